@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+	"github.com/lukasmalkmus/icloud-go/icloud/schema"
+)
+
+// initialisms are written in upper case when they make up a whole word of a
+// name.
+var initialisms = map[string]bool{
+	"API":  true,
+	"HTML": true,
+	"HTTP": true,
+	"ID":   true,
+	"IP":   true,
+	"JSON": true,
+	"URL":  true,
+	"UUID": true,
+}
+
+// goField is a field of a record type as written to the generated struct.
+type goField struct {
+	schema.Field
+
+	// ident is the name of the struct field.
+	ident string
+	// constant is the name of the constant holding the field name.
+	constant string
+	// goType is the Go type of the struct field.
+	goType string
+	// writeType is the field type used when writing the field.
+	writeType icloud.FieldType
+}
+
+// goRecordType is a record type as written to the generated struct.
+type goRecordType struct {
+	name     string
+	ident    string
+	constant string
+	fields   []goField
+}
+
+// generator generates Go code for the record types of a schema.
+type generator struct {
+	buf bytes.Buffer
+}
+
+// printf writes formatted code.
+func (g *generator) printf(format string, a ...interface{}) {
+	fmt.Fprintf(&g.buf, format, a...)
+}
+
+// generate returns the formatted source of a file of the given package which
+// holds the record types of the schema. If types is not empty, only the given
+// record types are generated. The command line is recorded in the header.
+func generate(s *schema.Schema, pkg, cmdline string, types []string) ([]byte, error) {
+	recordTypes, err := selectRecordTypes(s, types)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		g        generator
+		idents   = make(map[string]string)
+		goTypes  = make([]goRecordType, 0, len(recordTypes))
+		usesTime bool
+	)
+	for _, rt := range recordTypes {
+		var grt goRecordType
+		if grt, err = newGoRecordType(rt); err != nil {
+			return nil, err
+		}
+
+		for _, ident := range []string{grt.ident, grt.constant} {
+			if other, ok := idents[ident]; ok {
+				return nil, fmt.Errorf("record types %q and %q both map to %s", other, rt.Name, ident)
+			}
+			idents[ident] = rt.Name
+		}
+		for _, field := range grt.fields {
+			if field.goType == "time.Time" || field.goType == "[]time.Time" {
+				usesTime = true
+			}
+		}
+
+		goTypes = append(goTypes, grt)
+	}
+
+	var body generator
+	body.writeConstants(goTypes)
+	for _, grt := range goTypes {
+		body.writeRecordType(grt)
+	}
+	body.writeHelpers(usesTime)
+
+	g.printf("// Code generated by %q; DO NOT EDIT.\n\n", cmdline)
+	g.printf("package %s\n\n", pkg)
+	g.printf("import (\n\t\"encoding/json\"\n\t\"fmt\"\n")
+	if usesTime {
+		g.printf("\t\"time\"\n")
+	}
+	g.printf("\n\t\"github.com/lukasmalkmus/icloud-go/icloud\"\n)\n")
+	g.buf.Write(body.buf.Bytes())
+
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w", err)
+	}
+	return src, nil
+}
+
+// selectRecordTypes returns the record types with the given names, sorted by
+// name. If no names are given, all record types are returned.
+func selectRecordTypes(s *schema.Schema, names []string) ([]schema.RecordType, error) {
+	var recordTypes []schema.RecordType
+	if len(names) == 0 {
+		recordTypes = append(recordTypes, s.RecordTypes...)
+	}
+	for _, name := range names {
+		rt, ok := s.RecordType(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown record type %q", name)
+		}
+		recordTypes = append(recordTypes, *rt)
+	}
+
+	if len(recordTypes) == 0 {
+		return nil, fmt.Errorf("no record types to generate")
+	}
+
+	sort.Slice(recordTypes, func(i, j int) bool {
+		return recordTypes[i].Name < recordTypes[j].Name
+	})
+	return recordTypes, nil
+}
+
+// newGoRecordType maps a record type to Go. System fields are omitted.
+func newGoRecordType(rt schema.RecordType) (goRecordType, error) {
+	ident, err := exported(rt.Name)
+	if err != nil {
+		return goRecordType{}, fmt.Errorf("record type %q: %w", rt.Name, err)
+	}
+
+	grt := goRecordType{
+		name:     rt.Name,
+		ident:    ident,
+		constant: "RecordType" + ident,
+	}
+
+	// The struct fields holding the record name and change tag are reserved.
+	idents := map[string]string{
+		"RecordName": "",
+		"ChangeTag":  "",
+	}
+	for _, field := range rt.Fields {
+		if field.IsSystem() {
+			continue
+		}
+
+		var fieldIdent string
+		if fieldIdent, err = exported(field.Name); err != nil {
+			return grt, fmt.Errorf("field %q of record type %q: %w", field.Name, rt.Name, err)
+		}
+		if other, ok := idents[fieldIdent]; ok {
+			if other == "" {
+				return grt, fmt.Errorf("field %q of record type %q maps to reserved name %s", field.Name, rt.Name, fieldIdent)
+			}
+			return grt, fmt.Errorf("fields %q and %q of record type %q both map to %s", other, field.Name, rt.Name, fieldIdent)
+		}
+		idents[fieldIdent] = field.Name
+
+		goType, writeType := mapType(field.Type)
+		grt.fields = append(grt.fields, goField{
+			Field:     field,
+			ident:     fieldIdent,
+			constant:  ident + "Field" + fieldIdent,
+			goType:    goType,
+			writeType: writeType,
+		})
+	}
+
+	sort.Slice(grt.fields, func(i, j int) bool {
+		return grt.fields[i].Name < grt.fields[j].Name
+	})
+	return grt, nil
+}
+
+// mapType returns the Go type of values of a field type and the field type
+// used when writing them. Assets are written as asset ids.
+func mapType(typ icloud.FieldType) (string, icloud.FieldType) {
+	if typ.IsList() {
+		elem, writeType := mapType(typ.Elem())
+		return "[]" + strings.TrimPrefix(elem, "*"), writeType.List()
+	}
+
+	switch typ {
+	case icloud.TypeAsset, icloud.TypeAssetID:
+		return "*icloud.Asset", icloud.TypeAssetID
+	case icloud.TypeBytes:
+		return "[]byte", typ
+	case icloud.TypeDouble:
+		return "float64", typ
+	case icloud.TypeInt64:
+		return "int64", typ
+	case icloud.TypeLocation:
+		return "*icloud.Location", typ
+	case icloud.TypeReference:
+		return "*icloud.Reference", typ
+	case icloud.TypeTimestamp:
+		return "time.Time", typ
+	}
+	return "string", icloud.TypeString
+}
+
+// exported returns an exported Go identifier for a name. Words separated by
+// underscores or other characters not valid in identifiers are joined in
+// camel case.
+func exported(name string) (string, error) {
+	words := strings.FieldsFunc(name, func(c rune) bool {
+		return c == '_' || !(unicode.IsLetter(c) || unicode.IsDigit(c))
+	})
+
+	var sb strings.Builder
+	for _, word := range words {
+		if upper := strings.ToUpper(word); initialisms[upper] {
+			sb.WriteString(upper)
+			continue
+		}
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		sb.WriteString(string(runes))
+	}
+
+	ident := sb.String()
+	if ident != "" && unicode.IsDigit([]rune(ident)[0]) {
+		ident = "X" + ident
+	}
+	if !token.IsIdentifier(ident) || !token.IsExported(ident) {
+		return "", fmt.Errorf("can't derive a Go identifier from %q", name)
+	}
+	return ident, nil
+}
+
+// writeConstants writes the record type and field name constants.
+func (g *generator) writeConstants(goTypes []goRecordType) {
+	g.printf("\n// Record types of the schema.\nconst (\n")
+	for _, grt := range goTypes {
+		g.printf("\t%s = %q\n", grt.constant, grt.name)
+	}
+	g.printf(")\n")
+
+	for _, grt := range goTypes {
+		if len(grt.fields) == 0 {
+			continue
+		}
+		g.printf("\n// Field names of the %s record type.\nconst (\n", grt.name)
+		for _, field := range grt.fields {
+			g.printf("\t%s = %q\n", field.constant, field.Name)
+		}
+		g.printf(")\n")
+	}
+}
+
+// writeRecordType writes the struct of a record type and its methods.
+func (g *generator) writeRecordType(grt goRecordType) {
+	g.printf("\n// %s is a record of the %s record type.\n", grt.ident, grt.name)
+	g.printf("type %s struct {\n", grt.ident)
+	g.printf("\t// RecordName of the record.\n\tRecordName string\n")
+	g.printf("\t// ChangeTag of the record. Required to update or replace the record\n\t// without force.\n\tChangeTag string\n\n")
+	for _, field := range grt.fields {
+		g.printf("\t%s %s\n", field.ident, field.goType)
+	}
+	g.printf("}\n")
+
+	g.printf("\n// MarshalRecord returns the %s as a record. All fields are set, nil values\n// clear a field.\n", grt.ident)
+	g.printf("func (r *%s) MarshalRecord() icloud.Record {\n", grt.ident)
+	g.printf("\treturn icloud.Record{\n\t\tName: r.RecordName,\n\t\tType: %s,\n\t\tChangeTag: r.ChangeTag,\n", grt.constant)
+	g.printf("\t\tFields: icloud.Fields{\n")
+	for _, field := range grt.fields {
+		value := "r." + field.ident
+		switch field.goType {
+		case "time.Time":
+			value = "ckMillis(" + value + ")"
+		case "[]time.Time":
+			value = "ckMillisList(" + value + ")"
+		}
+		g.printf("\t\t\t{Name: %s, Type: %q, Value: %s},\n", field.constant, field.writeType, value)
+	}
+	g.printf("\t\t},\n\t}\n}\n")
+
+	g.printf("\n// UnmarshalRecord sets the %s from the record. Fields not defined by the\n// schema are ignored.\n", grt.ident)
+	g.printf("func (r *%s) UnmarshalRecord(record icloud.Record) error {\n", grt.ident)
+	g.printf("\tif record.Type != %s {\n", grt.constant)
+	g.printf("\t\treturn fmt.Errorf(\"record %%q is of type %%q, not %%q\", record.Name, record.Type, %s)\n\t}\n\n", grt.constant)
+	g.printf("\t*r = %s{\n\t\tRecordName: record.Name,\n\t\tChangeTag: record.ChangeTag,\n\t}\n", grt.ident)
+	if len(grt.fields) == 0 {
+		g.printf("\treturn nil\n}\n")
+		return
+	}
+	g.printf("\tfor _, field := range record.Fields {\n\t\tvar err error\n\t\tswitch field.Name {\n")
+	for _, field := range grt.fields {
+		decode := "ckDecode"
+		switch field.goType {
+		case "time.Time":
+			decode = "ckDecodeTime"
+		case "[]time.Time":
+			decode = "ckDecodeTimeList"
+		}
+		g.printf("\t\tcase %s:\n\t\t\terr = %s(field.Value, &r.%s)\n", field.constant, decode, field.ident)
+	}
+	g.printf("\t\t}\n\t\tif err != nil {\n")
+	g.printf("\t\t\treturn fmt.Errorf(\"field %%q of record %%q: %%w\", field.Name, record.Name, err)\n")
+	g.printf("\t\t}\n\t}\n\n\treturn nil\n}\n")
+}
+
+// writeHelpers writes the unexported helper functions used by the generated
+// methods.
+func (g *generator) writeHelpers(usesTime bool) {
+	g.printf(`
+// ckDecode decodes a field value as returned by the server into v.
+func ckDecode(value interface{}, v interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+`)
+
+	if !usesTime {
+		return
+	}
+
+	g.printf(`
+// ckMillis returns the time in milliseconds since the Unix epoch or nil for
+// the zero time.
+func ckMillis(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// ckMillisList returns the times in milliseconds since the Unix epoch.
+func ckMillisList(ts []time.Time) interface{} {
+	if ts == nil {
+		return nil
+	}
+	ms := make([]int64, len(ts))
+	for i, t := range ts {
+		ms[i] = t.UnixNano() / int64(time.Millisecond)
+	}
+	return ms
+}
+
+// ckDecodeTime decodes a timestamp in milliseconds since the Unix epoch.
+func ckDecodeTime(value interface{}, t *time.Time) error {
+	var ms *int64
+	if err := ckDecode(value, &ms); err != nil || ms == nil {
+		return err
+	}
+	*t = time.Unix(0, *ms*int64(time.Millisecond)).UTC()
+	return nil
+}
+
+// ckDecodeTimeList decodes a list of timestamps in milliseconds since the Unix
+// epoch.
+func ckDecodeTimeList(value interface{}, ts *[]time.Time) error {
+	var ms []int64
+	if err := ckDecode(value, &ms); err != nil || ms == nil {
+		return err
+	}
+	*ts = make([]time.Time, len(ms))
+	for i := range ms {
+		(*ts)[i] = time.Unix(0, ms[i]*int64(time.Millisecond)).UTC()
+	}
+	return nil
+}
+`)
+}
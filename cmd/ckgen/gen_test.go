@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lukasmalkmus/icloud-go/icloud/schema"
+)
+
+func TestGenerate(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "records_gen.go")
+
+	// Use the command line of the go:generate directive of the example package.
+	err := generateFile("internal/example/schema.ckdb", output, "example", "ckgen -schema schema.ckdb -output records_gen.go", nil)
+	require.NoError(t, err)
+
+	got, err := os.ReadFile(output)
+	require.NoError(t, err)
+
+	// The generated code of the example package is up to date.
+	exp, err := os.ReadFile("internal/example/records_gen.go")
+	require.NoError(t, err)
+	assert.Equal(t, string(exp), string(got))
+}
+
+func TestGenerate_Types(t *testing.T) {
+	s, err := schema.Parse(strings.NewReader(`DEFINE SCHEMA
+		RECORD TYPE Author (name STRING);
+		RECORD TYPE Post (title STRING);
+	`))
+	require.NoError(t, err)
+
+	src, err := generate(s, "models", "ckgen", []string{"Author"})
+	require.NoError(t, err)
+	assert.Contains(t, string(src), "type Author struct")
+	assert.NotContains(t, string(src), "type Post struct")
+	assert.NotContains(t, string(src), `"time"`)
+
+	_, err = generate(s, "models", "ckgen", []string{"Comment"})
+	assert.EqualError(t, err, `unknown record type "Comment"`)
+}
+
+func TestGenerate_Error(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		err    string
+	}{
+		{
+			name:   "no record types",
+			schema: "DEFINE SCHEMA",
+			err:    "no record types to generate",
+		},
+		{
+			name:   "colliding record types",
+			schema: "DEFINE SCHEMA RECORD TYPE blog_post (); RECORD TYPE BlogPost ();",
+			err:    `record types "BlogPost" and "blog_post" both map to BlogPost`,
+		},
+		{
+			name:   "colliding fields",
+			schema: "DEFINE SCHEMA RECORD TYPE Post (source_url STRING, sourceURL STRING);",
+			err:    `fields "source_url" and "sourceURL" of record type "Post" both map to SourceURL`,
+		},
+		{
+			name:   "reserved field",
+			schema: "DEFINE SCHEMA RECORD TYPE Post (recordName STRING);",
+			err:    `field "recordName" of record type "Post" maps to reserved name RecordName`,
+		},
+		{
+			name:   "invalid name",
+			schema: `DEFINE SCHEMA RECORD TYPE "_" ();`,
+			err:    `record type "_": can't derive a Go identifier from "_"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := schema.Parse(strings.NewReader(tt.schema))
+			require.NoError(t, err)
+
+			_, err = generate(s, "models", "ckgen", nil)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestExported(t *testing.T) {
+	tests := map[string]string{
+		"title":      "Title",
+		"source_url": "SourceURL",
+		"userId":     "UserId",
+		"user_id":    "UserID",
+		"2fa":        "X2fa",
+		"café":       "Café",
+		"a-b c":      "ABC",
+	}
+	for name, exp := range tests {
+		got, err := exported(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, exp, got, name)
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		env    func(string) string
+		code   int
+		stderr string
+	}{
+		{
+			name:   "missing schema",
+			env:    env("example"),
+			code:   2,
+			stderr: "ckgen: missing -schema",
+		},
+		{
+			name:   "missing package",
+			args:   []string{"-schema", "schema.ckdb"},
+			env:    env(""),
+			code:   2,
+			stderr: "ckgen: missing -package and $GOPACKAGE is not set",
+		},
+		{
+			name:   "missing schema file",
+			args:   []string{"-schema", "missing.ckdb"},
+			env:    env("example"),
+			code:   1,
+			stderr: "ckgen: open missing.ckdb",
+		},
+		{
+			name: "help",
+			args: []string{"-h"},
+			env:  env(""),
+			code: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stderr bytes.Buffer
+			assert.Equal(t, tt.code, run(tt.args, tt.env, &stderr))
+			assert.Contains(t, stderr.String(), tt.stderr)
+		})
+	}
+}
+
+func TestRun_DefaultOutput(t *testing.T) {
+	dir := t.TempDir()
+	schemaFile := filepath.Join(dir, "models.ckdb")
+	require.NoError(t, os.WriteFile(schemaFile, []byte("DEFINE SCHEMA RECORD TYPE Post (title STRING);"), 0o600))
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer func() { require.NoError(t, os.Chdir(wd)) }()
+
+	var stderr bytes.Buffer
+	require.Zero(t, run([]string{"-schema", schemaFile}, env("models"), &stderr), stderr.String())
+
+	src, err := os.ReadFile("models_gen.go")
+	require.NoError(t, err)
+	assert.Contains(t, string(src), "package models")
+}
+
+// env returns a getenv function that only knows $GOPACKAGE.
+func env(pkg string) func(string) string {
+	return func(key string) string {
+		if key == "GOPACKAGE" {
+			return pkg
+		}
+		return ""
+	}
+}
@@ -0,0 +1,5 @@
+// Package example holds the code ckgen generates for an example schema. It
+// ensures the generated code compiles and behaves as expected.
+package example
+
+//go:generate go run github.com/lukasmalkmus/icloud-go/cmd/ckgen -schema schema.ckdb -output records_gen.go
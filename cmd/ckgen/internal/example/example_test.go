@@ -0,0 +1,90 @@
+package example
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+	"github.com/lukasmalkmus/icloud-go/icloud/icloudtest"
+	"github.com/lukasmalkmus/icloud-go/icloud/schema"
+)
+
+func TestRecords(t *testing.T) {
+	f, err := os.Open("schema.ckdb")
+	require.NoError(t, err)
+	defer f.Close()
+
+	s, err := schema.Parse(f)
+	require.NoError(t, err)
+
+	srv := icloudtest.NewServer()
+	defer srv.Close()
+
+	client, err := srv.NewClient("iCloud.com.lukasmalkmus.Example-App", icloud.Development, icloud.SetSchema(s))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	uploadRes, err := client.Assets.Upload(ctx, icloud.Public, icloud.UploadRequest{
+		Tokens: []icloud.UploadToken{{RecordName: "hello", RecordType: RecordTypePost, FieldName: PostFieldCover}},
+	})
+	require.NoError(t, err)
+	cover, err := client.Assets.UploadData(ctx, uploadRes.Tokens[0].URL, strings.NewReader("cover"))
+	require.NoError(t, err)
+
+	published := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	post := Post{
+		RecordName: "hello",
+		Author:     &icloud.Reference{RecordName: "alice", Action: icloud.ActionDeleteSelf},
+		Body:       "Hello, World!",
+		Cover:      cover,
+		Edits:      []time.Time{published.Add(time.Hour)},
+		Likes:      42,
+		Location:   &icloud.Location{Latitude: 52.52, Longitude: 13.405},
+		Published:  published,
+		Rating:     4.5,
+		Signature:  []byte{0xca, 0xfe},
+		SourceURL:  "https://example.com",
+		Tags:       []string{"go", "cloudkit"},
+		Title:      "Hello",
+	}
+
+	res, err := client.Records.Modify(ctx, icloud.Public, icloud.RecordsRequest{
+		Operations: []icloud.RecordOperation{
+			{Type: icloud.Create, Record: (&Author{RecordName: "alice", Name: "Alice"}).MarshalRecord()},
+			{Type: icloud.Create, Record: post.MarshalRecord()},
+		},
+	})
+	require.NoError(t, err)
+	for _, record := range res.Records {
+		require.NoError(t, record.Err())
+	}
+
+	lookup, err := client.Records.Lookup(ctx, icloud.Public, icloud.LookupRequest{
+		Records: []icloud.Record{{Name: "hello"}},
+	})
+	require.NoError(t, err)
+
+	var got Post
+	require.NoError(t, got.UnmarshalRecord(lookup.Records[0]))
+	assert.NotEmpty(t, got.ChangeTag)
+	assert.Equal(t, cover.FileChecksum, got.Cover.FileChecksum)
+	assert.NotEmpty(t, got.Cover.DownloadURL)
+
+	post.ChangeTag, post.Cover = got.ChangeTag, got.Cover
+	assert.Equal(t, post, got)
+
+	// Records of other types are rejected.
+	var author Author
+	assert.EqualError(t, author.UnmarshalRecord(lookup.Records[0]), `record "hello" is of type "Post", not "Author"`)
+
+	// Zero values clear fields and unset fields are zero.
+	require.NoError(t, got.UnmarshalRecord((&Post{RecordName: "empty"}).MarshalRecord()))
+	assert.Equal(t, Post{RecordName: "empty"}, got)
+}
@@ -0,0 +1,237 @@
+// Code generated by "ckgen -schema schema.ckdb -output records_gen.go"; DO NOT EDIT.
+
+package example
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// Record types of the schema.
+const (
+	RecordTypeAuthor = "Author"
+	RecordTypePost   = "Post"
+)
+
+// Field names of the Author record type.
+const (
+	AuthorFieldName = "name"
+)
+
+// Field names of the Post record type.
+const (
+	PostFieldAuthor    = "author"
+	PostFieldBody      = "body"
+	PostFieldCover     = "cover"
+	PostFieldEdits     = "edits"
+	PostFieldLikes     = "likes"
+	PostFieldLocation  = "location"
+	PostFieldPublished = "published"
+	PostFieldRating    = "rating"
+	PostFieldSignature = "signature"
+	PostFieldSourceURL = "source_url"
+	PostFieldTags      = "tags"
+	PostFieldTitle     = "title"
+)
+
+// Author is a record of the Author record type.
+type Author struct {
+	// RecordName of the record.
+	RecordName string
+	// ChangeTag of the record. Required to update or replace the record
+	// without force.
+	ChangeTag string
+
+	Name string
+}
+
+// MarshalRecord returns the Author as a record. All fields are set, nil values
+// clear a field.
+func (r *Author) MarshalRecord() icloud.Record {
+	return icloud.Record{
+		Name:      r.RecordName,
+		Type:      RecordTypeAuthor,
+		ChangeTag: r.ChangeTag,
+		Fields: icloud.Fields{
+			{Name: AuthorFieldName, Type: "STRING", Value: r.Name},
+		},
+	}
+}
+
+// UnmarshalRecord sets the Author from the record. Fields not defined by the
+// schema are ignored.
+func (r *Author) UnmarshalRecord(record icloud.Record) error {
+	if record.Type != RecordTypeAuthor {
+		return fmt.Errorf("record %q is of type %q, not %q", record.Name, record.Type, RecordTypeAuthor)
+	}
+
+	*r = Author{
+		RecordName: record.Name,
+		ChangeTag:  record.ChangeTag,
+	}
+	for _, field := range record.Fields {
+		var err error
+		switch field.Name {
+		case AuthorFieldName:
+			err = ckDecode(field.Value, &r.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("field %q of record %q: %w", field.Name, record.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Post is a record of the Post record type.
+type Post struct {
+	// RecordName of the record.
+	RecordName string
+	// ChangeTag of the record. Required to update or replace the record
+	// without force.
+	ChangeTag string
+
+	Author    *icloud.Reference
+	Body      string
+	Cover     *icloud.Asset
+	Edits     []time.Time
+	Likes     int64
+	Location  *icloud.Location
+	Published time.Time
+	Rating    float64
+	Signature []byte
+	SourceURL string
+	Tags      []string
+	Title     string
+}
+
+// MarshalRecord returns the Post as a record. All fields are set, nil values
+// clear a field.
+func (r *Post) MarshalRecord() icloud.Record {
+	return icloud.Record{
+		Name:      r.RecordName,
+		Type:      RecordTypePost,
+		ChangeTag: r.ChangeTag,
+		Fields: icloud.Fields{
+			{Name: PostFieldAuthor, Type: "REFERENCE", Value: r.Author},
+			{Name: PostFieldBody, Type: "STRING", Value: r.Body},
+			{Name: PostFieldCover, Type: "ASSETID", Value: r.Cover},
+			{Name: PostFieldEdits, Type: "TIMESTAMP_LIST", Value: ckMillisList(r.Edits)},
+			{Name: PostFieldLikes, Type: "INT64", Value: r.Likes},
+			{Name: PostFieldLocation, Type: "LOCATION", Value: r.Location},
+			{Name: PostFieldPublished, Type: "TIMESTAMP", Value: ckMillis(r.Published)},
+			{Name: PostFieldRating, Type: "DOUBLE", Value: r.Rating},
+			{Name: PostFieldSignature, Type: "BYTES", Value: r.Signature},
+			{Name: PostFieldSourceURL, Type: "STRING", Value: r.SourceURL},
+			{Name: PostFieldTags, Type: "STRING_LIST", Value: r.Tags},
+			{Name: PostFieldTitle, Type: "STRING", Value: r.Title},
+		},
+	}
+}
+
+// UnmarshalRecord sets the Post from the record. Fields not defined by the
+// schema are ignored.
+func (r *Post) UnmarshalRecord(record icloud.Record) error {
+	if record.Type != RecordTypePost {
+		return fmt.Errorf("record %q is of type %q, not %q", record.Name, record.Type, RecordTypePost)
+	}
+
+	*r = Post{
+		RecordName: record.Name,
+		ChangeTag:  record.ChangeTag,
+	}
+	for _, field := range record.Fields {
+		var err error
+		switch field.Name {
+		case PostFieldAuthor:
+			err = ckDecode(field.Value, &r.Author)
+		case PostFieldBody:
+			err = ckDecode(field.Value, &r.Body)
+		case PostFieldCover:
+			err = ckDecode(field.Value, &r.Cover)
+		case PostFieldEdits:
+			err = ckDecodeTimeList(field.Value, &r.Edits)
+		case PostFieldLikes:
+			err = ckDecode(field.Value, &r.Likes)
+		case PostFieldLocation:
+			err = ckDecode(field.Value, &r.Location)
+		case PostFieldPublished:
+			err = ckDecodeTime(field.Value, &r.Published)
+		case PostFieldRating:
+			err = ckDecode(field.Value, &r.Rating)
+		case PostFieldSignature:
+			err = ckDecode(field.Value, &r.Signature)
+		case PostFieldSourceURL:
+			err = ckDecode(field.Value, &r.SourceURL)
+		case PostFieldTags:
+			err = ckDecode(field.Value, &r.Tags)
+		case PostFieldTitle:
+			err = ckDecode(field.Value, &r.Title)
+		}
+		if err != nil {
+			return fmt.Errorf("field %q of record %q: %w", field.Name, record.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// ckDecode decodes a field value as returned by the server into v.
+func ckDecode(value interface{}, v interface{}) error {
+	if value == nil {
+		return nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// ckMillis returns the time in milliseconds since the Unix epoch or nil for
+// the zero time.
+func ckMillis(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// ckMillisList returns the times in milliseconds since the Unix epoch.
+func ckMillisList(ts []time.Time) interface{} {
+	if ts == nil {
+		return nil
+	}
+	ms := make([]int64, len(ts))
+	for i, t := range ts {
+		ms[i] = t.UnixNano() / int64(time.Millisecond)
+	}
+	return ms
+}
+
+// ckDecodeTime decodes a timestamp in milliseconds since the Unix epoch.
+func ckDecodeTime(value interface{}, t *time.Time) error {
+	var ms *int64
+	if err := ckDecode(value, &ms); err != nil || ms == nil {
+		return err
+	}
+	*t = time.Unix(0, *ms*int64(time.Millisecond)).UTC()
+	return nil
+}
+
+// ckDecodeTimeList decodes a list of timestamps in milliseconds since the Unix
+// epoch.
+func ckDecodeTimeList(value interface{}, ts *[]time.Time) error {
+	var ms []int64
+	if err := ckDecode(value, &ms); err != nil || ms == nil {
+		return err
+	}
+	*ts = make([]time.Time, len(ms))
+	for i := range ms {
+		(*ts)[i] = time.Unix(0, ms[i]*int64(time.Millisecond)).UTC()
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Command ckgen generates Go types for the record types of a CloudKit schema
+// in the .ckdb format.
+//
+// Usage:
+//
+//	ckgen -schema schema.ckdb [-package name] [-output file] [-types Type,...]
+//
+// For every record type, ckgen emits a struct with a field for each non-system
+// field of the record type, a constant holding the name of the record type,
+// constants holding the field names and MarshalRecord and UnmarshalRecord
+// methods which convert the struct to and from an icloud.Record. Field types
+// map to Go types as follows:
+//
+//	ASSET      *icloud.Asset
+//	BYTES      []byte
+//	DOUBLE     float64
+//	INT64      int64
+//	LOCATION   *icloud.Location
+//	REFERENCE  *icloud.Reference
+//	STRING     string
+//	TIMESTAMP  time.Time
+//	LIST<T>    []T
+//
+// It is meant to be run by "go generate", which sets the package name:
+//
+//	//go:generate go run github.com/lukasmalkmus/icloud-go/cmd/ckgen -schema schema.ckdb
+//
+// The output file defaults to the name of the schema file with the extension
+// replaced by "_gen.go".
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lukasmalkmus/icloud-go/icloud/schema"
+)
+
+const usage = `Usage: ckgen -schema schema.ckdb [-package name] [-output file] [-types Type,...]
+
+Flags:
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Getenv, os.Stderr))
+}
+
+// run runs ckgen with the given arguments and returns the exit code.
+func run(args []string, getenv func(string) string, stderr io.Writer) int {
+	fs := flag.NewFlagSet("ckgen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		fs.PrintDefaults()
+	}
+
+	var (
+		schemaFile = fs.String("schema", "", "`path` of the .ckdb schema file")
+		pkg        = fs.String("package", getenv("GOPACKAGE"), "`name` of the package of the generated file; defaults to $GOPACKAGE")
+		output     = fs.String("output", "", "`path` of the generated file; defaults to the schema file name with a _gen.go suffix")
+		types      = fs.String("types", "", "comma separated `list` of record types to generate; defaults to all")
+	)
+	if err := fs.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+
+	switch {
+	case *schemaFile == "":
+		fmt.Fprintln(stderr, "ckgen: missing -schema")
+		fs.Usage()
+		return 2
+	case *pkg == "":
+		fmt.Fprintln(stderr, "ckgen: missing -package and $GOPACKAGE is not set")
+		fs.Usage()
+		return 2
+	case fs.NArg() > 0:
+		fmt.Fprintf(stderr, "ckgen: unexpected arguments %q\n", fs.Args())
+		fs.Usage()
+		return 2
+	}
+
+	if *output == "" {
+		*output = strings.TrimSuffix(filepath.Base(*schemaFile), filepath.Ext(*schemaFile)) + "_gen.go"
+	}
+
+	var typeNames []string
+	if *types != "" {
+		typeNames = strings.Split(*types, ",")
+	}
+
+	cmdline := strings.Join(append([]string{"ckgen"}, args...), " ")
+	if err := generateFile(*schemaFile, *output, *pkg, cmdline, typeNames); err != nil {
+		fmt.Fprintf(stderr, "ckgen: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// generateFile generates the code for the schema file and writes it to the
+// output file.
+func generateFile(schemaFile, output, pkg, cmdline string, types []string) error {
+	f, err := os.Open(schemaFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s, err := schema.Parse(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", schemaFile, err)
+	}
+
+	src, err := generate(s, pkg, cmdline, types)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(output, src, 0o644) //nolint:gosec // Generated code is not secret.
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// config is the configuration of the cli.
+type config struct {
+	Container      string `json:"container"`
+	Environment    string `json:"environment"`
+	Database       string `json:"database"`
+	KeyID          string `json:"keyID"`
+	PrivateKey     string `json:"privateKey"`
+	PrivateKeyFile string `json:"privateKeyFile"`
+	BaseURL        string `json:"baseURL"`
+
+	environment icloud.Environment
+	database    icloud.Database
+	privateKey  *ecdsa.PrivateKey
+}
+
+// loadConfig loads the configuration from the config file at the given path
+// and the environment. If the path is empty, the path is taken from the
+// environment or defaults to icloud/config.json in the user config directory.
+// A missing default config file is not an error.
+func loadConfig(path string, getenv func(string) string) (*config, error) {
+	cfg := &config{
+		Environment: icloud.Development.String(),
+		Database:    icloud.Public.String(),
+	}
+
+	if path == "" {
+		path = getenv("ICLOUD_CONFIG")
+	}
+	explicit := path != ""
+	if !explicit {
+		if dir, err := os.UserConfigDir(); err == nil {
+			path = filepath.Join(dir, "icloud", "config.json")
+		}
+	}
+
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) && !explicit {
+			b = nil
+		} else if err != nil {
+			return nil, fmt.Errorf("read config file: %w", err)
+		}
+
+		if b != nil {
+			if err = json.Unmarshal(b, cfg); err != nil {
+				return nil, fmt.Errorf("parse config file %s: %w", path, err)
+			}
+		}
+	}
+
+	for _, v := range []struct {
+		dst *string
+		key string
+	}{
+		{&cfg.Container, "ICLOUD_CONTAINER"},
+		{&cfg.Environment, "ICLOUD_ENVIRONMENT"},
+		{&cfg.Database, "ICLOUD_DATABASE"},
+		{&cfg.KeyID, "ICLOUD_KEY_ID"},
+		{&cfg.PrivateKey, "ICLOUD_PRIVATE_KEY"},
+		{&cfg.PrivateKeyFile, "ICLOUD_PRIVATE_KEY_FILE"},
+		{&cfg.BaseURL, "ICLOUD_BASE_URL"},
+	} {
+		if s := getenv(v.key); s != "" {
+			*v.dst = s
+		}
+	}
+
+	return cfg, nil
+}
+
+// override overrides the configuration with the non-empty values given.
+func (cfg *config) override(container, environment, database string) {
+	if container != "" {
+		cfg.Container = container
+	}
+	if environment != "" {
+		cfg.Environment = environment
+	}
+	if database != "" {
+		cfg.Database = database
+	}
+}
+
+// validate validates the configuration and parses its values. It must be
+// called before the parsed values are used.
+func (cfg *config) validate() error {
+	if cfg.Container == "" {
+		return errors.New("missing container")
+	} else if cfg.KeyID == "" {
+		return errors.New("missing key id")
+	}
+
+	switch cfg.Environment {
+	case icloud.Development.String():
+		cfg.environment = icloud.Development
+	case icloud.Production.String():
+		cfg.environment = icloud.Production
+	default:
+		return fmt.Errorf("invalid environment %q", cfg.Environment)
+	}
+
+	switch cfg.Database {
+	case icloud.Public.String():
+		cfg.database = icloud.Public
+	case icloud.Private.String():
+		cfg.database = icloud.Private
+	case icloud.Shared.String():
+		cfg.database = icloud.Shared
+	default:
+		return fmt.Errorf("invalid database %q", cfg.Database)
+	}
+
+	rawPrivateKey := []byte(cfg.PrivateKey)
+	if len(rawPrivateKey) == 0 && cfg.PrivateKeyFile != "" {
+		var err error
+		if rawPrivateKey, err = os.ReadFile(cfg.PrivateKeyFile); err != nil {
+			return fmt.Errorf("read private key: %w", err)
+		}
+	} else if len(rawPrivateKey) == 0 {
+		return errors.New("missing private key")
+	}
+
+	var err error
+	if cfg.privateKey, err = parsePrivateKey(rawPrivateKey); err != nil {
+		return fmt.Errorf("parse private key: %w", err)
+	}
+
+	return nil
+}
+
+// parsePrivateKey parses a PEM or DER encoded ECDSA private key, as created by
+// "openssl ecparam -name prime256v1 -genkey -noout".
+func parsePrivateKey(b []byte) (*ecdsa.PrivateKey, error) {
+	if block, _ := pem.Decode(b); block != nil {
+		b = block.Bytes
+	}
+
+	if privateKey, err := x509.ParseECPrivateKey(b); err == nil {
+		return privateKey, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(b)
+	if err != nil {
+		return nil, errors.New("not an ECDSA private key")
+	}
+
+	privateKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an ECDSA private key")
+	}
+
+	return privateKey, nil
+}
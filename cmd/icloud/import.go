@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/lukasmalkmus/icloud-go/icloud/importer"
+)
+
+// recordsImport runs the records import command.
+func (c *cli) recordsImport(ctx context.Context, args []string) error {
+	fs := c.flagSet("records import", "<file.csv>")
+	var (
+		mappingPath = fs.String("mapping", "", "JSON file with the mapping of CSV columns to record fields (required)")
+		zone        = fs.String("zone", "", "name of the zone to import into (default zone if empty)")
+		reportPath  = fs.String("report", "", "file to write the rejected rows to as CSV (stderr if empty)")
+		batchSize   = fs.Int("batch-size", 0, "number of records written per request (maximum if zero)")
+		dryRun      = fs.Bool("dry-run", false, "map the rows to records without writing them")
+	)
+	if err := parse(fs, args); err != nil {
+		return err
+	} else if *mappingPath == "" || fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	b, err := os.ReadFile(*mappingPath)
+	if err != nil {
+		return fmt.Errorf("read mapping: %w", err)
+	}
+
+	var mapping importer.Mapping
+	if err = json.Unmarshal(b, &mapping); err != nil {
+		return fmt.Errorf("parse mapping: %w", err)
+	}
+
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+
+	imp, err := importer.New(client, mapping, importer.Options{
+		Database:  c.config.database,
+		ZoneID:    zoneID(*zone),
+		BatchSize: *batchSize,
+		DryRun:    *dryRun,
+	})
+	if err != nil {
+		return fmt.Errorf("invalid mapping: %w", err)
+	}
+
+	var r io.Reader = c.stdin
+	if path := fs.Arg(0); path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	res, err := imp.Import(ctx, r)
+	if res == nil {
+		return err
+	}
+
+	if reportErr := c.writeReport(res, *reportPath); err == nil {
+		err = reportErr
+	}
+
+	header := []string{"ROWS", "IMPORTED", "REJECTED", "DRY RUN"}
+	summary := map[string]interface{}{
+		"rows":     res.Rows,
+		"imported": res.Imported,
+		"rejected": len(res.Rejected),
+		"dryRun":   *dryRun,
+	}
+	if printErr := c.out.print(header, []interface{}{summary}, func(interface{}) []string {
+		return []string{
+			strconv.Itoa(res.Rows),
+			strconv.Itoa(res.Imported),
+			strconv.Itoa(len(res.Rejected)),
+			strconv.FormatBool(*dryRun),
+		}
+	}); err == nil {
+		err = printErr
+	}
+
+	if err == nil && len(res.Rejected) > 0 {
+		err = fmt.Errorf("%d of %d rows rejected", len(res.Rejected), res.Rows)
+	}
+	return err
+}
+
+// writeReport writes the rejected rows of an import to the file at the given
+// path or, if the path is empty, to stderr.
+func (c *cli) writeReport(res *importer.Result, path string) error {
+	if path == "" {
+		for _, rej := range res.Rejected {
+			fmt.Fprintf(c.stderr, "icloud: line %d: %s\n", rej.Line, rej.Reason)
+		}
+		return nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err = res.WriteReport(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
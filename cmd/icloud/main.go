@@ -0,0 +1,264 @@
+// Command icloud is a command-line client for the CloudKit Web Services API.
+//
+// Usage:
+//
+//	icloud [flags] <command> [command flags] [arguments]
+//
+// The commands are:
+//
+//	records query   query records of a type
+//	records get     fetch records by name
+//	records create  create records
+//	records update  update records
+//	records delete  delete records by name
+//	records import  import records from a CSV file
+//	zones list      list the zones of a database
+//	schema infer    infer a draft schema from sampled records
+//	whoami          print the user the key belongs to
+//	version         print the version
+//
+// The client is configured from a JSON config file, ICLOUD_* environment
+// variables and flags, in ascending order of precedence. See the output of
+// "icloud -h" for details.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// version is set at build time.
+var version = "dev"
+
+const usage = `Usage: icloud [flags] <command> [command flags] [arguments]
+
+Commands:
+  records query   query records of a type
+  records get     fetch records by name
+  records create  create records
+  records update  update records
+  records delete  delete records by name
+  records import  import records from a CSV file
+  zones list      list the zones of a database
+  schema infer    infer a draft schema from sampled records
+  whoami          print the user the key belongs to
+  version         print the version
+
+Configuration is read from the config file, the environment and flags, in
+ascending order of precedence:
+
+  Config file       Environment              Flag
+  container         ICLOUD_CONTAINER         -container
+  environment       ICLOUD_ENVIRONMENT       -environment
+  database          ICLOUD_DATABASE          -database
+  keyID             ICLOUD_KEY_ID
+  privateKey        ICLOUD_PRIVATE_KEY
+  privateKeyFile    ICLOUD_PRIVATE_KEY_FILE
+  baseURL           ICLOUD_BASE_URL
+
+The config file is a JSON object with the keys above. It is read from the path
+given by -config or ICLOUD_CONFIG and defaults to icloud/config.json in the
+user config directory.
+
+Flags:
+`
+
+// errUsage is returned when the command line is invalid. The usage has been
+// printed already.
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	c := &cli{
+		stdin:  os.Stdin,
+		stdout: os.Stdout,
+		stderr: os.Stderr,
+		getenv: os.Getenv,
+	}
+
+	os.Exit(c.run(ctx, os.Args[1:]))
+}
+
+// cli is the command-line interface. Its dependencies are fields, so it can be
+// tested.
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+
+	config *config
+	client *icloud.Client
+	out    *printer
+}
+
+// command is a (sub)command of the cli.
+type command struct {
+	name  string
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
+
+// run runs the cli with the given arguments and returns the exit code.
+func (c *cli) run(ctx context.Context, args []string) int {
+	fs := flag.NewFlagSet("icloud", flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+
+	var (
+		configPath  = fs.String("config", "", "path of the config file")
+		container   = fs.String("container", "", "container identifier")
+		environment = fs.String("environment", "", "environment of the container (development or production)")
+		database    = fs.String("database", "", "database to use (public, private or shared)")
+		output      = fs.String("output", "table", "output format (table, json or ndjson)")
+	)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	format, err := parseFormat(*output)
+	if err != nil {
+		fmt.Fprintln(c.stderr, "icloud:", err)
+		return 2
+	}
+	c.out = &printer{w: c.stdout, format: format}
+
+	commands := []command{
+		{"records", "records <query|get|create|update|delete>", c.records},
+		{"zones", "zones <list>", c.zones},
+		{"schema", "schema <infer>", c.schema},
+		{"whoami", "whoami", c.whoami},
+	}
+
+	name := fs.Arg(0)
+	if name == "version" {
+		fmt.Fprintln(c.stdout, "icloud", version)
+		return 0
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == name {
+			cmd = &commands[i]
+			break
+		}
+	}
+	if cmd == nil {
+		if name != "" {
+			fmt.Fprintf(c.stderr, "icloud: unknown command %q\n", name)
+		}
+		fs.Usage()
+		return 2
+	}
+
+	if c.config, err = loadConfig(*configPath, c.getenv); err != nil {
+		fmt.Fprintln(c.stderr, "icloud:", err)
+		return 1
+	}
+	c.config.override(*container, *environment, *database)
+
+	if err = cmd.run(ctx, fs.Args()[1:]); errors.Is(err, flag.ErrHelp) {
+		return 0
+	} else if errors.Is(err, errUsage) {
+		return 2
+	} else if err != nil {
+		fmt.Fprintln(c.stderr, "icloud:", err)
+		return 1
+	}
+
+	return 0
+}
+
+// connect creates the client, if not done already.
+func (c *cli) connect() (*icloud.Client, error) {
+	if c.client != nil {
+		return c.client, nil
+	}
+
+	if err := c.config.validate(); err != nil {
+		return nil, err
+	}
+
+	client, err := newClient(c.config)
+	if err != nil {
+		return nil, fmt.Errorf("create client: %w", err)
+	}
+	c.client = client
+
+	return client, nil
+}
+
+// newClient creates a client talking to CloudKit from the configuration.
+func newClient(cfg *config) (*icloud.Client, error) {
+	options := []icloud.Option{
+		icloud.SetUserAgent("icloud-go/" + version),
+	}
+	if cfg.BaseURL != "" {
+		options = append(options, icloud.SetBaseURL(cfg.BaseURL))
+	}
+	return icloud.NewClient(cfg.Container, cfg.KeyID, cfg.privateKey, cfg.environment, options...)
+}
+
+// dispatch runs the subcommand named by the first argument.
+func (c *cli) dispatch(ctx context.Context, name string, args []string, subcommands []command) error {
+	usage := func() {
+		fmt.Fprintf(c.stderr, "Usage: icloud %s <command> [flags] [arguments]\n\nCommands:\n", name)
+		for _, cmd := range subcommands {
+			fmt.Fprintf(c.stderr, "  %s\n", cmd.usage)
+		}
+	}
+
+	if len(args) == 0 {
+		usage()
+		return errUsage
+	}
+
+	for _, cmd := range subcommands {
+		if cmd.name == args[0] {
+			return cmd.run(ctx, args[1:])
+		}
+	}
+
+	fmt.Fprintf(c.stderr, "icloud: unknown command %q\n", strings.TrimSpace(name+" "+args[0]))
+	usage()
+
+	return errUsage
+}
+
+// flagSet returns a new flag set for the named subcommand.
+func (c *cli) flagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: icloud %s\n\nFlags:\n", strings.TrimSpace(name+" [flags] "+args))
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses the arguments of a subcommand. Invalid arguments have been
+// reported by the flag set already.
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return err
+	} else if err != nil {
+		return errUsage
+	}
+	return nil
+}
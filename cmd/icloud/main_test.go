@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+	"github.com/lukasmalkmus/icloud-go/icloud/icloudtest"
+)
+
+const container = "iCloud.com.lukasmalkmus.Example-App"
+
+// setup starts a fake server and returns a function which runs the cli
+// against it.
+func setup(t *testing.T) (run func(stdin string, args ...string) (stdout, stderr string, code int), teardown func()) {
+	return setupProxy(t, nil)
+}
+
+// setupProxy is like setup but the requests of the cli are sent to the fake
+// server through the given proxy, if not nil.
+func setupProxy(t *testing.T, proxy func(srv http.Handler) http.Handler) (run func(stdin string, args ...string) (stdout, stderr string, code int), teardown func()) {
+	srv := icloudtest.NewServer()
+
+	baseURL, closeProxy := srv.URL, func() {}
+	if proxy != nil {
+		proxySrv := httptest.NewServer(proxy(srv))
+		baseURL, closeProxy = proxySrv.URL, proxySrv.Close
+	}
+
+	b, err := x509.MarshalECPrivateKey(srv.PrivateKey())
+	require.NoError(t, err)
+
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(configPath, []byte(`{"container": "`+container+`"}`), 0600))
+
+	env := map[string]string{
+		"ICLOUD_CONFIG":      configPath,
+		"ICLOUD_KEY_ID":      icloudtest.KeyID,
+		"ICLOUD_PRIVATE_KEY": string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b})),
+		"ICLOUD_BASE_URL":    baseURL,
+	}
+
+	run = func(stdin string, args ...string) (string, string, int) {
+		var stdout, stderr bytes.Buffer
+		c := &cli{
+			stdin:  strings.NewReader(stdin),
+			stdout: &stdout,
+			stderr: &stderr,
+			getenv: func(key string) string { return env[key] },
+		}
+		code := c.run(context.Background(), args)
+		return stdout.String(), stderr.String(), code
+	}
+
+	return run, func() {
+		closeProxy()
+		srv.Close()
+	}
+}
+
+func TestCLI_Records(t *testing.T) {
+	run, teardown := setup(t)
+	defer teardown()
+
+	stdout, stderr, code := run("", "records", "create", "-type", "Post", "-name", "a", "-field", "title=Hello", "-field", "likes:INT64=3")
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "likes=3 title=Hello")
+
+	stdin := `[
+		{"recordName": "b", "recordType": "Post", "fields": {"title": {"value": "World"}, "likes": {"value": 5}}},
+		{"recordName": "c", "recordType": "Post", "fields": {"title": {"value": "Again"}, "likes": {"value": 1}}}
+	]`
+	_, stderr, code = run(stdin, "records", "create", "-file", "-")
+	require.Equal(t, 0, code, stderr)
+
+	stdout, stderr, code = run("", "-output", "json", "records", "query", "-type", "Post", "-filter", "likes:INT64>=3", "-sort", "-likes")
+	require.Equal(t, 0, code, stderr)
+
+	var records []icloud.Record
+	require.NoError(t, json.Unmarshal([]byte(stdout), &records))
+	require.Len(t, records, 2)
+	assert.Equal(t, "b", records[0].Name)
+	assert.Equal(t, "a", records[1].Name)
+
+	stdout, stderr, code = run("", "-output", "ndjson", "records", "query", "-type", "Post", "-filter", "title BEGINS_WITH Ag", "-fields", "title")
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, 1, strings.Count(stdout, "\n"))
+	assert.Contains(t, stdout, `"recordName":"c"`)
+	assert.NotContains(t, stdout, "likes")
+
+	stdout, _, code = run("", "records", "query", "-type", "Post", "-limit", "1")
+	require.Equal(t, 0, code)
+	assert.Equal(t, 2, strings.Count(stdout, "\n"))
+
+	_, stderr, code = run("", "records", "update", "-name", "a", "-field", "likes:INT64=4")
+	require.Equal(t, 0, code, stderr)
+
+	_, stderr, code = run("", "records", "update", "-name", "a", "-tag", "outdated", "-field", "likes:INT64=5")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "a: ")
+	assert.Contains(t, stderr, "CONFLICT")
+
+	stdout, stderr, code = run("", "records", "get", "a")
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "likes=4 title=Hello")
+
+	_, stderr, code = run("", "records", "delete", "a", "b")
+	require.Equal(t, 0, code, stderr)
+
+	_, stderr, code = run("", "records", "get", "a", "c")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "NOT_FOUND")
+	assert.Contains(t, stderr, "1 of 2 records failed")
+
+	_, stderr, code = run("", "records", "delete", "-force", "c", "d")
+	assert.Equal(t, 1, code)
+	assert.NotContains(t, stderr, "c: ")
+	assert.Contains(t, stderr, "d: ")
+}
+
+func TestCLI_Records_PartialFailure(t *testing.T) {
+	// The second request to modify records fails as a whole.
+	var modifies int
+	run, teardown := setupProxy(t, func(srv http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/records/modify") {
+				if modifies++; modifies == 2 {
+					w.Header().Set("content-type", "application/json")
+					w.WriteHeader(http.StatusServiceUnavailable)
+					_, _ = fmt.Fprint(w, `{"serverErrorCode": "TRY_AGAIN_LATER", "reason": "try again"}`)
+					return
+				}
+			}
+			srv.ServeHTTP(w, r)
+		})
+	})
+	defer teardown()
+
+	records := make([]string, icloud.MaxOperationPerRequest+10)
+	for i := range records {
+		records[i] = fmt.Sprintf(`{"recordName": "r%d", "recordType": "Post"}`, i)
+	}
+
+	stdout, stderr, code := run("["+strings.Join(records, ",")+"]", "-output", "ndjson", "records", "create", "-file", "-")
+	assert.Equal(t, 1, code)
+	assert.Equal(t, icloud.MaxOperationPerRequest, strings.Count(stdout, "\n"))
+	assert.Contains(t, stderr, "200 of 210 records written, 10 not sent: ")
+	assert.Contains(t, stderr, "TRY_AGAIN_LATER")
+}
+
+func TestCLI_Records_LookupBatches(t *testing.T) {
+	// Lookups of more records than the server accepts in a single request
+	// fail.
+	var lookups int
+	run, teardown := setupProxy(t, func(srv http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/records/lookup") {
+				lookups++
+
+				b, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				r.Body = io.NopCloser(bytes.NewReader(b))
+
+				var req icloud.LookupRequest
+				require.NoError(t, json.Unmarshal(b, &req))
+				if len(req.Records) > icloud.MaxOperationPerRequest {
+					w.Header().Set("content-type", "application/json")
+					w.WriteHeader(http.StatusBadRequest)
+					_, _ = fmt.Fprint(w, `{"serverErrorCode": "BAD_REQUEST", "reason": "too many records"}`)
+					return
+				}
+			}
+			srv.ServeHTTP(w, r)
+		})
+	})
+	defer teardown()
+
+	var (
+		records = make([]string, icloud.MaxOperationPerRequest+10)
+		names   = make([]string, len(records))
+	)
+	for i := range records {
+		names[i] = fmt.Sprintf("r%d", i)
+		records[i] = fmt.Sprintf(`{"recordName": %q, "recordType": "Post"}`, names[i])
+	}
+
+	_, stderr, code := run("["+strings.Join(records, ",")+"]", "records", "create", "-file", "-")
+	require.Equal(t, 0, code, stderr)
+
+	stdout, stderr, code := run("", append([]string{"-output", "ndjson", "records", "delete"}, names...)...)
+	require.Equal(t, 0, code, stderr)
+	assert.Equal(t, len(names), strings.Count(stdout, "\n"))
+	assert.Equal(t, 2, lookups)
+}
+
+func TestCLI_RecordsImport(t *testing.T) {
+	run, teardown := setup(t)
+	defer teardown()
+
+	dir := t.TempDir()
+	mappingPath := filepath.Join(dir, "mapping.json")
+	require.NoError(t, os.WriteFile(mappingPath, []byte(`{
+		"recordType": "Post",
+		"keyColumns": ["id"],
+		"columns": [
+			{"name": "title", "type": "STRING"},
+			{"name": "likes", "type": "INT64"}
+		]
+	}`), 0600))
+
+	stdin := "id,title,likes\n1,Hello,3\n2,World,many\n"
+
+	stdout, stderr, code := run(stdin, "-output", "json", "records", "import", "-mapping", mappingPath, "-dry-run", "-")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, `"imported": 1`)
+	assert.Contains(t, stderr, "line 3: ")
+	assert.Contains(t, stderr, "1 of 2 rows rejected")
+
+	stdout, _, code = run("", "records", "query", "-type", "Post")
+	require.Equal(t, 0, code)
+	assert.NotContains(t, stdout, "Hello")
+
+	reportPath := filepath.Join(dir, "rejected.csv")
+	_, _, code = run(stdin, "records", "import", "-mapping", mappingPath, "-report", reportPath, "-")
+	assert.Equal(t, 1, code)
+
+	report, err := os.ReadFile(reportPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(report), "2,World,many")
+
+	stdout, _, code = run("", "records", "query", "-type", "Post")
+	require.Equal(t, 0, code)
+	assert.Contains(t, stdout, "likes=3 title=Hello")
+}
+
+func TestCLI_Zones(t *testing.T) {
+	run, teardown := setup(t)
+	defer teardown()
+
+	stdout, stderr, code := run("", "-output", "ndjson", "-database", "private", "zones", "list")
+	require.Equal(t, 0, code, stderr)
+
+	var zone icloud.Zone
+	require.NoError(t, json.Unmarshal([]byte(stdout), &zone))
+	assert.Equal(t, icloud.DefaultZoneName, zone.ZoneID.Name)
+}
+
+func TestCLI_SchemaInfer(t *testing.T) {
+	run, teardown := setup(t)
+	defer teardown()
+
+	stdin := `[
+		{"recordName": "a", "recordType": "Post", "fields": {"title": {"value": "Hello"}, "likes": {"value": 3}}},
+		{"recordName": "b", "recordType": "Post", "fields": {"title": {"value": "World"}, "likes": {"value": 4.5}}}
+	]`
+	_, stderr, code := run(stdin, "records", "create", "-file", "-")
+	require.Equal(t, 0, code, stderr)
+
+	stdout, stderr, code := run("", "schema", "infer", "-types", "Post")
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "RECORD TYPE Post (")
+	assert.Contains(t, stdout, "title           STRING\n")
+	assert.Contains(t, stderr, "Post.likes: inconsistent types")
+
+	_, stderr, code = run("", "schema", "infer")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "specify the record types")
+}
+
+func TestCLI_Whoami(t *testing.T) {
+	run, teardown := setup(t)
+	defer teardown()
+
+	stdout, stderr, code := run("", "whoami")
+	require.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, container)
+	assert.Contains(t, stdout, "development")
+	assert.Regexp(t, "_[0-9a-f]{32}", stdout)
+}
+
+func TestCLI_Usage(t *testing.T) {
+	run, teardown := setup(t)
+	defer teardown()
+
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{nil, 2},
+		{[]string{"-h"}, 0},
+		{[]string{"unknown"}, 2},
+		{[]string{"-output", "xml", "whoami"}, 2},
+		{[]string{"records"}, 2},
+		{[]string{"records", "unknown"}, 2},
+		{[]string{"records", "query"}, 2},
+		{[]string{"records", "query", "-h"}, 0},
+		{[]string{"records", "create", "-file", "x.json", "-type", "Post"}, 2},
+		{[]string{"-environment", "staging", "whoami"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			_, _, code := run("", tt.args...)
+			assert.Equal(t, tt.code, code)
+		})
+	}
+
+	stdout, _, code := run("", "version")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "icloud dev\n", stdout)
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"container": "iCloud.com.example.File",
+		"environment": "production",
+		"keyID": "file-key"
+	}`), 0600))
+
+	env := map[string]string{
+		"ICLOUD_CONFIG":   path,
+		"ICLOUD_KEY_ID":   "env-key",
+		"ICLOUD_DATABASE": "private",
+	}
+
+	cfg, err := loadConfig("", func(key string) string { return env[key] })
+	require.NoError(t, err)
+	assert.Equal(t, "iCloud.com.example.File", cfg.Container)
+	assert.Equal(t, "production", cfg.Environment)
+	assert.Equal(t, "private", cfg.Database)
+	assert.Equal(t, "env-key", cfg.KeyID)
+
+	cfg.override("iCloud.com.example.Flag", "", "")
+	assert.Equal(t, "iCloud.com.example.Flag", cfg.Container)
+	assert.Equal(t, "production", cfg.Environment)
+
+	_, err = loadConfig(filepath.Join(t.TempDir(), "missing.json"), func(string) string { return "" })
+	assert.Error(t, err)
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	sec1, err := x509.MarshalECPrivateKey(privateKey)
+	require.NoError(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+
+	for name, b := range map[string][]byte{
+		"SEC 1":     sec1,
+		"SEC 1 PEM": pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1}),
+		"PKCS 8":    pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			parsed, err := parsePrivateKey(b)
+			require.NoError(t, err)
+			assert.True(t, privateKey.Equal(parsed))
+		})
+	}
+
+	_, err = parsePrivateKey([]byte("not a key"))
+	assert.Error(t, err)
+}
+
+func TestFilterFlag(t *testing.T) {
+	var f filterFlag
+	require.NoError(t, f.Set("likes:INT64>=3"))
+	require.NoError(t, f.Set("title BEGINS_WITH Hello World"))
+	require.NoError(t, f.Set("ratio:DOUBLE != 0.5"))
+	require.NoError(t, f.Set("code=007"))
+	assert.Error(t, f.Set("title"))
+	assert.Error(t, f.Set("title FOO bar"))
+	assert.Error(t, f.Set("likes:INT64=many"))
+
+	assert.Equal(t, filterFlag{
+		{Comparator: icloud.GreaterThanOrEquals, FieldName: "likes", FieldValue: icloud.FieldValue{Type: "INT64", Value: int64(3)}},
+		{Comparator: icloud.BeginsWith, FieldName: "title", FieldValue: icloud.FieldValue{Value: "Hello World"}},
+		{Comparator: icloud.NotEquals, FieldName: "ratio", FieldValue: icloud.FieldValue{Type: "DOUBLE", Value: 0.5}},
+		{Comparator: icloud.Equals, FieldName: "code", FieldValue: icloud.FieldValue{Value: "007"}},
+	}, f)
+}
+
+func TestFieldsFlag(t *testing.T) {
+	var f fieldsFlag
+	require.NoError(t, f.Set("code=007"))
+	require.NoError(t, f.Set("likes:INT64=7"))
+	require.NoError(t, f.Set("ratio:DOUBLE=0.5"))
+	require.NoError(t, f.Set("published:TIMESTAMP=2021-01-01T12:00:00Z"))
+	require.NoError(t, f.Set("title:STRING=a=b"))
+	assert.Error(t, f.Set("likes:INT64=many"))
+	assert.Error(t, f.Set("likes:FOO=1"))
+	assert.Error(t, f.Set("cover:ASSET=a"))
+	assert.Error(t, f.Set("=a"))
+
+	assert.Equal(t, fieldsFlag{
+		{Name: "code", Value: "007"},
+		{Name: "likes", Type: "INT64", Value: int64(7)},
+		{Name: "ratio", Type: "DOUBLE", Value: 0.5},
+		{Name: "published", Type: "TIMESTAMP", Value: int64(1609502400000)},
+		{Name: "title", Type: "STRING", Value: "a=b"},
+	}, f)
+}
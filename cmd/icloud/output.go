@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// format is an output format.
+type format uint8
+
+const (
+	formatTable format = iota
+	formatJSON
+	formatNDJSON
+)
+
+// parseFormat parses the name of an output format.
+func parseFormat(s string) (format, error) {
+	switch s {
+	case "table":
+		return formatTable, nil
+	case "json":
+		return formatJSON, nil
+	case "ndjson":
+		return formatNDJSON, nil
+	}
+	return 0, fmt.Errorf("invalid output format %q", s)
+}
+
+// printer prints values in the configured output format.
+type printer struct {
+	w      io.Writer
+	format format
+}
+
+// print prints the given items. As a table, every item is printed as a row
+// returned by the row function. As JSON, the items are printed as an array. As
+// NDJSON, every item is printed on its own line.
+func (p *printer) print(header []string, items []interface{}, row func(item interface{}) []string) error {
+	switch p.format {
+	case formatJSON:
+		enc := json.NewEncoder(p.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	case formatNDJSON:
+		enc := json.NewEncoder(p.w)
+		for _, item := range items {
+			if err := enc.Encode(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, item := range items {
+		fmt.Fprintln(tw, strings.Join(row(item), "\t"))
+	}
+	return tw.Flush()
+}
+
+// printRecords prints records.
+func (p *printer) printRecords(records []icloud.Record) error {
+	items := make([]interface{}, len(records))
+	for i := range records {
+		items[i] = records[i]
+	}
+
+	return p.print([]string{"NAME", "TYPE", "CHANGE TAG", "FIELDS"}, items, func(item interface{}) []string {
+		record := item.(icloud.Record)
+		return []string{record.Name, record.Type, record.ChangeTag, formatFields(record.Fields)}
+	})
+}
+
+// formatFields formats fields as space separated name=value pairs, sorted by
+// name.
+func formatFields(fields icloud.Fields) string {
+	pairs := make([]string, len(fields))
+	for i, field := range fields {
+		pairs[i] = field.Name + "=" + formatValue(field.Value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, " ")
+}
+
+// formatValue formats a field value. Strings and numbers are printed as is,
+// everything else as JSON.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64, int, int64:
+		return fmt.Sprint(v)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,549 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// records runs the records command.
+func (c *cli) records(ctx context.Context, args []string) error {
+	return c.dispatch(ctx, "records", args, []command{
+		{"query", "query   query records of a type", c.recordsQuery},
+		{"get", "get     fetch records by name", c.recordsGet},
+		{"create", "create  create records", c.recordsCreate},
+		{"update", "update  update records", c.recordsUpdate},
+		{"delete", "delete  delete records by name", c.recordsDelete},
+		{"import", "import  import records from a CSV file", c.recordsImport},
+	})
+}
+
+// recordsQuery runs the records query command.
+func (c *cli) recordsQuery(ctx context.Context, args []string) error {
+	fs := c.flagSet("records query", "")
+	var (
+		recordType = fs.String("type", "", "type of the records to query (required)")
+		zone       = fs.String("zone", "", "name of the zone to query (default zone if empty)")
+		keys       = fs.String("fields", "", "comma separated names of the fields to return (all if empty)")
+		limit      = fs.Int("limit", 0, "maximum number of records to return (all if zero)")
+		filters    filterFlag
+		sorts      sortFlag
+	)
+	fs.Var(&filters, "filter", `filter of the form "field=value" or "field COMPARATOR value", values are strings unless typed like "field:INT64>=10" (repeatable)`)
+	fs.Var(&sorts, "sort", `field to sort by, descending if prefixed with "-" (repeatable)`)
+	if err := parse(fs, args); err != nil {
+		return err
+	} else if *recordType == "" || fs.NArg() > 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+
+	req := icloud.QueryRequest{
+		ZoneID: zoneID(*zone),
+		Query: icloud.Query{
+			RecordType: *recordType,
+			FilterBy:   filters,
+			SortBy:     sorts,
+		},
+		DesiredKeys: desiredKeys(*keys),
+	}
+
+	var records []icloud.Record
+	for {
+		if *limit > 0 {
+			req.ResultsLimit = *limit - len(records)
+		}
+
+		res, err := client.Records.Query(ctx, c.config.database, req)
+		if err != nil {
+			return err
+		}
+		records = append(records, res.Records...)
+
+		if res.ContinuationMarker == "" || (*limit > 0 && len(records) >= *limit) {
+			break
+		}
+		req.ContinuationMarker = res.ContinuationMarker
+	}
+
+	return c.out.printRecords(records)
+}
+
+// recordsGet runs the records get command.
+func (c *cli) recordsGet(ctx context.Context, args []string) error {
+	fs := c.flagSet("records get", "<name>...")
+	var (
+		zone = fs.String("zone", "", "name of the zone the records are in (default zone if empty)")
+		keys = fs.String("fields", "", "comma separated names of the fields to return (all if empty)")
+	)
+	if err := parse(fs, args); err != nil {
+		return err
+	} else if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+
+	req := icloud.LookupRequest{
+		ZoneID:      zoneID(*zone),
+		DesiredKeys: desiredKeys(*keys),
+	}
+	for _, name := range fs.Args() {
+		req.Records = append(req.Records, icloud.Record{Name: name})
+	}
+
+	res, err := client.Records.Lookup(ctx, c.config.database, req)
+	if err != nil {
+		return err
+	}
+
+	return c.report(res.Records)
+}
+
+// recordsCreate runs the records create command.
+func (c *cli) recordsCreate(ctx context.Context, args []string) error {
+	fs := c.flagSet("records create", "")
+	var (
+		recordType = fs.String("type", "", "type of the record")
+		name       = fs.String("name", "", "name of the record (generated if empty)")
+		zone       = fs.String("zone", "", "name of the zone to create the records in (default zone if empty)")
+		file       = fs.String("file", "", `JSON file with a record or an array of records to create ("-" for stdin)`)
+		fields     fieldsFlag
+	)
+	fs.Var(&fields, "field", `field of the form "name=value", values are strings unless typed like "name:INT64=value" (repeatable)`)
+	if err := parse(fs, args); err != nil {
+		return err
+	} else if fs.NArg() > 0 || (*file == "") == (*recordType == "") {
+		fs.Usage()
+		return errUsage
+	}
+
+	records, err := c.readRecords(*file, icloud.Record{
+		Name:   *name,
+		Type:   *recordType,
+		Fields: icloud.Fields(fields),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.modify(ctx, *zone, icloud.Create, records)
+}
+
+// recordsUpdate runs the records update command.
+func (c *cli) recordsUpdate(ctx context.Context, args []string) error {
+	fs := c.flagSet("records update", "")
+	var (
+		name  = fs.String("name", "", "name of the record")
+		tag   = fs.String("tag", "", "change tag of the record (looked up if empty)")
+		zone  = fs.String("zone", "", "name of the zone the records are in (default zone if empty)")
+		file  = fs.String("file", "", `JSON file with a record or an array of records to update ("-" for stdin)`)
+		force = fs.Bool("force", false, "update regardless of conflicts, create the records if they don't exist")
+		field fieldsFlag
+	)
+	fs.Var(&field, "field", `field of the form "name=value", values are strings unless typed like "name:INT64=value" (repeatable)`)
+	if err := parse(fs, args); err != nil {
+		return err
+	} else if fs.NArg() > 0 || (*file == "") == (*name == "") {
+		fs.Usage()
+		return errUsage
+	}
+
+	records, err := c.readRecords(*file, icloud.Record{
+		Name:      *name,
+		ChangeTag: *tag,
+		Fields:    icloud.Fields(field),
+	})
+	if err != nil {
+		return err
+	}
+
+	if *force {
+		return c.modify(ctx, *zone, icloud.ForceUpdate, records)
+	}
+	return c.modify(ctx, *zone, icloud.Update, records)
+}
+
+// recordsDelete runs the records delete command.
+func (c *cli) recordsDelete(ctx context.Context, args []string) error {
+	fs := c.flagSet("records delete", "<name>...")
+	var (
+		zone  = fs.String("zone", "", "name of the zone the records are in (default zone if empty)")
+		force = fs.Bool("force", false, "delete regardless of conflicts")
+	)
+	if err := parse(fs, args); err != nil {
+		return err
+	} else if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	records := make([]icloud.Record, fs.NArg())
+	for i, name := range fs.Args() {
+		records[i].Name = name
+	}
+
+	if *force {
+		return c.modify(ctx, *zone, icloud.ForceDelete, records)
+	}
+	return c.modify(ctx, *zone, icloud.Delete, records)
+}
+
+// modify applies operations of the given type to the records and reports the
+// outcome. Updates and deletes without force look up the change tags of the
+// records which don't have one, so they fail if the records are modified in
+// the meantime.
+func (c *cli) modify(ctx context.Context, zone string, typ icloud.OperationType, records []icloud.Record) error {
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+
+	var failed []icloud.Record
+	if typ == icloud.Update || typ == icloud.Delete {
+		if records, failed, err = c.lookupChangeTags(ctx, zone, records); err != nil {
+			return err
+		}
+	}
+
+	var (
+		results = failed
+		total   = len(failed) + len(records)
+	)
+	for len(records) > 0 {
+		n := len(records)
+		if n > icloud.MaxOperationPerRequest {
+			n = icloud.MaxOperationPerRequest
+		}
+
+		req := icloud.RecordsRequest{
+			ZoneID:     zoneID(zone),
+			Operations: make([]icloud.RecordOperation, n),
+		}
+		for i, record := range records[:n] {
+			req.Operations[i] = icloud.RecordOperation{Type: typ, Record: record}
+		}
+
+		res, err := client.Records.Modify(ctx, c.config.database, req)
+		if err != nil {
+			// Report the outcome of the batches sent before, so it is known
+			// which records have been written.
+			written, printErr := c.printResults(results)
+			if printErr != nil {
+				return printErr
+			}
+			return fmt.Errorf("%d of %d records written, %d not sent: %w", written, total, len(records), err)
+		}
+		results = append(results, res.Records...)
+		records = records[n:]
+	}
+
+	return c.report(results)
+}
+
+// lookupChangeTags sets the change tags of the records which don't have one.
+// Missing record types are set as well. Records which can't be looked up are
+// returned separately, carrying the error.
+func (c *cli) lookupChangeTags(ctx context.Context, zone string, records []icloud.Record) (found, failed []icloud.Record, err error) {
+	var lookup []icloud.Record
+	for _, record := range records {
+		if record.ChangeTag == "" {
+			lookup = append(lookup, icloud.Record{Name: record.Name})
+		}
+	}
+	if len(lookup) == 0 {
+		return records, nil, nil
+	}
+
+	// The records are looked up in batches, like they are written.
+	current := make(map[string]icloud.Record, len(lookup))
+	for len(lookup) > 0 {
+		n := len(lookup)
+		if n > icloud.MaxOperationPerRequest {
+			n = icloud.MaxOperationPerRequest
+		}
+
+		req := icloud.LookupRequest{
+			ZoneID:  zoneID(zone),
+			Records: lookup[:n],
+		}
+
+		res, err := c.client.Records.Lookup(ctx, c.config.database, req)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, record := range res.Records {
+			current[record.Name] = record
+		}
+		lookup = lookup[n:]
+	}
+
+	for _, record := range records {
+		if record.ChangeTag == "" {
+			cur := current[record.Name]
+			if cur.Err() != nil {
+				failed = append(failed, cur)
+				continue
+			}
+			record.ChangeTag = cur.ChangeTag
+			if record.Type == "" {
+				record.Type = cur.Type
+			}
+		}
+		found = append(found, record)
+	}
+
+	return found, failed, nil
+}
+
+// report prints the records which were operated on successfully and the
+// errors of the others.
+func (c *cli) report(records []icloud.Record) error {
+	ok, err := c.printResults(records)
+	if err != nil {
+		return err
+	}
+
+	if n := len(records) - ok; n > 0 {
+		return fmt.Errorf("%d of %d records failed", n, len(records))
+	}
+	return nil
+}
+
+// printResults prints the records which were operated on successfully and the
+// errors of the others. It returns the number of successful records.
+func (c *cli) printResults(records []icloud.Record) (int, error) {
+	var ok []icloud.Record
+	for _, record := range records {
+		if err := record.Err(); err != nil {
+			fmt.Fprintf(c.stderr, "icloud: %s: %s (%s)\n", record.Name, record.Reason, record.ServerErrorCode)
+			continue
+		}
+		ok = append(ok, record)
+	}
+
+	return len(ok), c.out.printRecords(ok)
+}
+
+// readRecords reads a record or an array of records from the JSON file at the
+// given path. If the path is "-", they are read from stdin. If the path is
+// empty, the given record is returned.
+func (c *cli) readRecords(path string, record icloud.Record) ([]icloud.Record, error) {
+	if path == "" {
+		return []icloud.Record{record}, nil
+	}
+
+	var (
+		b   []byte
+		err error
+	)
+	if path == "-" {
+		b, err = io.ReadAll(c.stdin)
+	} else {
+		b, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read records: %w", err)
+	}
+
+	var records []icloud.Record
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '[' {
+		err = json.Unmarshal(b, &records)
+	} else {
+		records = make([]icloud.Record, 1)
+		err = json.Unmarshal(b, &records[0])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse records: %w", err)
+	}
+
+	return records, nil
+}
+
+// zoneID returns the id of the zone with the given name or nil, if the name is
+// empty.
+func zoneID(name string) *icloud.ZoneID {
+	if name == "" {
+		return nil
+	}
+	return &icloud.ZoneID{Name: name}
+}
+
+// desiredKeys splits a comma separated list of field names.
+func desiredKeys(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// parseField parses a field name given on the command line, which is
+// optionally followed by the type of the field, e.g. "likes:INT64", and the
+// value of the field. Values of fields without a type are strings, so values
+// like "007" are kept as they are.
+func parseField(name, value string) (icloud.Field, error) {
+	field := icloud.Field{Name: name, Value: value}
+
+	i := strings.IndexByte(name, ':')
+	if i < 0 {
+		return field, nil
+	}
+
+	typ, err := icloud.ParseFieldType(name[i+1:])
+	if err != nil {
+		return field, err
+	}
+	field.Name, field.Type = name[:i], typ.String()
+
+	switch typ {
+	case icloud.TypeString:
+	case icloud.TypeInt64:
+		field.Value, err = strconv.ParseInt(value, 10, 64)
+	case icloud.TypeDouble:
+		field.Value, err = strconv.ParseFloat(value, 64)
+	case icloud.TypeTimestamp:
+		var t time.Time
+		if t, err = time.Parse(time.RFC3339, value); err == nil {
+			field.Value = t.UnixNano() / int64(time.Millisecond)
+		}
+	default:
+		err = fmt.Errorf("unsupported type %s", typ)
+	}
+	if err != nil {
+		return field, fmt.Errorf("field %s: %w", field.Name, err)
+	}
+
+	return field, nil
+}
+
+// fieldsFlag is a repeatable flag of "name=value" pairs. The name can be
+// followed by the type of the field, see parseField.
+type fieldsFlag icloud.Fields
+
+// String implements flag.Value.
+func (f *fieldsFlag) String() string {
+	return formatFields(icloud.Fields(*f))
+}
+
+// Set implements flag.Value.
+func (f *fieldsFlag) Set(s string) error {
+	i := strings.IndexByte(s, '=')
+	if i < 1 {
+		return errors.New(`must be of the form "name=value" or "name:TYPE=value"`)
+	}
+
+	field, err := parseField(s[:i], s[i+1:])
+	if err != nil {
+		return err
+	}
+	*f = append(*f, field)
+
+	return nil
+}
+
+var (
+	// operatorFilterExpr matches filters using comparison operators, e.g.
+	// "count:INT64>=10".
+	operatorFilterExpr = regexp.MustCompile(`^\s*(\w+(?::\w+)?)\s*(<=|>=|!=|=|<|>)\s*(.*)$`)
+	// comparatorFilterExpr matches filters using comparators, e.g.
+	// "title BEGINS_WITH foo".
+	comparatorFilterExpr = regexp.MustCompile(`^\s*(\w+(?::\w+)?)\s+([A-Z_]+)\s+(.*)$`)
+)
+
+// filterComparators maps comparison operators to comparators.
+var filterComparators = map[string]icloud.Comparator{
+	"=":  icloud.Equals,
+	"!=": icloud.NotEquals,
+	"<":  icloud.LessThan,
+	"<=": icloud.LessThanOrEquals,
+	">":  icloud.GreaterThan,
+	">=": icloud.GreaterThanOrEquals,
+}
+
+// filterFlag is a repeatable flag of query filters.
+type filterFlag []icloud.Filter
+
+// String implements flag.Value.
+func (f *filterFlag) String() string {
+	filters := make([]string, len(*f))
+	for i, filter := range *f {
+		filters[i] = fmt.Sprintf("%s %s %v", filter.FieldName, filter.Comparator, filter.FieldValue.Value)
+	}
+	return strings.Join(filters, ", ")
+}
+
+// Set implements flag.Value.
+func (f *filterFlag) Set(s string) error {
+	var comparator icloud.Comparator
+	m := operatorFilterExpr.FindStringSubmatch(s)
+	if m != nil {
+		comparator = filterComparators[m[2]]
+	} else if m = comparatorFilterExpr.FindStringSubmatch(s); m != nil {
+		if err := json.Unmarshal([]byte(strconv.Quote(m[2])), &comparator); err != nil {
+			return err
+		} else if comparator == icloud.ComparatorUnknown {
+			return fmt.Errorf("unknown comparator %q", m[2])
+		}
+	} else {
+		return errors.New(`must be of the form "field=value" or "field COMPARATOR value"`)
+	}
+
+	field, err := parseField(m[1], m[3])
+	if err != nil {
+		return err
+	}
+
+	*f = append(*f, icloud.Filter{
+		Comparator: comparator,
+		FieldName:  field.Name,
+		FieldValue: icloud.FieldValue{Type: field.Type, Value: field.Value},
+	})
+
+	return nil
+}
+
+// sortFlag is a repeatable flag of fields to sort by.
+type sortFlag []icloud.Sort
+
+// String implements flag.Value.
+func (f *sortFlag) String() string {
+	fields := make([]string, len(*f))
+	for i, sort := range *f {
+		fields[i] = sort.FieldName
+		if !sort.Ascending {
+			fields[i] = "-" + fields[i]
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+// Set implements flag.Value.
+func (f *sortFlag) Set(s string) error {
+	name := strings.TrimPrefix(s, "-")
+	if name == "" {
+		return errors.New("missing field name")
+	}
+
+	*f = append(*f, icloud.Sort{FieldName: name, Ascending: name == s})
+
+	return nil
+}
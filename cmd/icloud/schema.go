@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+	"github.com/lukasmalkmus/icloud-go/icloud/infer"
+)
+
+// schema runs the schema command.
+func (c *cli) schema(ctx context.Context, args []string) error {
+	return c.dispatch(ctx, "schema", args, []command{
+		{"infer", "infer  infer a draft schema from sampled records", c.schemaInfer},
+	})
+}
+
+// schemaInfer runs the schema infer command. The draft schema is written to
+// stdout in the .ckdb format, regardless of the output format.
+func (c *cli) schemaInfer(ctx context.Context, args []string) error {
+	fs := c.flagSet("schema infer", "")
+	var (
+		types      = fs.String("types", "", "comma separated record types to query (discovered from the zone changes if empty)")
+		zones      = fs.String("zones", "", "comma separated names of the zones to sample (default zone if empty)")
+		limit      = fs.Int("limit", 0, fmt.Sprintf("maximum number of records sampled per record type and zone (%d if zero)", infer.DefaultLimit))
+		reportPath = fs.String("report", "", "file to write the report of inconsistent field types to (stderr if empty)")
+	)
+	if err := parse(fs, args); err != nil {
+		return err
+	} else if fs.NArg() > 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+
+	opts := infer.Options{
+		Database:    c.config.database,
+		RecordTypes: desiredKeys(*types),
+		Limit:       *limit,
+	}
+	for _, name := range desiredKeys(*zones) {
+		opts.Zones = append(opts.Zones, icloud.ZoneID{Name: name})
+	}
+
+	res, err := infer.Sample(ctx, client, opts)
+	if err != nil {
+		return err
+	}
+
+	if err = c.writeInferReport(res, *reportPath); err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(c.stdout, res.Schema.String())
+	return err
+}
+
+// writeInferReport writes the report of a schema inference to the file at the
+// given path or, if the path is empty, to stderr.
+func (c *cli) writeInferReport(res *infer.Result, path string) error {
+	if path == "" {
+		return res.WriteReport(c.stderr)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err = res.WriteReport(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
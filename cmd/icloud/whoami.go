@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// whoami runs the whoami command.
+func (c *cli) whoami(ctx context.Context, args []string) error {
+	fs := c.flagSet("whoami", "")
+	if err := parse(fs, args); err != nil {
+		return err
+	} else if fs.NArg() > 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+
+	user, err := client.Users.Current(ctx)
+	if err != nil {
+		return err
+	}
+
+	header := []string{"CONTAINER", "ENVIRONMENT", "KEY ID", "USER RECORD NAME"}
+	return c.out.print(header, []interface{}{*user}, func(item interface{}) []string {
+		return []string{c.config.Container, c.config.Environment, c.config.KeyID, item.(icloud.User).RecordName}
+	})
+}
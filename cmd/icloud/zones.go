@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// zones runs the zones command.
+func (c *cli) zones(ctx context.Context, args []string) error {
+	return c.dispatch(ctx, "zones", args, []command{
+		{"list", "list  list the zones of a database", c.zonesList},
+	})
+}
+
+// zonesList runs the zones list command.
+func (c *cli) zonesList(ctx context.Context, args []string) error {
+	fs := c.flagSet("zones list", "")
+	if err := parse(fs, args); err != nil {
+		return err
+	} else if fs.NArg() > 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	client, err := c.connect()
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Zones.List(ctx, c.config.database)
+	if err != nil {
+		return err
+	}
+
+	items := make([]interface{}, len(res.Zones))
+	for i := range res.Zones {
+		items[i] = res.Zones[i]
+	}
+
+	return c.out.print([]string{"NAME", "OWNER", "ATOMIC", "SYNC TOKEN"}, items, func(item interface{}) []string {
+		zone := item.(icloud.Zone)
+		return []string{zone.ZoneID.Name, zone.ZoneID.OwnerName, strconv.FormatBool(zone.Atomic), zone.SyncToken}
+	})
+}
@@ -0,0 +1,150 @@
+package icloud
+
+import (
+	"context"
+	"io"
+	"net/http"
+)
+
+// Asset is the value of a field of type ASSETID. To set an asset on a record,
+// the asset data must be uploaded first. The returned Asset is then used as
+// the value of the field.
+type Asset struct {
+	// FileChecksum is the checksum of the asset data.
+	FileChecksum string `json:"fileChecksum,omitempty"`
+	// Size of the asset data in bytes.
+	Size int64 `json:"size,omitempty"`
+	// ReferenceChecksum is the checksum of the wrapping key.
+	ReferenceChecksum string `json:"referenceChecksum,omitempty"`
+	// WrappingKey is the key used to encrypt the asset data.
+	WrappingKey string `json:"wrappingKey,omitempty"`
+	// Receipt of the upload. Only present on freshly uploaded assets.
+	Receipt string `json:"receipt,omitempty"`
+	// DownloadURL of the asset data. Only present on assets returned by the
+	// server as part of a record.
+	DownloadURL string `json:"downloadURL,omitempty"`
+}
+
+// UploadRequest is the request to the upload operation of the AssetsService.
+type UploadRequest struct {
+	// ZoneID of the zone the records are in. If not set, the default zone is
+	// used.
+	ZoneID *ZoneID `json:"zoneID,omitempty"`
+	// Tokens to request, one per asset to upload.
+	Tokens []UploadToken `json:"tokens,omitempty"`
+}
+
+// UploadToken identifies the field of a record an asset is uploaded for.
+type UploadToken struct {
+	// RecordName of the record the asset belongs to. If not set, the server
+	// generates one.
+	RecordName string `json:"recordName,omitempty"`
+	// RecordType of the record the asset belongs to.
+	RecordType string `json:"recordType,omitempty"`
+	// FieldName of the field the asset is stored in.
+	FieldName string `json:"fieldName"`
+	// URL to upload the asset data to. Only set on tokens returned by the
+	// server.
+	URL string `json:"url,omitempty"`
+}
+
+// UploadResponse is the response recevied from the upload operation of the
+// AssetsService.
+type UploadResponse struct {
+	Tokens []UploadToken `json:"tokens,omitempty"`
+}
+
+// RereferenceRequest is the request to the rereference operation of the
+// AssetsService.
+type RereferenceRequest struct {
+	// ZoneID of the zone the records are in. If not set, the default zone is
+	// used.
+	ZoneID *ZoneID `json:"zoneID,omitempty"`
+	// Assets to reference, one per field to attach an existing asset to.
+	Assets []AssetReference `json:"assets,omitempty"`
+}
+
+// AssetReference attaches an existing asset to the field of a record.
+type AssetReference struct {
+	// RecordName of the record the asset is attached to.
+	RecordName string `json:"recordName,omitempty"`
+	// RecordType of the record the asset is attached to.
+	RecordType string `json:"recordType,omitempty"`
+	// FieldName of the field the asset is stored in.
+	FieldName string `json:"fieldName"`
+	// Asset to reference. In a request, it is the value of an ASSETID field
+	// as returned by the server. In a response, it is the Asset to set as the
+	// value of the field.
+	Asset
+}
+
+// RereferenceResponse is the response received from the rereference operation
+// of the AssetsService.
+type RereferenceResponse struct {
+	Assets []AssetReference `json:"assets,omitempty"`
+}
+
+// AssetsService handles communication with the asset related operations of
+// the CloudKit Web Services API.
+//
+// CloudKit Web Services Reference: https://developer.apple.com/library/archive/documentation/DataManagement/Conceptual/CloudKitWebServicesReference/UploadAssets.html
+type AssetsService service
+
+// Upload requests the urls to upload asset data to. The data itself is
+// uploaded using UploadData.
+func (s *AssetsService) Upload(ctx context.Context, database Database, req UploadRequest) (*UploadResponse, error) {
+	path := "/" + database.String() + s.basePath + "/upload"
+
+	var res UploadResponse
+	if err := s.client.call(ctx, http.MethodPost, path, req, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// Rereference attaches existing assets to the fields of other records without
+// uploading their data again. The returned assets are set as the values of the
+// fields when modifying the records.
+func (s *AssetsService) Rereference(ctx context.Context, database Database, req RereferenceRequest) (*RereferenceResponse, error) {
+	path := "/" + database.String() + s.basePath + "/rereference"
+
+	var res RereferenceResponse
+	if err := s.client.call(ctx, http.MethodPost, path, req, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// UploadData uploads the asset data read from r to the url of an UploadToken
+// and returns the Asset to set as the value of the tokens field.
+func (s *AssetsService) UploadData(ctx context.Context, url string, r io.Reader) (*Asset, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, r)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("content-type", "application/octet-stream")
+	req.Header.Set("user-agent", s.client.userAgent)
+
+	var res struct {
+		SingleFile Asset `json:"singleFile"`
+	}
+	if err = s.client.do(req, &res); err != nil {
+		return nil, err
+	}
+
+	return &res.SingleFile, nil
+}
+
+// Download writes the data of the asset to w. Only assets returned by the
+// server as part of a record can be downloaded.
+func (s *AssetsService) Download(ctx context.Context, asset Asset, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, asset.DownloadURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("user-agent", s.client.userAgent)
+
+	return s.client.do(req, w)
+}
@@ -0,0 +1,87 @@
+package icloud
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssetsService_Rereference(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+
+		b, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"zoneID": {"zoneName": "Posts"},
+			"assets": [
+				{
+					"recordName": "b",
+					"recordType": "Post",
+					"fieldName": "image",
+					"fileChecksum": "AbC1",
+					"size": 1024,
+					"referenceChecksum": "dEf2",
+					"wrappingKey": "gHi3"
+				}
+			]
+		}`, string(b))
+
+		_, _ = w.Write([]byte(`{
+			"assets": [
+				{
+					"recordName": "b",
+					"recordType": "Post",
+					"fieldName": "image",
+					"fileChecksum": "AbC1",
+					"size": 1024,
+					"referenceChecksum": "dEf2",
+					"wrappingKey": "gHi3",
+					"receipt": "jKl4"
+				}
+			]
+		}`))
+	}
+
+	client, teardown := setup(t, "/database/1/"+container+"/development/private/assets/rereference", hf)
+	defer teardown()
+
+	res, err := client.Assets.Rereference(context.Background(), Private, RereferenceRequest{
+		ZoneID: &ZoneID{Name: "Posts"},
+		Assets: []AssetReference{
+			{
+				RecordName: "b",
+				RecordType: "Post",
+				FieldName:  "image",
+				Asset: Asset{
+					FileChecksum:      "AbC1",
+					Size:              1024,
+					ReferenceChecksum: "dEf2",
+					WrappingKey:       "gHi3",
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, &RereferenceResponse{
+		Assets: []AssetReference{
+			{
+				RecordName: "b",
+				RecordType: "Post",
+				FieldName:  "image",
+				Asset: Asset{
+					FileChecksum:      "AbC1",
+					Size:              1024,
+					ReferenceChecksum: "dEf2",
+					WrappingKey:       "gHi3",
+					Receipt:           "jKl4",
+				},
+			},
+		},
+	}, res)
+}
@@ -21,8 +21,8 @@ import (
 )
 
 const (
-	baseURL    = "https://api.apple-cloudkit.com"
-	apiVersion = 1
+	defaultBaseURL    = "https://api.apple-cloudkit.com"
+	defaultAPIVersion = 1
 )
 
 // service is the base service used by all CloudKit Web Service APIs.
@@ -47,35 +47,49 @@ func DefaultHTTPClient() *http.Client {
 
 // Client provides the CloudKit Web Services API operations.
 type Client struct {
+	container      string
+	environment    Environment
+	rootURL        *url.URL
+	apiVersion     int
 	baseURL        *url.URL
+	deviceURL      *url.URL
 	userAgent      string
 	keyID          string
 	privateKey     *ecdsa.PrivateKey
 	strictDecoding bool
+	hooks          []Hooks
+	schema         Schema
 
 	httpClient *http.Client
 
+	Assets  *AssetsService
 	Records *RecordsService
+	Tokens  *TokensService
+	Users   *UsersService
+	Zones   *ZonesService
 }
 
 // NewClient returns a new CloudKit Web Services API client.
 func NewClient(container, keyID string, privateKey *ecdsa.PrivateKey, environment Environment, options ...Option) (*Client, error) {
-	urlStr := fmt.Sprintf("%s/database/%d/%s/%s", baseURL, apiVersion, container, environment)
-	u, err := url.ParseRequestURI(urlStr)
-	if err != nil {
-		return nil, err
-	}
-
 	client := &Client{
-		baseURL:    u,
-		userAgent:  "icloud-go",
-		keyID:      keyID,
-		privateKey: privateKey,
+		container:   container,
+		environment: environment,
+		userAgent:   "icloud-go",
+		keyID:       keyID,
+		privateKey:  privateKey,
 
 		httpClient: DefaultHTTPClient(),
 	}
 
-	client.Records = &RecordsService{client, "/records/modify"}
+	if err := client.setBaseURL(defaultBaseURL, defaultAPIVersion); err != nil {
+		return nil, err
+	}
+
+	client.Assets = &AssetsService{client, "/assets"}
+	client.Records = &RecordsService{client, "/records"}
+	client.Tokens = &TokensService{client, "/tokens"}
+	client.Users = &UsersService{client, "/users"}
+	client.Zones = &ZonesService{client, "/zones"}
 
 	// Apply supplied options.
 	if err := client.Options(options...); err != nil {
@@ -85,6 +99,34 @@ func NewClient(container, keyID string, privateKey *ecdsa.PrivateKey, environmen
 	return client, nil
 }
 
+// setBaseURL builds the urls of the database and device API of the clients
+// container and environment from the given base url and API version.
+func (c *Client) setBaseURL(baseURL string, apiVersion int) error {
+	rootURL, err := url.ParseRequestURI(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return err
+	}
+
+	urlStr := fmt.Sprintf("%s/database/%d/%s/%s", rootURL, apiVersion, c.container, c.environment)
+	u, err := url.ParseRequestURI(urlStr)
+	if err != nil {
+		return err
+	}
+
+	urlStr = fmt.Sprintf("%s/device/%d/%s/%s", rootURL, apiVersion, c.container, c.environment)
+	deviceURL, err := url.ParseRequestURI(urlStr)
+	if err != nil {
+		return err
+	}
+
+	c.rootURL = rootURL
+	c.apiVersion = apiVersion
+	c.baseURL = u
+	c.deviceURL = deviceURL
+
+	return nil
+}
+
 // Options applies Options to the Client.
 func (c *Client) Options(options ...Option) error {
 	for _, option := range options {
@@ -95,25 +137,62 @@ func (c *Client) Options(options ...Option) error {
 	return nil
 }
 
-// call creates a new API request and executes it. The response body is JSON
-// decoded or directly written to v, depending on v being an io.Writer or not.
+// Container returns the identifier of the container the client accesses.
+func (c *Client) Container() string {
+	return c.container
+}
+
+// Environment returns the environment the client accesses.
+func (c *Client) Environment() Environment {
+	return c.environment
+}
+
+// call creates a new request to the database API and executes it. The response
+// body is JSON decoded or directly written to v, depending on v being an
+// io.Writer or not. Errors are wrapped in a *RequestError.
 func (c *Client) call(ctx context.Context, method, endpoint string, body, v interface{}) error {
-	req, err := c.newRequest(ctx, method, endpoint, body)
+	return c.callURL(ctx, c.baseURL, method, endpoint, body, v)
+}
+
+// callDevice is like call but the request is made to the device API.
+func (c *Client) callDevice(ctx context.Context, method, endpoint string, body, v interface{}) error {
+	return c.callURL(ctx, c.deviceURL, method, endpoint, body, v)
+}
+
+// callURL is like call but the endpoint is relative to the given API url.
+func (c *Client) callURL(ctx context.Context, apiURL *url.URL, method, endpoint string, body, v interface{}) error {
+	req, err := c.newRequestURL(ctx, apiURL, method, endpoint, body)
 	if err != nil {
 		return err
 	}
-	return c.do(req, v)
+
+	rc := newRequestContext(endpoint, body)
+	if err = c.doContext(req, v, &rc); err != nil {
+		return err
+	}
+
+	if oe, ok := v.(operationErrorer); ok {
+		oe.setRequestContext(rc)
+	}
+
+	return nil
 }
 
-// newRequest creates an API request. The given body will be included as a JSON
-// encoded request body.
+// newRequest creates a request to the database API. The given body will be
+// included as a JSON encoded request body.
 func (c *Client) newRequest(ctx context.Context, method, endpoint string, body interface{}) (*http.Request, error) {
-	endpoint = path.Join(c.baseURL.Path, endpoint)
+	return c.newRequestURL(ctx, c.baseURL, method, endpoint, body)
+}
+
+// newRequestURL is like newRequest but the endpoint is relative to the given
+// API url.
+func (c *Client) newRequestURL(ctx context.Context, apiURL *url.URL, method, endpoint string, body interface{}) (*http.Request, error) {
+	endpoint = path.Join(apiURL.Path, endpoint)
 	rel, err := url.ParseRequestURI(endpoint)
 	if err != nil {
 		return nil, err
 	}
-	u := c.baseURL.ResolveReference(rel)
+	u := apiURL.ResolveReference(rel)
 
 	var (
 		buf bytes.Buffer
@@ -144,20 +223,73 @@ func (c *Client) newRequest(ctx context.Context, method, endpoint string, body i
 
 // do sends an API request and returns the API response. The response body is
 // JSON decoded or directly written to v, depending on v being an io.Writer or
-// not.
+// not. The hooks of the client are invoked around the request.
 func (c *Client) do(req *http.Request, v interface{}) error {
+	return c.doContext(req, v, nil)
+}
+
+// doContext is like do but wraps errors with the given request context, if
+// not nil, before they are passed to the hooks.
+func (c *Client) doContext(req *http.Request, v interface{}, rc *requestContext) error {
+	if len(c.hooks) == 0 {
+		return rc.wrapErr(c.send(req, v, nil, false))
+	}
+
+	var includeBodies bool
+	for _, hooks := range c.hooks {
+		includeBodies = includeBodies || hooks.IncludeBodies
+	}
+
+	var (
+		ctx  = req.Context()
+		info = c.requestInfo(req, includeBodies)
+		res  = new(ResponseInfo)
+	)
+
+	for _, hooks := range c.hooks {
+		if hooks.BeforeRequest != nil {
+			ctx = hooks.BeforeRequest(ctx, info)
+		}
+	}
+
+	start := time.Now()
+	res.Err = rc.wrapErr(c.send(req.WithContext(ctx), v, res, includeBodies))
+	res.Duration = time.Since(start)
+
+	for _, hooks := range c.hooks {
+		if hooks.AfterResponse != nil {
+			hooks.AfterResponse(ctx, info, res)
+		}
+	}
+
+	return res.Err
+}
+
+// send sends an API request and handles the API response as described by do.
+// If info is not nil, it is populated with details about the response. JSON
+// formatted response bodies are only included if requested.
+func (c *Client) send(req *http.Request, v interface{}, info *ResponseInfo, includeBody bool) error {
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
+	if info != nil {
+		info.StatusCode = resp.StatusCode
+		info.RequestID = resp.Header.Get("x-apple-request-uuid")
+
+		if includeBody && strings.HasPrefix(resp.Header.Get("content-type"), "application/json") {
+			var body bytes.Buffer
+			resp.Body = readCloser{io.TeeReader(resp.Body, &body), resp.Body}
+			defer func() { info.Body = body.Bytes() }()
+		}
+	}
+
 	if statusCode := resp.StatusCode; statusCode >= 400 {
 		// Handle a generic HTTP error if the response is not JSON formatted.
 		if val := resp.Header.Get("content-type"); !strings.HasPrefix(val, "application/json") {
-			return Error{
-				Reason: http.StatusText(statusCode),
-			}
+			return newHTTPError(req, resp, nil, nil)
 		}
 
 		// For error handling, we want to have access to the raw request body to
@@ -171,7 +303,7 @@ func (c *Client) do(req *http.Request, v interface{}) error {
 		// response.
 		var errResp Error
 		if err = dec.Decode(&errResp); err != nil {
-			return fmt.Errorf("error decoding %d error response: %w", statusCode, err)
+			return newHTTPError(req, resp, buf.Bytes(), fmt.Errorf("error decoding %d error response: %w", statusCode, err))
 		}
 
 		// In case something went wrong, include the raw response and hope for
@@ -181,6 +313,10 @@ func (c *Client) do(req *http.Request, v interface{}) error {
 			errResp.Reason = s
 		}
 
+		if info != nil {
+			info.ErrorCodes = []ErrorCode{errResp.Code}
+		}
+
 		return errResp
 	}
 
@@ -194,17 +330,30 @@ func (c *Client) do(req *http.Request, v interface{}) error {
 		if c.strictDecoding {
 			dec.DisallowUnknownFields()
 		}
-		return dec.Decode(v)
+		if err = dec.Decode(v); err != nil {
+			return err
+		}
+
+		if ec, ok := v.(errorCoder); ok && info != nil {
+			info.ErrorCodes = ec.errorCodes()
+		}
 	}
 
 	return nil
 }
 
+// readCloser combines an io.Reader with the io.Closer of another reader.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
 // signRequest signs the request with a signature of format date:body:path where
 // date is the ISO8601 representation of the current date, body the base64
 // string encoded SHA-256 hash of the request body and path the API path without
-// base url and query parameters. The SHA-256 hash.Hash must be precomputed
-// before calling this function.
+// base url and query parameters. If the base url has a path (e.g. when talking
+// to a proxy), it is not part of the signed path. The SHA-256 hash.Hash must be
+// precomputed before calling this function.
 func (c *Client) signRequest(req *http.Request, bodyHash hash.Hash) error {
 	var (
 		buf     bytes.Buffer
@@ -218,7 +367,7 @@ func (c *Client) signRequest(req *http.Request, bodyHash hash.Hash) error {
 	_, _ = buf.WriteString(encodedBody)
 	_ = buf.WriteByte(':')
 
-	_, _ = buf.WriteString(req.URL.Path)
+	_, _ = buf.WriteString(strings.TrimPrefix(req.URL.Path, c.rootURL.Path))
 
 	// Hash the signature message.
 	h := sha256.New()
@@ -49,14 +49,24 @@ func TestNewClient(t *testing.T) {
 	require.NotNil(t, client)
 
 	// Are endpoints/resources present?
+	assert.NotNil(t, client.Assets)
 	assert.NotNil(t, client.Records)
+	assert.NotNil(t, client.Tokens)
+	assert.NotNil(t, client.Users)
+	assert.NotNil(t, client.Zones)
 
 	// Is default configuration present?
 	expURL := "https://api.apple-cloudkit.com/database/1/iCloud.com.lukasmalkmus.Example-App/development"
 	assert.Equal(t, expURL, client.baseURL.String())
+	expURL = "https://api.apple-cloudkit.com/device/1/iCloud.com.lukasmalkmus.Example-App/development"
+	assert.Equal(t, expURL, client.deviceURL.String())
+	assert.Equal(t, 1, client.apiVersion)
 	assert.NotEmpty(t, client.userAgent)
 	assert.False(t, client.strictDecoding)
 	assert.NotNil(t, client.httpClient)
+
+	assert.Equal(t, container, client.Container())
+	assert.Equal(t, environment, client.Environment())
 }
 
 // func TestClient_newRequest_BadURL(t *testing.T) {
@@ -173,7 +183,7 @@ func TestClient_do_RedirectLoop(t *testing.T) {
 // setup sets up a test HTTP server along with a client that is configured to
 // talk to that test server. Tests should pass a handler function which provides
 // the response for the API method being tested.
-func setup(t *testing.T, path string, handler http.HandlerFunc) (*Client, func()) { //nolint:unparam // ...
+func setup(t *testing.T, path string, handler http.HandlerFunc) (*Client, func()) {
 	t.Helper()
 
 	r := http.NewServeMux()
@@ -193,15 +203,9 @@ func setup(t *testing.T, path string, handler http.HandlerFunc) (*Client, func()
 	}))
 	srv := httptest.NewServer(r)
 
-	urlStr := fmt.Sprintf("%s/database/%d/%s/%s", srv.URL, apiVersion, container, environment)
-	baseURL, err := url.ParseRequestURI(urlStr)
-	require.NoError(t, err)
-
-	client, err := NewClient(container, keyID, privateKey, environment, SetHTTPClient(srv.Client()), SetStrictDecoding())
+	client, err := NewClient(container, keyID, privateKey, environment, SetBaseURL(srv.URL), SetHTTPClient(srv.Client()), SetStrictDecoding())
 	require.NoError(t, err)
 
-	client.baseURL = baseURL
-
 	return client, func() { srv.Close() }
 }
 
@@ -1,8 +1,14 @@
 package icloud
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
 	"time"
 )
 
@@ -53,48 +59,129 @@ func (ec ErrorCode) Description() string {
 	return errorCodeDescriptions[ec]
 }
 
+// MarshalJSON implements json.Marshaler. It is in place to marshal the
+// ErrorCode to its string representation because that's what the server
+// returns.
+func (ec ErrorCode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ec.String())
+}
+
 // UnmarshalJSON implements json.Unmarshaler. It is in place to unmarshal the
-// ErrorCode from the string representation the server returns.
+// ErrorCode from the string representation the server returns. Codes unknown
+// to the package are unmarshalled as Unknown, so responses stay decodable when
+// the server introduces new codes.
 func (ec *ErrorCode) UnmarshalJSON(b []byte) error {
 	var s string
 	if err := json.Unmarshal(b, &s); err != nil {
 		return err
 	}
 
+	*ec, _ = parseErrorCode(s)
+
+	return nil
+}
+
+// parseErrorCode returns the ErrorCode with the given string representation.
+// Codes unknown to the package are returned as Unknown, along with their string
+// representation. For known codes, the returned string is empty.
+func parseErrorCode(s string) (ErrorCode, string) {
 	switch s {
+	case Unknown.String():
+		return Unknown, ""
 	case AccessDenied.String():
-		*ec = AccessDenied
+		return AccessDenied, ""
 	case AtomicError.String():
-		*ec = AtomicError
+		return AtomicError, ""
 	case AuthenticationFailed.String():
-		*ec = AuthenticationFailed
+		return AuthenticationFailed, ""
 	case AuthenticationRequired.String():
-		*ec = AuthenticationRequired
+		return AuthenticationRequired, ""
 	case BadRequest.String():
-		*ec = BadRequest
+		return BadRequest, ""
 	case Conflict.String():
-		*ec = Conflict
+		return Conflict, ""
 	case Exists.String():
-		*ec = Exists
+		return Exists, ""
 	case InternalError.String():
-		*ec = InternalError
+		return InternalError, ""
 	case NotFound.String():
-		*ec = NotFound
+		return NotFound, ""
 	case QuotaExceeded.String():
-		*ec = QuotaExceeded
+		return QuotaExceeded, ""
 	case Throttled.String():
-		*ec = Throttled
+		return Throttled, ""
 	case TryAgainLater.String():
-		*ec = TryAgainLater
+		return TryAgainLater, ""
 	case ValidatingReferenceError.String():
-		*ec = ValidatingReferenceError
+		return ValidatingReferenceError, ""
 	case ZoneNotFound.String():
-		*ec = ZoneNotFound
+		return ZoneNotFound, ""
 	default:
-		return fmt.Errorf("unknown error code %q", s)
+		return Unknown, s
 	}
+}
 
-	return nil
+// Sentinel errors matching an Error with the corresponding server error code,
+// e.g. errors.Is(err, icloud.ErrConflict).
+var (
+	ErrAccessDenied             error = codeError(AccessDenied)
+	ErrAtomicError              error = codeError(AtomicError)
+	ErrAuthenticationFailed     error = codeError(AuthenticationFailed)
+	ErrAuthenticationRequired   error = codeError(AuthenticationRequired)
+	ErrBadRequest               error = codeError(BadRequest)
+	ErrConflict                 error = codeError(Conflict)
+	ErrExists                   error = codeError(Exists)
+	ErrInternalError            error = codeError(InternalError)
+	ErrNotFound                 error = codeError(NotFound)
+	ErrQuotaExceeded            error = codeError(QuotaExceeded)
+	ErrThrottled                error = codeError(Throttled)
+	ErrTryAgainLater            error = codeError(TryAgainLater)
+	ErrValidatingReferenceError error = codeError(ValidatingReferenceError)
+	ErrZoneNotFound             error = codeError(ZoneNotFound)
+)
+
+// codeError is the type of the sentinel errors of the server error codes.
+type codeError ErrorCode
+
+// Error implements the error interface.
+func (e codeError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrorCode(e), ErrorCode(e).Description())
+}
+
+// IsRetryable returns true, if err is a failure the server suggested to retry
+// the request after some time on, a temporary failure of a proxy in front of
+// the server or if the request timed out.
+func IsRetryable(err error) bool {
+	var apiErr Error
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter > 0 || apiErr.Code == Throttled || apiErr.Code == TryAgainLater
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// IsAuthError returns true, if err is a failure to authenticate the request or
+// to access the requested resource.
+func IsAuthError(err error) bool {
+	return errors.Is(err, ErrAuthenticationFailed) ||
+		errors.Is(err, ErrAuthenticationRequired) ||
+		errors.Is(err, ErrAccessDenied)
+}
+
+// IsNotFound returns true, if err is a failure because the requested record,
+// zone or other resource doesn't exist.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrZoneNotFound)
 }
 
 // Error is the generic error response returned on non 2xx HTTP status codes.
@@ -104,8 +191,51 @@ type Error struct {
 	// RetryAfter specifies the suggested time to wait before trying the
 	// operation again. If not set, the operation can't be retried.
 	RetryAfter time.Duration `json:"retryAfter"`
-	// Code is the server error code.
+	// Code is the server error code. Codes unknown to the package are
+	// Unknown, use Raw to get them.
 	Code ErrorCode `json:"serverErrorCode"`
+	// UUID uniquely identifies the error. Include it when reporting issues
+	// to Apple.
+	UUID string `json:"uuid,omitempty"`
+	// RedirectURL is the url to sign in at, if the Code is
+	// AuthenticationRequired.
+	RedirectURL string `json:"redirectURL,omitempty"`
+
+	rawCode string
+}
+
+// Raw returns the server error code as sent by the server. It is the string
+// representation of Code, unless the server sent a code unknown to the
+// package.
+func (e Error) Raw() string {
+	return rawString(e.rawCode, e.Code)
+}
+
+// rawString returns the raw string representation of an enum value as sent by
+// the server, if the value is unknown to the package, and the string
+// representation of v otherwise.
+func rawString(raw string, v fmt.Stringer) string {
+	if raw != "" {
+		return raw
+	}
+	return v.String()
+}
+
+// marshalString returns the string an enum value is marshalled as. Like
+// rawString, values unknown to the package are marshalled as they were
+// unmarshalled. Zero values without a raw string are returned as an empty
+// string, so they are omitted.
+func marshalString(raw string, v fmt.Stringer, zero bool) string {
+	if raw == "" && zero {
+		return ""
+	}
+	return rawString(raw, v)
+}
+
+// Is makes errors.Is match the error against the sentinel error of its code.
+func (e Error) Is(target error) bool {
+	code, ok := target.(codeError)
+	return ok && e.Code == ErrorCode(code)
 }
 
 // Error implements the error interface.
@@ -124,6 +254,7 @@ func (e *Error) UnmarshalJSON(b []byte) error {
 		*LocalError
 
 		RetryAfter string `json:"retryAfter"`
+		Code       string `json:"serverErrorCode"`
 	}{
 		LocalError: (*LocalError)(e),
 	}
@@ -132,6 +263,8 @@ func (e *Error) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
+	e.Code, e.rawCode = parseErrorCode(localError.Code)
+
 	// If the "retry after" duration is not specified, parsing it is omitted.
 	var err error
 	if s := localError.RetryAfter; s != "" {
@@ -140,3 +273,226 @@ func (e *Error) UnmarshalJSON(b []byte) error {
 
 	return err
 }
+
+// MaxHTTPErrorBody is the maximum number of bytes of the response body kept by
+// an HTTPError.
+const MaxHTTPErrorBody = 1024
+
+// httpErrorHeaders are the response headers kept by an HTTPError.
+var httpErrorHeaders = []string{"Content-Type", "Location", "Retry-After", "Server", "Via"}
+
+// HTTPError is returned for error responses that are not CloudKit errors, e.g.
+// the HTML error page of a proxy in front of the server, and for error
+// responses that can't be decoded.
+type HTTPError struct {
+	// Method of the request.
+	Method string
+	// Path of the request url.
+	Path string
+	// StatusCode of the response.
+	StatusCode int
+	// Header holds the Content-Type, Location, Retry-After, Server and Via
+	// headers of the response, if present.
+	Header http.Header
+	// Body holds the beginning of the response body, up to MaxHTTPErrorBody
+	// bytes.
+	Body []byte
+	// RequestID is the identifier CloudKit assigned to the request. Empty, if
+	// the request didn't reach CloudKit.
+	RequestID string
+	// Err is the error decoding the response body, if it claims to be a JSON
+	// formatted CloudKit error.
+	Err error
+}
+
+// newHTTPError returns an HTTPError for the response to req. The already read
+// beginning of the response body is passed as prefix.
+func newHTTPError(req *http.Request, resp *http.Response, prefix []byte, err error) *HTTPError {
+	httpErr := &HTTPError{
+		Method:     req.Method,
+		Path:       req.URL.Path,
+		StatusCode: resp.StatusCode,
+		Header:     make(http.Header),
+		RequestID:  resp.Header.Get("x-apple-request-uuid"),
+		Err:        err,
+	}
+
+	for _, key := range httpErrorHeaders {
+		if values := resp.Header.Values(key); len(values) > 0 {
+			httpErr.Header[key] = values
+		}
+	}
+
+	// An error reading the body only shortens the excerpt.
+	body := io.MultiReader(bytes.NewReader(prefix), resp.Body)
+	httpErr.Body, _ = io.ReadAll(io.LimitReader(body, MaxHTTPErrorBody))
+
+	return httpErr
+}
+
+// Error implements the error interface.
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the error decoding the response body, if any.
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// RequestError adds the context of a failed request or of a failed operation
+// of a batch request to the underlying error. The underlying Error of failures
+// reported by the server stays reachable by errors.As.
+type RequestError struct {
+	// Endpoint of the request, e.g. "records/modify".
+	Endpoint string
+	// Database the request was made against.
+	Database Database
+	// ZoneID of the zone the request was made against. Nil, if the request
+	// wasn't made against a specific zone.
+	ZoneID *ZoneID
+	// Index of the failed operation in the batch request. It is -1, if the
+	// request failed as a whole.
+	Index int
+	// RecordName of the record the failed operation operated on, if any.
+	RecordName string
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements the error interface. The description of the server error
+// code is part of the message.
+func (e *RequestError) Error() string {
+	var details []string
+	if e.Database != 0 {
+		details = append(details, e.Database.String()+" database")
+	}
+	if e.ZoneID != nil {
+		details = append(details, fmt.Sprintf("zone %q", e.ZoneID.Name))
+	}
+	if e.Index >= 0 {
+		details = append(details, fmt.Sprintf("operation %d", e.Index))
+	}
+	if e.RecordName != "" {
+		details = append(details, fmt.Sprintf("record %q", e.RecordName))
+	}
+
+	msg := e.Endpoint
+	if len(details) > 0 {
+		msg += " (" + strings.Join(details, ", ") + ")"
+	}
+	msg += ": " + e.Err.Error()
+
+	var apiErr Error
+	if errors.As(e.Err, &apiErr) && apiErr.Code != Unknown {
+		msg += " [" + codeError(apiErr.Code).Error() + "]"
+	}
+
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// requestContext is the context errors of a request are wrapped with.
+type requestContext struct {
+	endpoint string
+	database Database
+	zoneID   *ZoneID
+}
+
+// parsePath returns the database and the endpoint of a request to the given
+// path relative to the database or device url, e.g. "/public/records/modify".
+// The database is zero for endpoints that don't belong to a database, like
+// "/tokens/create".
+func parsePath(path string) (Database, string) {
+	for _, db := range []Database{Public, Private, Shared} {
+		if endpoint := strings.TrimPrefix(path, "/"+db.String()+"/"); endpoint != path {
+			return db, endpoint
+		}
+	}
+	return 0, strings.TrimPrefix(path, "/")
+}
+
+// newRequestContext returns the context of a request to the given path
+// relative to the database url, e.g. "/public/records/modify", with the given
+// body.
+func newRequestContext(path string, body interface{}) requestContext {
+	var rc requestContext
+	rc.database, rc.endpoint = parsePath(path)
+
+	switch req := body.(type) {
+	case RecordsRequest:
+		rc.zoneID = req.ZoneID
+	case LookupRequest:
+		rc.zoneID = req.ZoneID
+	case QueryRequest:
+		rc.zoneID = req.ZoneID
+	case ChangesRequest:
+		rc.zoneID = &req.ZoneID
+	case UploadRequest:
+		rc.zoneID = req.ZoneID
+	case RereferenceRequest:
+		rc.zoneID = req.ZoneID
+	}
+
+	return rc
+}
+
+// wrap wraps err with the context of the request. The index and record name
+// identify a failed operation of a batch request.
+func (rc requestContext) wrap(err error, index int, recordName string) error {
+	return &RequestError{
+		Endpoint:   rc.endpoint,
+		Database:   rc.database,
+		ZoneID:     rc.zoneID,
+		Index:      index,
+		RecordName: recordName,
+		Err:        err,
+	}
+}
+
+// wrapErr wraps err with the context of the request, if both are not nil.
+func (rc *requestContext) wrapErr(err error) error {
+	if rc == nil || err == nil {
+		return err
+	}
+	return rc.wrap(err, -1, "")
+}
+
+// operationErrorer is implemented by responses that wrap the errors of
+// individual operations with the context of the request.
+type operationErrorer interface {
+	setRequestContext(rc requestContext)
+}
+
+// ErrFieldNotFound is returned by the typed getters of Fields if no field with
+// the requested name exists.
+var ErrFieldNotFound = errors.New("field not found")
+
+// FieldTypeError is returned by the typed getters of Fields if the type or the
+// value of a field doesn't match the requested type.
+type FieldTypeError struct {
+	// Name of the field.
+	Name string
+	// Type of the field. Empty, if not set.
+	Type string
+	// Value of the field.
+	Value interface{}
+	// Expected is the requested type.
+	Expected FieldType
+}
+
+// Error implements error.
+func (e *FieldTypeError) Error() string {
+	if e.Type != "" {
+		return fmt.Sprintf("field %q: expected %s, got %s", e.Name, e.Expected, e.Type)
+	}
+	return fmt.Sprintf("field %q: expected %s, got value of type %T", e.Name, e.Expected, e.Value)
+}
@@ -0,0 +1,268 @@
+package icloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCode_JSON(t *testing.T) {
+	b, err := json.Marshal(Throttled)
+	require.NoError(t, err)
+	assert.Equal(t, `"THROTTLED"`, string(b))
+
+	var code ErrorCode
+	require.NoError(t, json.Unmarshal(b, &code))
+	assert.Equal(t, Throttled, code)
+
+	require.NoError(t, json.Unmarshal([]byte(`"SOMETHING_NEW"`), &code))
+	assert.Equal(t, Unknown, code)
+}
+
+func TestError_UnknownCode(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprint(w, `{"reason": "Something new", "serverErrorCode": "SOMETHING_NEW"}`)
+	}
+
+	client, teardown := setup(t, "/", hf)
+	defer teardown()
+
+	req, err := client.newRequest(context.Background(), http.MethodGet, "/", nil)
+	require.NoError(t, err)
+
+	err = client.do(req, nil)
+	require.Error(t, err)
+
+	var apiErr Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "Something new", apiErr.Reason)
+	assert.Equal(t, Unknown, apiErr.Code)
+	assert.Equal(t, "SOMETHING_NEW", apiErr.Raw())
+
+	assert.Equal(t, "THROTTLED", Error{Code: Throttled}.Raw())
+}
+
+func TestRecordsResponse_UnknownCode(t *testing.T) {
+	var res RecordsResponse
+	err := json.Unmarshal([]byte(`{"records": [
+		{"recordName": "a", "recordType": "Post"},
+		{"recordName": "b", "reason": "Conflict", "serverErrorCode": "CONFLICT"},
+		{"recordName": "c", "serverErrorCode": "SOMETHING_NEW"}
+	]}`), &res)
+	require.NoError(t, err)
+	require.Len(t, res.Records, 3)
+
+	assert.Equal(t, Record{Name: "a", Type: "Post"}, res.Records[0])
+	assert.NoError(t, res.Records[0].Err())
+
+	assert.Equal(t, Error{Reason: "Conflict", Code: Conflict}, res.Records[1].Err())
+
+	var apiErr Error
+	require.True(t, errors.As(res.Records[2].Err(), &apiErr))
+	assert.Equal(t, Unknown, apiErr.Code)
+	assert.Equal(t, "SOMETHING_NEW", apiErr.Raw())
+
+	assert.Equal(t, []ErrorCode{Conflict, Unknown}, res.errorCodes())
+}
+
+func TestOperationType_JSON(t *testing.T) {
+	var op RecordOperation
+	require.NoError(t, json.Unmarshal([]byte(`{"operationType": "forceReplace"}`), &op))
+	assert.Equal(t, ForceReplace, op.Type)
+
+	assert.Equal(t, "forceReplace", op.RawType())
+
+	require.NoError(t, json.Unmarshal([]byte(`{"operationType": "somethingNew"}`), &op))
+	assert.Equal(t, OperationUnknown, op.Type)
+	assert.Equal(t, "somethingNew", op.RawType())
+}
+
+func TestError_Is(t *testing.T) {
+	err := fmt.Errorf("save post: %w", Error{Reason: "changed", Code: Conflict})
+
+	assert.True(t, errors.Is(err, ErrConflict))
+	assert.False(t, errors.Is(err, ErrExists))
+	assert.False(t, errors.Is(errors.New("conflict"), ErrConflict))
+
+	assert.EqualError(t, ErrConflict, "CONFLICT: "+Conflict.Description())
+}
+
+func TestErrorClassification(t *testing.T) {
+	tests := []struct {
+		err       error
+		retryable bool
+		auth      bool
+		notFound  bool
+	}{
+		{err: Error{Code: Throttled}, retryable: true},
+		{err: Error{Code: TryAgainLater}, retryable: true},
+		{err: Error{Code: InternalError, RetryAfter: time.Second}, retryable: true},
+		{err: Error{Code: InternalError}},
+		{err: &net.DNSError{IsTimeout: true}, retryable: true},
+		{err: Error{Code: AuthenticationFailed}, auth: true},
+		{err: Error{Code: AuthenticationRequired}, auth: true},
+		{err: Error{Code: AccessDenied}, auth: true},
+		{err: Error{Code: NotFound}, notFound: true},
+		{err: &RequestError{Index: -1, Err: Error{Code: ZoneNotFound}}, notFound: true},
+		{err: errors.New("boom")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tt.retryable, IsRetryable(tt.err))
+			assert.Equal(t, tt.auth, IsAuthError(tt.err))
+			assert.Equal(t, tt.notFound, IsNotFound(tt.err))
+		})
+	}
+}
+
+func TestRequestError(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/lookup") {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"reason": "zone does not exist", "serverErrorCode": "ZONE_NOT_FOUND"}`)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"records": [
+			{"recordName": "a", "recordType": "Post", "recordChangeTag": "1"},
+			{"recordName": "b", "reason": "record changed", "serverErrorCode": "CONFLICT"}
+		]}`)
+	}
+
+	client, teardown := setup(t, "/", hf)
+	defer teardown()
+
+	ctx := context.Background()
+	zoneID := &ZoneID{Name: "Posts"}
+
+	_, err := client.Records.Lookup(ctx, Private, LookupRequest{
+		ZoneID:  zoneID,
+		Records: []Record{{Name: "a"}},
+	})
+	require.Error(t, err)
+	assert.EqualError(t, err, `records/lookup (private database, zone "Posts"): API error: zone does not exist [ZONE_NOT_FOUND: `+ZoneNotFound.Description()+`]`)
+	assert.True(t, errors.Is(err, ErrZoneNotFound))
+
+	var reqErr *RequestError
+	require.True(t, errors.As(err, &reqErr))
+	assert.Equal(t, "records/lookup", reqErr.Endpoint)
+	assert.Equal(t, Private, reqErr.Database)
+	assert.Equal(t, zoneID, reqErr.ZoneID)
+	assert.Equal(t, -1, reqErr.Index)
+
+	var apiErr Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, ZoneNotFound, apiErr.Code)
+
+	res, err := client.Records.Modify(ctx, Private, RecordsRequest{
+		ZoneID: zoneID,
+		Operations: []RecordOperation{
+			{Type: Create, Record: Record{Name: "a", Type: "Post"}},
+			{Type: Update, Record: Record{Name: "b", Type: "Post", ChangeTag: "1"}},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Records, 2)
+
+	assert.NoError(t, res.Err(0))
+	err = res.Err(1)
+	assert.EqualError(t, err, `records/modify (private database, zone "Posts", operation 1, record "b"): API error: record changed [CONFLICT: `+Conflict.Description()+`]`)
+	assert.True(t, errors.Is(err, ErrConflict))
+}
+
+func TestHTTPError(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "text/html")
+		w.Header().Set("retry-after", "30")
+		w.Header().Set("x-apple-request-uuid", "3F2C5C8E")
+		w.Header().Set("x-internal", "secret")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = fmt.Fprint(w, "<html>"+strings.Repeat("unavailable ", 200)+"</html>")
+	}
+
+	client, teardown := setup(t, "/", hf)
+	defer teardown()
+
+	req, err := client.newRequest(context.Background(), http.MethodPost, "/public/records/modify", nil)
+	require.NoError(t, err)
+
+	err = client.do(req, nil)
+	require.Error(t, err)
+	assert.EqualError(t, err, "POST "+req.URL.Path+": 503 Service Unavailable")
+	assert.True(t, IsRetryable(err))
+
+	var httpErr *HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusServiceUnavailable, httpErr.StatusCode)
+	assert.Equal(t, "3F2C5C8E", httpErr.RequestID)
+	assert.Equal(t, http.Header{
+		"Content-Type": {"text/html"},
+		"Retry-After":  {"30"},
+	}, httpErr.Header)
+	assert.Len(t, httpErr.Body, MaxHTTPErrorBody)
+	assert.True(t, strings.HasPrefix(string(httpErr.Body), "<html>unavailable"))
+}
+
+func TestHTTPError_UndecodableError(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprint(w, `{"reason": `)
+	}
+
+	client, teardown := setup(t, "/", hf)
+	defer teardown()
+
+	req, err := client.newRequest(context.Background(), http.MethodGet, "/", nil)
+	require.NoError(t, err)
+
+	err = client.do(req, nil)
+	require.Error(t, err)
+
+	var httpErr *HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusBadRequest, httpErr.StatusCode)
+	assert.Equal(t, `{"reason": `, string(httpErr.Body))
+	assert.EqualError(t, httpErr.Err, "error decoding 400 error response: unexpected EOF")
+	assert.False(t, IsRetryable(err))
+}
+
+func TestError_RedirectURL(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = fmt.Fprint(w, `{
+			"uuid": "3F2C5C8E-8C1B-4E2B-9A7D-1B2C3D4E5F60",
+			"serverErrorCode": "AUTHENTICATION_REQUIRED",
+			"reason": "request needs authorization",
+			"redirectURL": "https://idmsa.apple.com/IDMSWebAuth/auth"
+		}`)
+	}
+
+	client, teardown := setup(t, "/", hf)
+	defer teardown()
+
+	_, err := client.Users.Current(context.Background())
+	require.Error(t, err)
+	assert.True(t, IsAuthError(err))
+
+	var apiErr Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, Error{
+		Reason:      "request needs authorization",
+		Code:        AuthenticationRequired,
+		UUID:        "3F2C5C8E-8C1B-4E2B-9A7D-1B2C3D4E5F60",
+		RedirectURL: "https://idmsa.apple.com/IDMSWebAuth/auth",
+	}, apiErr)
+}
@@ -0,0 +1,280 @@
+package icloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//go:generate ../bin/stringer -type=FieldType,ReferenceAction -linecomment -output=fieldtype_string.go
+
+// FieldType is the type of the value of a field.
+type FieldType uint8
+
+// All available field types. Every type has a list variant holding multiple
+// values of that type.
+const (
+	// TypeUnknown is the type of values whose type is unknown to the package.
+	TypeUnknown FieldType = iota // UNKNOWN
+
+	TypeAsset         // ASSET
+	TypeAssetID       // ASSETID
+	TypeBytes         // BYTES
+	TypeDouble        // DOUBLE
+	TypeInt64         // INT64
+	TypeLocation      // LOCATION
+	TypeReference     // REFERENCE
+	TypeString        // STRING
+	TypeTimestamp     // TIMESTAMP
+	TypeAssetList     // ASSET_LIST
+	TypeAssetIDList   // ASSETID_LIST
+	TypeBytesList     // BYTES_LIST
+	TypeDoubleList    // DOUBLE_LIST
+	TypeInt64List     // INT64_LIST
+	TypeLocationList  // LOCATION_LIST
+	TypeReferenceList // REFERENCE_LIST
+	TypeStringList    // STRING_LIST
+	TypeTimestampList // TIMESTAMP_LIST
+	// TypeUnknownList is the type of an empty list.
+	TypeUnknownList // UNKNOWN_LIST
+)
+
+// IsList returns true, if the type is a list type.
+func (ft FieldType) IsList() bool {
+	return ft >= TypeAssetList && ft <= TypeUnknownList
+}
+
+// Elem returns the type of the elements of a list type. It returns TypeUnknown
+// for TypeUnknownList and for types that are not a list type.
+func (ft FieldType) Elem() FieldType {
+	if !ft.IsList() || ft == TypeUnknownList {
+		return TypeUnknown
+	}
+	return ft - TypeAssetList + TypeAsset
+}
+
+// List returns the list type with elements of the type. It returns
+// TypeUnknown for TypeUnknown and for types that are a list type already.
+func (ft FieldType) List() FieldType {
+	if ft < TypeAsset || ft > TypeTimestamp {
+		return TypeUnknown
+	}
+	return ft - TypeAsset + TypeAssetList
+}
+
+// MarshalJSON implements json.Marshaler. It is in place to marshal the
+// FieldType to its string representation because that's what the server
+// expects.
+func (ft FieldType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ft.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It is in place to unmarshal the
+// FieldType from the string representation the server returns. Types unknown
+// to the package are unmarshalled as TypeUnknown, so responses stay decodable
+// when the server introduces new types.
+func (ft *FieldType) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	*ft, _ = parseFieldType(s)
+
+	return nil
+}
+
+// ParseFieldType parses the string representation of a field type, e.g.
+// "STRING_LIST". It is case insensitive. Unlike unmarshalling a FieldType, it
+// returns an error for types unknown to the package.
+func ParseFieldType(s string) (FieldType, error) {
+	ft, _ := parseFieldType(strings.ToUpper(s))
+	if ft == TypeUnknown {
+		return TypeUnknown, fmt.Errorf("unknown field type %q", s)
+	}
+	return ft, nil
+}
+
+// parseFieldType returns the FieldType with the given string representation.
+// Types unknown to the package are returned as TypeUnknown, along with their
+// string representation. For known types, the returned string is empty.
+func parseFieldType(s string) (FieldType, string) {
+	switch s {
+	case TypeAsset.String():
+		return TypeAsset, ""
+	case TypeAssetID.String():
+		return TypeAssetID, ""
+	case TypeBytes.String():
+		return TypeBytes, ""
+	case TypeDouble.String():
+		return TypeDouble, ""
+	case TypeInt64.String():
+		return TypeInt64, ""
+	case TypeLocation.String():
+		return TypeLocation, ""
+	case TypeReference.String():
+		return TypeReference, ""
+	case TypeString.String():
+		return TypeString, ""
+	case TypeTimestamp.String():
+		return TypeTimestamp, ""
+	case TypeAssetList.String():
+		return TypeAssetList, ""
+	case TypeAssetIDList.String():
+		return TypeAssetIDList, ""
+	case TypeBytesList.String():
+		return TypeBytesList, ""
+	case TypeDoubleList.String():
+		return TypeDoubleList, ""
+	case TypeInt64List.String():
+		return TypeInt64List, ""
+	case TypeLocationList.String():
+		return TypeLocationList, ""
+	case TypeReferenceList.String():
+		return TypeReferenceList, ""
+	case TypeStringList.String():
+		return TypeStringList, ""
+	case TypeTimestampList.String():
+		return TypeTimestampList, ""
+	case TypeUnknownList.String():
+		return TypeUnknownList, ""
+	}
+	return TypeUnknown, s
+}
+
+// Location is the value of a field of type TypeLocation.
+type Location struct {
+	// Latitude in degrees.
+	Latitude float64 `json:"latitude"`
+	// Longitude in degrees.
+	Longitude float64 `json:"longitude"`
+	// HorizontalAccuracy is the radius of uncertainty for the location, in
+	// meters.
+	HorizontalAccuracy float64 `json:"horizontalAccuracy,omitempty"`
+	// VerticalAccuracy is the accuracy of the altitude, in meters.
+	VerticalAccuracy float64 `json:"verticalAccuracy,omitempty"`
+	// Altitude in meters.
+	Altitude float64 `json:"altitude,omitempty"`
+	// Speed in meters per second.
+	Speed float64 `json:"speed,omitempty"`
+	// Course is the direction of travel, in degrees relative to due north.
+	Course float64 `json:"course,omitempty"`
+	// Timestamp at which the location was determined, in milliseconds since
+	// the Unix epoch.
+	Timestamp int64 `json:"timestamp,omitempty"`
+}
+
+// ReferenceAction specifies what happens to a record when the record it
+// references is deleted.
+type ReferenceAction uint8
+
+// All available reference actions.
+const (
+	// ActionUnknown is the action of references whose action is unknown to
+	// the package.
+	ActionUnknown ReferenceAction = iota // UNKNOWN
+	// ActionNone leaves the referencing record untouched.
+	ActionNone // NONE
+	// ActionDeleteSelf deletes the referencing record as well.
+	ActionDeleteSelf // DELETE_SELF
+	// ActionValidate makes the server verify that the referenced record
+	// exists when the referencing record is written.
+	ActionValidate // VALIDATE
+)
+
+// MarshalJSON implements json.Marshaler. It is in place to marshal the
+// ReferenceAction to its string representation because that's what the server
+// expects.
+func (ra ReferenceAction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ra.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It is in place to unmarshal the
+// ReferenceAction from the string representation the server returns. Actions
+// unknown to the package are unmarshalled as ActionUnknown, so responses stay
+// decodable when the server introduces new actions.
+func (ra *ReferenceAction) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	*ra, _ = parseReferenceAction(s)
+
+	return nil
+}
+
+// parseReferenceAction returns the ReferenceAction with the given string
+// representation. Actions unknown to the package are returned as
+// ActionUnknown, along with their string representation. For known actions,
+// the returned string is empty.
+func parseReferenceAction(s string) (ReferenceAction, string) {
+	switch s {
+	case ActionNone.String():
+		return ActionNone, ""
+	case ActionDeleteSelf.String():
+		return ActionDeleteSelf, ""
+	case ActionValidate.String():
+		return ActionValidate, ""
+	}
+	return ActionUnknown, s
+}
+
+// Reference is the value of a field of type TypeReference.
+type Reference struct {
+	// RecordName of the referenced record.
+	RecordName string `json:"recordName"`
+	// ZoneID of the zone the referenced record is in. If not set, the zone of
+	// the referencing record is assumed.
+	ZoneID *ZoneID `json:"zoneID,omitempty"`
+	// Action to take when the referenced record is deleted. Actions unknown
+	// to the package are ActionUnknown, use RawAction to get them.
+	Action ReferenceAction `json:"action,omitempty"`
+
+	rawAction string
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It is in place to retain actions
+// unknown to the package.
+func (r *Reference) UnmarshalJSON(b []byte) error {
+	type LocalReference Reference
+	localReference := struct {
+		*LocalReference
+
+		Action string `json:"action"`
+	}{
+		LocalReference: (*LocalReference)(r),
+	}
+
+	if err := json.Unmarshal(b, &localReference); err != nil {
+		return err
+	}
+
+	r.Action, r.rawAction = parseReferenceAction(localReference.Action)
+
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. It is in place to marshal actions
+// unknown to the package as they were unmarshalled.
+func (r Reference) MarshalJSON() ([]byte, error) {
+	type LocalReference Reference
+	localReference := struct {
+		LocalReference
+
+		Action string `json:"action,omitempty"`
+	}{
+		LocalReference: LocalReference(r),
+
+		Action: marshalString(r.rawAction, r.Action, r.Action == ActionUnknown),
+	}
+
+	return json.Marshal(localReference)
+}
+
+// RawAction returns the action as sent by the server. It is the string
+// representation of Action, unless the server sent an action unknown to the
+// package.
+func (r Reference) RawAction() string {
+	return rawString(r.rawAction, r.Action)
+}
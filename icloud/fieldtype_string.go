@@ -0,0 +1,62 @@
+// Code generated by "stringer -type=FieldType,ReferenceAction -linecomment -output=fieldtype_string.go"; DO NOT EDIT.
+
+package icloud
+
+import "strconv"
+
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[TypeUnknown-0]
+	_ = x[TypeAsset-1]
+	_ = x[TypeAssetID-2]
+	_ = x[TypeBytes-3]
+	_ = x[TypeDouble-4]
+	_ = x[TypeInt64-5]
+	_ = x[TypeLocation-6]
+	_ = x[TypeReference-7]
+	_ = x[TypeString-8]
+	_ = x[TypeTimestamp-9]
+	_ = x[TypeAssetList-10]
+	_ = x[TypeAssetIDList-11]
+	_ = x[TypeBytesList-12]
+	_ = x[TypeDoubleList-13]
+	_ = x[TypeInt64List-14]
+	_ = x[TypeLocationList-15]
+	_ = x[TypeReferenceList-16]
+	_ = x[TypeStringList-17]
+	_ = x[TypeTimestampList-18]
+	_ = x[TypeUnknownList-19]
+}
+
+const _FieldType_name = "UNKNOWNASSETASSETIDBYTESDOUBLEINT64LOCATIONREFERENCESTRINGTIMESTAMPASSET_LISTASSETID_LISTBYTES_LISTDOUBLE_LISTINT64_LISTLOCATION_LISTREFERENCE_LISTSTRING_LISTTIMESTAMP_LISTUNKNOWN_LIST"
+
+var _FieldType_index = [...]uint8{0, 7, 12, 19, 24, 30, 35, 43, 52, 58, 67, 77, 89, 99, 110, 120, 133, 147, 158, 172, 184}
+
+func (i FieldType) String() string {
+	if i >= FieldType(len(_FieldType_index)-1) {
+		return "FieldType(" + strconv.FormatInt(int64(i), 10) + ")"
+	}
+	return _FieldType_name[_FieldType_index[i]:_FieldType_index[i+1]]
+}
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[ActionUnknown-0]
+	_ = x[ActionNone-1]
+	_ = x[ActionDeleteSelf-2]
+	_ = x[ActionValidate-3]
+}
+
+const _ReferenceAction_name = "UNKNOWNNONEDELETE_SELFVALIDATE"
+
+var _ReferenceAction_index = [...]uint8{0, 7, 11, 22, 30}
+
+func (i ReferenceAction) String() string {
+	if i >= ReferenceAction(len(_ReferenceAction_index)-1) {
+		return "ReferenceAction(" + strconv.FormatInt(int64(i), 10) + ")"
+	}
+	return _ReferenceAction_name[_ReferenceAction_index[i]:_ReferenceAction_index[i+1]]
+}
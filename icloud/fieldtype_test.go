@@ -0,0 +1,77 @@
+package icloud
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldType_List(t *testing.T) {
+	for ft := TypeAsset; ft <= TypeTimestamp; ft++ {
+		assert.False(t, ft.IsList(), ft)
+		assert.True(t, ft.List().IsList(), ft)
+		assert.Equal(t, ft, ft.List().Elem(), ft)
+		assert.Equal(t, ft.String()+"_LIST", ft.List().String())
+	}
+
+	assert.True(t, TypeUnknownList.IsList())
+	assert.Equal(t, TypeUnknown, TypeUnknownList.Elem())
+	assert.Equal(t, TypeUnknown, TypeStringList.List())
+	assert.Equal(t, TypeUnknown, TypeString.Elem())
+}
+
+func TestFieldType_JSON(t *testing.T) {
+	for ft := TypeAsset; ft <= TypeUnknownList; ft++ {
+		b, err := json.Marshal(ft)
+		require.NoError(t, err)
+
+		var got FieldType
+		require.NoError(t, json.Unmarshal(b, &got))
+		assert.Equal(t, ft, got)
+	}
+
+	var ft FieldType
+	require.NoError(t, json.Unmarshal([]byte(`"FOO"`), &ft))
+	assert.Equal(t, TypeUnknown, ft)
+}
+
+func TestParseFieldType(t *testing.T) {
+	ft, err := ParseFieldType("string_list")
+	require.NoError(t, err)
+	assert.Equal(t, TypeStringList, ft)
+
+	_, err = ParseFieldType("FOO")
+	assert.EqualError(t, err, `unknown field type "FOO"`)
+
+	_, err = ParseFieldType(TypeUnknown.String())
+	assert.Error(t, err)
+}
+
+func TestReference_JSON(t *testing.T) {
+	ref := Reference{
+		RecordName: "a",
+		ZoneID:     &ZoneID{Name: "Posts"},
+		Action:     ActionDeleteSelf,
+	}
+
+	b, err := json.Marshal(ref)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"recordName":"a","zoneID":{"zoneName":"Posts"},"action":"DELETE_SELF"}`, string(b))
+
+	var got Reference
+	require.NoError(t, json.Unmarshal(b, &got))
+	assert.Equal(t, ref, got)
+
+	b, err = json.Marshal(Reference{RecordName: "a"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"recordName":"a"}`, string(b))
+
+	assert.Equal(t, "DELETE_SELF", got.RawAction())
+
+	var unknown Reference
+	require.NoError(t, json.Unmarshal([]byte(`{"recordName":"a","action":"CASCADE"}`), &unknown))
+	assert.Equal(t, ActionUnknown, unknown.Action)
+	assert.Equal(t, "CASCADE", unknown.RawAction())
+}
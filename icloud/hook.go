@@ -0,0 +1,190 @@
+package icloud
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RequestInfo describes a request to the CloudKit Web Services API.
+type RequestInfo struct {
+	// Container the client is configured for.
+	Container string
+	// Environment the client is configured for.
+	Environment Environment
+	// Method of the request.
+	Method string
+	// Path of the request. For API requests, it is relative to the database
+	// or device url of the container and environment, e.g.
+	// "/public/records/modify" or "/tokens/create".
+	Path string
+	// Database the request is made against. Zero, if the request is not made
+	// against a database, e.g. when creating tokens or uploading asset data.
+	Database Database
+	// Endpoint of the request, e.g. "records/modify". Empty, if the request is
+	// not an API request, e.g. when uploading asset data.
+	Endpoint string
+	// Operations is the number of operations of a modify request.
+	Operations int
+	// OperationTypes are the types of the operations of a modify request, in
+	// the order they appear in the request.
+	OperationTypes []OperationType
+	// Body of the request. Only set if requested by the hooks.
+	Body []byte
+}
+
+// ResponseInfo describes the outcome of a request to the CloudKit Web Services
+// API.
+type ResponseInfo struct {
+	// StatusCode of the response. Zero, if no response was received.
+	StatusCode int
+	// Duration of the request, including decoding the response.
+	Duration time.Duration
+	// RequestID is the identifier CloudKit assigned to the request.
+	RequestID string
+	// ErrorCodes are the server error codes of the response. This includes
+	// the error codes of failed operations on individual records or zones.
+	ErrorCodes []ErrorCode
+	// Err is the error returned to the caller, if any.
+	Err error
+	// Body of the response. Only set if requested by the hooks and if the
+	// response is JSON formatted.
+	Body []byte
+}
+
+// Hooks are invoked for every request the client sends. They must be safe for
+// concurrent use.
+type Hooks struct {
+	// BeforeRequest is called before a request is sent. The returned context
+	// is used for the request and passed to AfterResponse.
+	BeforeRequest func(ctx context.Context, req *RequestInfo) context.Context
+	// AfterResponse is called after a request completed, failed or not.
+	AfterResponse func(ctx context.Context, req *RequestInfo, res *ResponseInfo)
+	// IncludeBodies populates the bodies of RequestInfo and ResponseInfo.
+	IncludeBodies bool
+}
+
+// errorCoder is implemented by responses that report errors of individual
+// operations.
+type errorCoder interface {
+	errorCodes() []ErrorCode
+}
+
+// requestInfo describes the request.
+func (c *Client) requestInfo(req *http.Request, includeBody bool) *RequestInfo {
+	info := &RequestInfo{
+		Container:   c.container,
+		Environment: c.environment,
+		Method:      req.Method,
+		Path:        req.URL.Path,
+	}
+
+	for _, apiURL := range []*url.URL{c.baseURL, c.deviceURL} {
+		if p := strings.TrimPrefix(req.URL.Path, apiURL.Path); p != req.URL.Path {
+			info.Path = p
+			info.Database, info.Endpoint = parsePath(p)
+			break
+		}
+	}
+
+	if req.GetBody == nil {
+		return info
+	}
+
+	r, err := req.GetBody()
+	if err != nil {
+		return info
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return info
+	}
+
+	var body struct {
+		Operations []json.RawMessage `json:"operations"`
+	}
+	if json.Unmarshal(b, &body) == nil {
+		info.Operations = len(body.Operations)
+		for _, raw := range body.Operations {
+			var op struct {
+				Type OperationType `json:"operationType"`
+			}
+			if json.Unmarshal(raw, &op) == nil && op.Type != OperationUnknown {
+				info.OperationTypes = append(info.OperationTypes, op.Type)
+			}
+		}
+	}
+	if includeBody {
+		info.Body = b
+	}
+
+	return info
+}
+
+// Logger is a minimal structured logger. The key value pairs passed to Log
+// are alternating keys and values.
+type Logger interface {
+	Log(ctx context.Context, msg string, keyvals ...interface{})
+}
+
+// The LoggerFunc type is an adapter to allow the use of ordinary functions as
+// Logger.
+type LoggerFunc func(ctx context.Context, msg string, keyvals ...interface{})
+
+// Log calls f(ctx, msg, keyvals...).
+func (f LoggerFunc) Log(ctx context.Context, msg string, keyvals ...interface{}) {
+	f(ctx, msg, keyvals...)
+}
+
+// logHooks returns Hooks which log every request to the given logger.
+func logHooks(logger Logger, includeBodies bool) Hooks {
+	return Hooks{
+		AfterResponse: func(ctx context.Context, req *RequestInfo, res *ResponseInfo) {
+			keyvals := []interface{}{
+				"method", req.Method,
+				"path", req.Path,
+			}
+			if req.Database != 0 {
+				keyvals = append(keyvals, "database", req.Database.String())
+			}
+			if req.Operations > 0 {
+				keyvals = append(keyvals, "operations", req.Operations)
+			}
+			keyvals = append(keyvals,
+				"status", res.StatusCode,
+				"duration", res.Duration,
+			)
+			if res.RequestID != "" {
+				keyvals = append(keyvals, "request_id", res.RequestID)
+			}
+			if len(res.ErrorCodes) > 0 {
+				codes := make([]string, len(res.ErrorCodes))
+				for i, code := range res.ErrorCodes {
+					codes[i] = code.String()
+				}
+				keyvals = append(keyvals, "error_codes", codes)
+			}
+			if includeBodies {
+				keyvals = append(keyvals,
+					"request_body", string(req.Body),
+					"response_body", string(res.Body),
+				)
+			}
+
+			msg := "request completed"
+			if res.Err != nil {
+				msg = "request failed"
+				keyvals = append(keyvals, "error", res.Err.Error())
+			}
+
+			logger.Log(ctx, msg, keyvals...)
+		},
+		IncludeBodies: includeBodies,
+	}
+}
@@ -0,0 +1,66 @@
+package icloud
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHooks(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		w.Header().Set("x-apple-request-uuid", "2E9A9E4B-8D1B-4F3A-9F0C-3C8E1F7A2B6D")
+		_, _ = fmt.Fprint(w, `{"records":[{"recordName":"a"},{"recordName":"b","reason":"conflict","serverErrorCode":"CONFLICT"}]}`)
+	}
+
+	client, teardown := setup(t, "/", hf)
+	defer teardown()
+
+	type ctxKey struct{}
+
+	var (
+		reqInfo *RequestInfo
+		resInfo *ResponseInfo
+	)
+	err := client.Options(AddHooks(Hooks{
+		BeforeRequest: func(ctx context.Context, req *RequestInfo) context.Context {
+			return context.WithValue(ctx, ctxKey{}, "value")
+		},
+		AfterResponse: func(ctx context.Context, req *RequestInfo, res *ResponseInfo) {
+			assert.Equal(t, "value", ctx.Value(ctxKey{}))
+			reqInfo, resInfo = req, res
+		},
+	}))
+	require.NoError(t, err)
+
+	_, err = client.Records.Modify(context.Background(), Public, RecordsRequest{
+		Operations: []RecordOperation{
+			{Type: Create, Record: Record{Name: "a"}},
+			{Type: Update, Record: Record{Name: "b"}},
+		},
+	})
+	require.NoError(t, err)
+
+	require.NotNil(t, reqInfo)
+	assert.Equal(t, container, reqInfo.Container)
+	assert.Equal(t, environment, reqInfo.Environment)
+	assert.Equal(t, http.MethodPost, reqInfo.Method)
+	assert.Equal(t, "/public/records/modify", reqInfo.Path)
+	assert.Equal(t, Public, reqInfo.Database)
+	assert.Equal(t, "records/modify", reqInfo.Endpoint)
+	assert.Equal(t, 2, reqInfo.Operations)
+	assert.Equal(t, []OperationType{Create, Update}, reqInfo.OperationTypes)
+	assert.Empty(t, reqInfo.Body)
+
+	require.NotNil(t, resInfo)
+	assert.Equal(t, http.StatusOK, resInfo.StatusCode)
+	assert.Equal(t, "2E9A9E4B-8D1B-4F3A-9F0C-3C8E1F7A2B6D", resInfo.RequestID)
+	assert.Equal(t, []ErrorCode{Conflict}, resInfo.ErrorCodes)
+	assert.Positive(t, resInfo.Duration)
+	assert.NoError(t, resInfo.Err)
+	assert.Empty(t, resInfo.Body)
+}
@@ -0,0 +1,27 @@
+module github.com/lukasmalkmus/icloud-go/icloud/icloudotel
+
+go 1.20
+
+// Within this repository, the module is built against the icloud package next
+// to it. Other modules get the required commit of the root module, which is
+// the first one providing all of the hooks this module uses.
+replace github.com/lukasmalkmus/icloud-go => ../..
+
+require (
+	github.com/lukasmalkmus/icloud-go v0.0.0-20261018183738-a24bde8700fb
+	github.com/stretchr/testify v1.8.4
+	go.opentelemetry.io/otel v1.19.0
+	go.opentelemetry.io/otel/metric v1.19.0
+	go.opentelemetry.io/otel/sdk v1.19.0
+	go.opentelemetry.io/otel/sdk/metric v1.19.0
+	go.opentelemetry.io/otel/trace v1.19.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/go-logr/logr v1.2.4 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/sys v0.12.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,213 @@
+// Package icloudotel instruments the icloud client with OpenTelemetry tracing
+// and metrics. It is a separate module to keep the icloud package free of
+// dependencies.
+//
+// Usage:
+//
+//	client, err := icloud.NewClient(container, keyID, privateKey, icloud.Development,
+//		icloudotel.Instrument(),
+//	)
+//
+// Every call of the API creates a client span named after the endpoint, e.g.
+// "CloudKit records/modify". Server error codes, including the ones of failed
+// operations on individual records, are recorded as span events. The following
+// metrics are exported:
+//
+//	cloudkit.client.requests         Counter of requests.
+//	cloudkit.client.request.duration Histogram of request latencies (seconds).
+//	cloudkit.client.operations       Counter of operations sent in requests.
+//	cloudkit.client.errors           Counter of server error codes.
+//
+// The client sends every request once, so no retry metric is exported.
+package icloudotel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// instrumentationName identifies the instrumentation library.
+const instrumentationName = "github.com/lukasmalkmus/icloud-go/icloud/icloudotel"
+
+// Attribute keys used by the instrumentation.
+const (
+	ContainerKey   = attribute.Key("cloudkit.container")
+	EnvironmentKey = attribute.Key("cloudkit.environment")
+	DatabaseKey    = attribute.Key("cloudkit.database")
+	EndpointKey    = attribute.Key("cloudkit.endpoint")
+	OperationsKey  = attribute.Key("cloudkit.operations")
+	RequestIDKey   = attribute.Key("cloudkit.request_id")
+	ErrorCodeKey   = attribute.Key("cloudkit.error_code")
+	MethodKey      = attribute.Key("http.method")
+	StatusCodeKey  = attribute.Key("http.status_code")
+)
+
+// An Option configures the instrumentation.
+type Option func(c *config)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+}
+
+// WithTracerProvider sets the provider of the tracer used to create spans. It
+// defaults to the global one.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.tracerProvider = provider
+	}
+}
+
+// WithMeterProvider sets the provider of the meter used to record metrics. It
+// defaults to the global one.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(c *config) {
+		c.meterProvider = provider
+	}
+}
+
+// Instrument returns an icloud.Option that instruments the client.
+func Instrument(options ...Option) icloud.Option {
+	return func(c *icloud.Client) error {
+		hooks, err := Hooks(options...)
+		if err != nil {
+			return err
+		}
+		return c.Options(icloud.AddHooks(hooks))
+	}
+}
+
+// Hooks returns icloud.Hooks that trace requests and record metrics about
+// them. Most of the time, Instrument is the better choice.
+func Hooks(options ...Option) (icloud.Hooks, error) {
+	cfg := config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+	}
+	for _, option := range options {
+		option(&cfg)
+	}
+
+	var (
+		tracer = cfg.tracerProvider.Tracer(instrumentationName)
+		meter  = cfg.meterProvider.Meter(instrumentationName)
+		inst   instruments
+		err    error
+	)
+
+	if inst.requests, err = meter.Int64Counter("cloudkit.client.requests",
+		metric.WithDescription("Number of requests sent to the CloudKit Web Services API."),
+	); err != nil {
+		return icloud.Hooks{}, err
+	}
+	if inst.duration, err = meter.Float64Histogram("cloudkit.client.request.duration",
+		metric.WithDescription("Duration of requests sent to the CloudKit Web Services API."),
+		metric.WithUnit("s"),
+	); err != nil {
+		return icloud.Hooks{}, err
+	}
+	if inst.operations, err = meter.Int64Counter("cloudkit.client.operations",
+		metric.WithDescription("Number of operations sent to the CloudKit Web Services API."),
+	); err != nil {
+		return icloud.Hooks{}, err
+	}
+	if inst.errors, err = meter.Int64Counter("cloudkit.client.errors",
+		metric.WithDescription("Number of server error codes returned by the CloudKit Web Services API."),
+	); err != nil {
+		return icloud.Hooks{}, err
+	}
+
+	return icloud.Hooks{
+		BeforeRequest: func(ctx context.Context, req *icloud.RequestInfo) context.Context {
+			ctx, _ = tracer.Start(ctx, spanName(req),
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(requestAttributes(req)...),
+			)
+			return ctx
+		},
+		AfterResponse: func(ctx context.Context, req *icloud.RequestInfo, res *icloud.ResponseInfo) {
+			span := trace.SpanFromContext(ctx)
+			defer span.End()
+
+			if res.StatusCode > 0 {
+				span.SetAttributes(StatusCodeKey.Int(res.StatusCode))
+			}
+			if res.RequestID != "" {
+				span.SetAttributes(RequestIDKey.String(res.RequestID))
+			}
+			for _, code := range res.ErrorCodes {
+				span.AddEvent("cloudkit.error", trace.WithAttributes(ErrorCodeKey.String(code.String())))
+			}
+			if res.Err != nil {
+				span.RecordError(res.Err)
+				span.SetStatus(codes.Error, res.Err.Error())
+			}
+
+			inst.record(ctx, req, res)
+		},
+	}, nil
+}
+
+// instruments are the metric instruments the hooks record to.
+type instruments struct {
+	requests   metric.Int64Counter
+	duration   metric.Float64Histogram
+	operations metric.Int64Counter
+	errors     metric.Int64Counter
+}
+
+func (inst instruments) record(ctx context.Context, req *icloud.RequestInfo, res *icloud.ResponseInfo) {
+	// Requests that didn't receive a response are reported with a status code
+	// of zero.
+	set := metric.WithAttributes(append(commonAttributes(req), StatusCodeKey.Int(res.StatusCode))...)
+
+	inst.requests.Add(ctx, 1, set)
+	inst.duration.Record(ctx, res.Duration.Seconds(), set)
+	if req.Operations > 0 {
+		inst.operations.Add(ctx, int64(req.Operations), metric.WithAttributes(commonAttributes(req)...))
+	}
+	for _, code := range res.ErrorCodes {
+		errAttrs := append(commonAttributes(req), ErrorCodeKey.String(code.String()))
+		inst.errors.Add(ctx, 1, metric.WithAttributes(errAttrs...))
+	}
+}
+
+// spanName returns the name of the span for the request.
+func spanName(req *icloud.RequestInfo) string {
+	if req.Endpoint == "" {
+		return "CloudKit " + req.Method
+	}
+	return "CloudKit " + req.Endpoint
+}
+
+// commonAttributes returns the attributes shared by spans and metrics.
+func commonAttributes(req *icloud.RequestInfo) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		ContainerKey.String(req.Container),
+		EnvironmentKey.String(req.Environment.String()),
+		MethodKey.String(req.Method),
+	}
+	if req.Database != 0 {
+		attrs = append(attrs, DatabaseKey.String(req.Database.String()))
+	}
+	if req.Endpoint != "" {
+		attrs = append(attrs, EndpointKey.String(req.Endpoint))
+	}
+	return attrs
+}
+
+// requestAttributes returns the span attributes describing the request.
+func requestAttributes(req *icloud.RequestInfo) []attribute.KeyValue {
+	attrs := commonAttributes(req)
+	if req.Operations > 0 {
+		attrs = append(attrs, OperationsKey.Int(req.Operations))
+	}
+	return attrs
+}
@@ -0,0 +1,111 @@
+package icloudotel_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+	"github.com/lukasmalkmus/icloud-go/icloud/icloudotel"
+	"github.com/lukasmalkmus/icloud-go/icloud/icloudtest"
+)
+
+const container = "iCloud.com.lukasmalkmus.Example-App"
+
+func TestInstrument(t *testing.T) {
+	var (
+		spanRecorder   = tracetest.NewSpanRecorder()
+		tracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder))
+		metricReader   = sdkmetric.NewManualReader()
+		meterProvider  = sdkmetric.NewMeterProvider(sdkmetric.WithReader(metricReader))
+	)
+
+	srv := icloudtest.NewServer()
+	defer srv.Close()
+
+	client, err := srv.NewClient(container, icloud.Development, icloudotel.Instrument(
+		icloudotel.WithTracerProvider(tracerProvider),
+		icloudotel.WithMeterProvider(meterProvider),
+	))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	create := icloud.RecordsRequest{
+		Operations: []icloud.RecordOperation{
+			{Type: icloud.Create, Record: icloud.Record{Name: "a", Type: "MyRecord"}},
+		},
+	}
+	for i := 0; i < 2; i++ {
+		_, err = client.Records.Modify(ctx, icloud.Public, create)
+		require.NoError(t, err)
+	}
+
+	_, err = client.Records.Modify(ctx, icloud.Public, icloud.RecordsRequest{Atomic: true})
+	require.Error(t, err)
+
+	// Traces.
+	spans := spanRecorder.Ended()
+	require.Len(t, spans, 3)
+
+	span := spans[1]
+	assert.Equal(t, "CloudKit records/modify", span.Name())
+	assert.Equal(t, trace.SpanKindClient, span.SpanKind())
+	assert.Contains(t, span.Attributes(), icloudotel.ContainerKey.String(container))
+	assert.Contains(t, span.Attributes(), icloudotel.EnvironmentKey.String("development"))
+	assert.Contains(t, span.Attributes(), icloudotel.DatabaseKey.String("public"))
+	assert.Contains(t, span.Attributes(), icloudotel.EndpointKey.String("records/modify"))
+	assert.Contains(t, span.Attributes(), icloudotel.OperationsKey.Int(1))
+	assert.Contains(t, span.Attributes(), icloudotel.StatusCodeKey.Int(200))
+	require.Len(t, span.Events(), 1)
+	assert.Equal(t, "cloudkit.error", span.Events()[0].Name)
+	assert.Contains(t, span.Events()[0].Attributes, icloudotel.ErrorCodeKey.String("EXISTS"))
+
+	assert.Equal(t, "Error", spans[2].Status().Code.String())
+
+	// Metrics.
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, metricReader.Collect(ctx, &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+
+	metrics := make(map[string]metricdata.Metrics)
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+
+	requests := metrics["cloudkit.client.requests"].Data.(metricdata.Sum[int64])
+	assert.Equal(t, int64(3), sum(requests.DataPoints))
+
+	operations := metrics["cloudkit.client.operations"].Data.(metricdata.Sum[int64])
+	assert.Equal(t, int64(2), sum(operations.DataPoints))
+
+	duration := metrics["cloudkit.client.request.duration"].Data.(metricdata.Histogram[float64])
+	var count uint64
+	for _, dp := range duration.DataPoints {
+		count += dp.Count
+	}
+	assert.Equal(t, uint64(3), count)
+
+	errors := metrics["cloudkit.client.errors"].Data.(metricdata.Sum[int64])
+	require.Len(t, errors.DataPoints, 2)
+	for _, dp := range errors.DataPoints {
+		code, ok := dp.Attributes.Value(icloudotel.ErrorCodeKey)
+		require.True(t, ok)
+		assert.Contains(t, []string{"EXISTS", "BAD_REQUEST"}, code.AsString())
+		assert.Equal(t, int64(1), dp.Value)
+	}
+}
+
+func sum(dps []metricdata.DataPoint[int64]) (n int64) {
+	for _, dp := range dps {
+		n += dp.Value
+	}
+	return n
+}
@@ -0,0 +1,28 @@
+module github.com/lukasmalkmus/icloud-go/icloud/icloudprom
+
+go 1.20
+
+// Within this repository, the module is built against the icloud package next
+// to it. Other modules get the required commit of the root module, which is
+// the first one providing all of the hooks this module uses.
+replace github.com/lukasmalkmus/icloud-go => ../..
+
+require (
+	github.com/lukasmalkmus/icloud-go v0.0.0-20261018183919-2e99145f460e
+	github.com/prometheus/client_golang v1.18.0
+	github.com/stretchr/testify v1.8.4
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/matttproud/golang_protobuf_extensions/v2 v2.0.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.45.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	golang.org/x/sys v0.15.0 // indirect
+	google.golang.org/protobuf v1.31.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,139 @@
+// Package icloudprom provides a Prometheus collector for the activity of the
+// icloud client. It is a separate module to keep the icloud package free of
+// dependencies.
+//
+// Usage:
+//
+//	collector := icloudprom.NewCollector()
+//	prometheus.MustRegister(collector)
+//
+//	client, err := icloud.NewClient(container, keyID, privateKey, icloud.Development,
+//		collector.Instrument(),
+//	)
+//
+// The following metrics are exported:
+//
+//	cloudkit_client_requests_total            Counter of requests by endpoint and status.
+//	cloudkit_client_request_duration_seconds  Histogram of request latencies by endpoint.
+//	cloudkit_client_operations_total          Counter of operations by operation type.
+//	cloudkit_client_errors_total              Counter of server error codes.
+//	cloudkit_client_requests_in_flight        Gauge of requests in flight.
+//
+// All metrics carry the container and environment as labels, which allows to
+// alert on e.g. "QUOTA_EXCEEDED" errors per container. A single collector can
+// be shared by multiple clients. The client sends every request once, so no
+// retry metric is exported.
+package icloudprom
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+const namespace = "cloudkit_client"
+
+// Collector is a prometheus.Collector which is fed by the hooks of one or more
+// icloud clients.
+type Collector struct {
+	requests   *prometheus.CounterVec
+	duration   *prometheus.HistogramVec
+	operations *prometheus.CounterVec
+	errors     *prometheus.CounterVec
+	inFlight   *prometheus.GaugeVec
+}
+
+// NewCollector returns a new Collector. It must be registered with a
+// prometheus.Registerer to be exported.
+func NewCollector() *Collector {
+	return &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Total number of requests sent to CloudKit.",
+		}, []string{"container", "environment", "database", "endpoint", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Duration of requests sent to CloudKit.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"container", "environment", "database", "endpoint"}),
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "operations_total",
+			Help:      "Total number of operations sent to CloudKit.",
+		}, []string{"container", "environment", "database", "endpoint", "operation_type"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "errors_total",
+			Help:      "Total number of server errors returned by CloudKit, including errors of individual operations.",
+		}, []string{"container", "environment", "database", "endpoint", "error_code"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "requests_in_flight",
+			Help:      "Number of requests to CloudKit currently in flight.",
+		}, []string{"container", "environment"}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.duration.Describe(ch)
+	c.operations.Describe(ch)
+	c.errors.Describe(ch)
+	c.inFlight.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.duration.Collect(ch)
+	c.operations.Collect(ch)
+	c.errors.Collect(ch)
+	c.inFlight.Collect(ch)
+}
+
+// Instrument returns an icloud.Option which feeds the collector with the
+// requests of the client.
+func (c *Collector) Instrument() icloud.Option {
+	return icloud.AddHooks(c.Hooks())
+}
+
+// Hooks returns the icloud.Hooks which feed the collector.
+func (c *Collector) Hooks() icloud.Hooks {
+	return icloud.Hooks{
+		BeforeRequest: func(ctx context.Context, req *icloud.RequestInfo) context.Context {
+			c.inFlight.WithLabelValues(req.Container, req.Environment.String()).Inc()
+			return ctx
+		},
+		AfterResponse: func(_ context.Context, req *icloud.RequestInfo, res *icloud.ResponseInfo) {
+			c.inFlight.WithLabelValues(req.Container, req.Environment.String()).Dec()
+
+			labels := []string{req.Container, req.Environment.String(), database(req), req.Endpoint}
+
+			// Requests that didn't receive a response are reported with a
+			// status of zero.
+			c.requests.WithLabelValues(append(labels, strconv.Itoa(res.StatusCode))...).Inc()
+			c.duration.WithLabelValues(labels...).Observe(res.Duration.Seconds())
+			for _, typ := range req.OperationTypes {
+				c.operations.WithLabelValues(append(labels, typ.String())...).Inc()
+			}
+			for _, code := range res.ErrorCodes {
+				c.errors.WithLabelValues(append(labels, code.String())...).Inc()
+			}
+		},
+	}
+}
+
+// database returns the name of the database the request is made against or
+// an empty string, if it isn't made against a database.
+func database(req *icloud.RequestInfo) string {
+	if req.Database == 0 {
+		return ""
+	}
+	return req.Database.String()
+}
@@ -0,0 +1,75 @@
+package icloudprom_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+	"github.com/lukasmalkmus/icloud-go/icloud/icloudprom"
+	"github.com/lukasmalkmus/icloud-go/icloud/icloudtest"
+)
+
+const container = "iCloud.com.lukasmalkmus.Example-App"
+
+func TestCollector(t *testing.T) {
+	collector := icloudprom.NewCollector()
+
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(collector))
+
+	srv := icloudtest.NewServer()
+	defer srv.Close()
+
+	client, err := srv.NewClient(container, icloud.Development, collector.Instrument())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	create := icloud.RecordsRequest{
+		Operations: []icloud.RecordOperation{
+			{Type: icloud.Create, Record: icloud.Record{Name: "a", Type: "MyRecord"}},
+			{Type: icloud.Create, Record: icloud.Record{Name: "b", Type: "MyRecord"}},
+		},
+	}
+	_, err = client.Records.Modify(ctx, icloud.Public, create)
+	require.NoError(t, err)
+
+	// Creating the records again fails for every record.
+	_, err = client.Records.Modify(ctx, icloud.Public, create)
+	require.NoError(t, err)
+
+	_, err = client.Records.Modify(ctx, icloud.Public, icloud.RecordsRequest{Atomic: true})
+	require.Error(t, err)
+
+	expected := `
+# HELP cloudkit_client_errors_total Total number of server errors returned by CloudKit, including errors of individual operations.
+# TYPE cloudkit_client_errors_total counter
+cloudkit_client_errors_total{container="iCloud.com.lukasmalkmus.Example-App",database="public",endpoint="records/modify",environment="development",error_code="BAD_REQUEST"} 1
+cloudkit_client_errors_total{container="iCloud.com.lukasmalkmus.Example-App",database="public",endpoint="records/modify",environment="development",error_code="EXISTS"} 2
+# HELP cloudkit_client_operations_total Total number of operations sent to CloudKit.
+# TYPE cloudkit_client_operations_total counter
+cloudkit_client_operations_total{container="iCloud.com.lukasmalkmus.Example-App",database="public",endpoint="records/modify",environment="development",operation_type="create"} 4
+# HELP cloudkit_client_requests_in_flight Number of requests to CloudKit currently in flight.
+# TYPE cloudkit_client_requests_in_flight gauge
+cloudkit_client_requests_in_flight{container="iCloud.com.lukasmalkmus.Example-App",environment="development"} 0
+# HELP cloudkit_client_requests_total Total number of requests sent to CloudKit.
+# TYPE cloudkit_client_requests_total counter
+cloudkit_client_requests_total{container="iCloud.com.lukasmalkmus.Example-App",database="public",endpoint="records/modify",environment="development",status="200"} 2
+cloudkit_client_requests_total{container="iCloud.com.lukasmalkmus.Example-App",database="public",endpoint="records/modify",environment="development",status="400"} 1
+`
+	err = testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"cloudkit_client_errors_total",
+		"cloudkit_client_operations_total",
+		"cloudkit_client_requests_in_flight",
+		"cloudkit_client_requests_total",
+	)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, testutil.CollectAndCount(collector, "cloudkit_client_request_duration_seconds"))
+}
@@ -0,0 +1,181 @@
+package icloudtest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// upload is an asset that has been or is about to be uploaded.
+type upload struct {
+	asset icloud.Asset
+	data  []byte
+}
+
+// requestUpload handles the assets/upload endpoint.
+func (s *Server) requestUpload(db *database, body []byte) (interface{}, *apiError) {
+	var req icloud.UploadRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, newAPIError(icloud.BadRequest, "invalid request body: %s", err)
+	}
+
+	if _, apiErr := db.zone(req.ZoneID); apiErr != nil {
+		return nil, apiErr
+	}
+
+	res := icloud.UploadResponse{Tokens: make([]icloud.UploadToken, len(req.Tokens))}
+	for i, token := range req.Tokens {
+		if token.FieldName == "" {
+			return nil, newAPIError(icloud.BadRequest, "missing field name for token %d", i)
+		} else if token.RecordName == "" {
+			token.RecordName = newUUID()
+		}
+
+		id := newUUID()
+		s.uploads[id] = new(upload)
+
+		token.URL = s.URL + "/assets/upload/" + id
+		res.Tokens[i] = token
+	}
+
+	return res, nil
+}
+
+// rereferenceAssets handles the assets/rereference endpoint. The data of a
+// referenced asset is shared by a new upload, whose receipt is returned.
+func (s *Server) rereferenceAssets(db *database, body []byte) (interface{}, *apiError) {
+	var req icloud.RereferenceRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, newAPIError(icloud.BadRequest, "invalid request body: %s", err)
+	}
+
+	if _, apiErr := db.zone(req.ZoneID); apiErr != nil {
+		return nil, apiErr
+	}
+
+	res := icloud.RereferenceResponse{Assets: make([]icloud.AssetReference, len(req.Assets))}
+	for i, ref := range req.Assets {
+		if ref.FieldName == "" {
+			return nil, newAPIError(icloud.BadRequest, "missing field name for asset %d", i)
+		} else if ref.RecordName == "" {
+			ref.RecordName = newUUID()
+		}
+
+		u, ok := s.findUpload(ref.FileChecksum, ref.ReferenceChecksum)
+		if !ok {
+			return nil, newAPIError(icloud.BadRequest, "unknown asset %d", i)
+		}
+
+		id := newUUID()
+		s.uploads[id] = &upload{asset: u.asset, data: u.data}
+		s.uploads[id].asset.Receipt = id
+
+		ref.Asset = s.uploads[id].asset
+		res.Assets[i] = ref
+	}
+
+	return res, nil
+}
+
+// findUpload returns the uploaded asset with the given checksums. The caller
+// must hold s.mu.
+func (s *Server) findUpload(fileChecksum, referenceChecksum string) (*upload, bool) {
+	for _, u := range s.uploads {
+		if u.data != nil && u.asset.FileChecksum == fileChecksum && u.asset.ReferenceChecksum == referenceChecksum {
+			return u, true
+		}
+	}
+	return nil, false
+}
+
+// uploadData handles the upload of asset data to a url returned by
+// requestUpload.
+func (s *Server) uploadData(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, icloud.BadRequest, "method not allowed")
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, icloud.BadRequest, "failed to read request body")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.uploads[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, icloud.NotFound, "unknown upload url")
+		return
+	}
+
+	var wrappingKey [16]byte
+	if _, err = rand.Read(wrappingKey[:]); err != nil {
+		writeError(w, http.StatusInternalServerError, icloud.InternalError, "failed to generate wrapping key")
+		return
+	}
+
+	var (
+		checksum          = sha256.Sum256(data)
+		referenceChecksum = sha256.Sum256(append(wrappingKey[:], checksum[:]...))
+	)
+
+	u.data = data
+	u.asset = icloud.Asset{
+		FileChecksum:      base64.StdEncoding.EncodeToString(checksum[:]),
+		Size:              int64(len(data)),
+		ReferenceChecksum: base64.StdEncoding.EncodeToString(referenceChecksum[:]),
+		WrappingKey:       base64.StdEncoding.EncodeToString(wrappingKey[:]),
+		Receipt:           id,
+	}
+
+	writeJSON(w, http.StatusOK, map[string]icloud.Asset{
+		"singleFile": u.asset,
+	})
+}
+
+// downloadData handles the download of asset data from the url of an asset.
+func (s *Server) downloadData(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, icloud.BadRequest, "method not allowed")
+		return
+	}
+
+	s.mu.Lock()
+	u, ok := s.uploads[id]
+	s.mu.Unlock()
+
+	if !ok || u.data == nil {
+		writeError(w, http.StatusNotFound, icloud.NotFound, "unknown asset")
+		return
+	}
+
+	w.Header().Set("content-type", "application/octet-stream")
+	w.Header().Set("content-length", strconv.Itoa(len(u.data)))
+	_, _ = w.Write(u.data)
+}
+
+// resolveAsset returns the value of an ASSETID field for the asset uploaded
+// with the given receipt. The caller must hold s.mu.
+func (s *Server) resolveAsset(receipt string) (map[string]interface{}, *apiError) {
+	u, ok := s.uploads[receipt]
+	if !ok || u.data == nil {
+		return nil, newAPIError(icloud.BadRequest, "invalid asset receipt %q", receipt)
+	}
+
+	return map[string]interface{}{
+		"fileChecksum":      u.asset.FileChecksum,
+		"size":              float64(u.asset.Size),
+		"referenceChecksum": u.asset.ReferenceChecksum,
+		"wrappingKey":       u.asset.WrappingKey,
+		"downloadURL":       s.URL + "/assets/download/" + receipt,
+	}, nil
+}
@@ -0,0 +1,329 @@
+package icloudtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// redactedHeaders are the request headers which are not recorded. They carry
+// the key id and request signature, which change on every request.
+var redactedHeaders = []string{
+	"x-apple-cloudkit-request-keyid",
+	"x-apple-cloudkit-request-iso8601date",
+	"x-apple-cloudkit-request-signaturev1",
+}
+
+// Cassette is a sequence of recorded HTTP interactions. It is stored as an
+// indented JSON document, which makes it suitable for golden files.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// LoadCassette reads a Cassette from the file at the given path.
+func LoadCassette(path string) (*Cassette, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Cassette
+	if err = json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("decode cassette %q: %w", path, err)
+	}
+
+	return &c, nil
+}
+
+// Save writes the Cassette to the file at the given path.
+func (c *Cassette) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0600)
+}
+
+// Interaction is a recorded request and the response to it.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a recorded HTTP request. Headers carrying the key id and
+// request signature are redacted.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// RecordedResponse is a recorded HTTP response.
+type RecordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body,omitempty"`
+}
+
+// Body is a recorded HTTP body. JSON object and array bodies are stored as
+// JSON, all others as base64 encoded strings.
+type Body []byte
+
+// MarshalJSON implements json.Marshaler.
+func (b Body) MarshalJSON() ([]byte, error) {
+	if trimmed := bytes.TrimSpace(b); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, trimmed); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return json.Marshal([]byte(b))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *Body) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var raw []byte
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+		*b = raw
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return err
+	}
+	*b = buf.Bytes()
+
+	return nil
+}
+
+// equal returns true, if both bodies are equal. JSON bodies are compared
+// semantically, ignoring whitespace and the order of object keys. Numbers are
+// compared exactly, so INT64 values beyond the precision of a float64 don't
+// match their neighbours.
+func (b Body) equal(other Body) bool {
+	v1, err1 := b.decode()
+	v2, err2 := other.decode()
+	if err1 != nil || err2 != nil {
+		return bytes.Equal(b, other)
+	}
+
+	n1, _ := json.Marshal(v1)
+	n2, _ := json.Marshal(v2)
+
+	return bytes.Equal(n1, n2)
+}
+
+// decode decodes the body as a single JSON value. Numbers are decoded as
+// json.Number.
+func (b Body) decode() (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("invalid data after top-level value")
+	}
+
+	return v, nil
+}
+
+// Recorder is an http.RoundTripper that records the requests passing through
+// it and the responses to them. It is meant to be installed via
+// icloud.SetHTTPClient. It is safe for concurrent use.
+type Recorder struct {
+	transport http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder returns a new Recorder which passes requests to the given
+// transport. If transport is nil, http.DefaultTransport is used.
+func NewRecorder(transport http.RoundTripper) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &Recorder{
+		transport: transport,
+	}
+}
+
+// RoundTrip implements http.RoundTripper. The request is passed on as a clone
+// carrying the recorded body, so the request itself is not modified.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	out := req.Clone(req.Context())
+	if reqBody != nil {
+		out.Body = io.NopCloser(bytes.NewReader(reqBody))
+		out.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(reqBody)), nil
+		}
+	}
+
+	resp, err := r.transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	header := req.Header.Clone()
+	for _, key := range redactedHeaders {
+		header.Del(key)
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			Path:   req.URL.Path,
+			Header: header,
+			Body:   reqBody,
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header.Clone(),
+			Body:       respBody,
+		},
+	})
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+// Cassette returns a Cassette holding the interactions recorded so far.
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	interactions := make([]Interaction, len(r.cassette.Interactions))
+	copy(interactions, r.cassette.Interactions)
+
+	return &Cassette{Interactions: interactions}
+}
+
+// Replayer is an http.RoundTripper that serves the responses of a Cassette. A
+// request is matched to the first unused interaction with the same method,
+// path and body. Headers, including the ones carrying the request signature,
+// are not considered. It is safe for concurrent use.
+type Replayer struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// NewReplayer returns a new Replayer which serves the interactions of the
+// given Cassette.
+func NewReplayer(cassette *Cassette) *Replayer {
+	return &Replayer{
+		cassette: cassette,
+		used:     make([]bool, len(cassette.Interactions)),
+	}
+}
+
+// RoundTrip implements http.RoundTripper. The Content-Length header of the
+// response is set to the length of the recorded body, which is compacted when
+// stored and might differ from the original one.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.cassette.Interactions {
+		recorded := interaction.Request
+		if r.used[i] || recorded.Method != req.Method || recorded.Path != req.URL.Path || !recorded.Body.equal(body) {
+			continue
+		}
+		r.used[i] = true
+
+		resp := newResponse(req, interaction.Response.StatusCode, "", interaction.Response.Body)
+		resp.Header = interaction.Response.Header.Clone()
+		if resp.Header == nil {
+			resp.Header = make(http.Header)
+		}
+		resp.Header.Set("content-length", strconv.Itoa(len(interaction.Response.Body)))
+
+		return resp, nil
+	}
+
+	return nil, fmt.Errorf("icloudtest: no recorded interaction for %s %s", req.Method, req.URL.Path)
+}
+
+// Unused returns the interactions which haven't been replayed, yet.
+func (r *Replayer) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unused []Interaction
+	for i, interaction := range r.cassette.Interactions {
+		if !r.used[i] {
+			unused = append(unused, interaction)
+		}
+	}
+	return unused
+}
+
+// requestBody reads and closes the body of the request. The request is not
+// modified, as required by http.RoundTripper.
+func requestBody(req *http.Request) (Body, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err = req.Body.Close(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// readBody reads the body and replaces it with a reader returning the same
+// data.
+func readBody(body *io.ReadCloser) (Body, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+
+	b, err := io.ReadAll(*body)
+	if err != nil {
+		return nil, err
+	}
+	if err = (*body).Close(); err != nil {
+		return nil, err
+	}
+
+	*body = io.NopCloser(bytes.NewReader(b))
+
+	return b, nil
+}
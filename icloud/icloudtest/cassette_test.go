@@ -0,0 +1,182 @@
+package icloudtest_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+	"github.com/lukasmalkmus/icloud-go/icloud/icloudtest"
+)
+
+func TestCassette(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	ctx := context.Background()
+
+	lookup := icloud.LookupRequest{
+		Records: []icloud.Record{{Name: "a"}, {Name: "b"}},
+	}
+
+	// Record the interactions with the fake server.
+	srv := icloudtest.NewServer()
+	recorder := icloudtest.NewRecorder(srv.Client().Transport)
+
+	client, err := srv.NewClient(container, icloud.Development,
+		icloud.SetHTTPClient(&http.Client{Transport: recorder}),
+	)
+	require.NoError(t, err)
+
+	_, err = client.Records.Modify(ctx, icloud.Public, icloud.RecordsRequest{
+		Operations: []icloud.RecordOperation{
+			create("a", icloud.Field{Name: "title", Value: "A"}),
+		},
+	})
+	require.NoError(t, err)
+
+	exp, err := client.Records.Lookup(ctx, icloud.Public, lookup)
+	require.NoError(t, err)
+
+	srv.Close()
+
+	require.NoError(t, recorder.Cassette().Save(path))
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, strings.ToLower(string(b)), "x-apple-cloudkit-request")
+	assert.NotContains(t, string(b), icloudtest.KeyID)
+
+	// Replay the interactions with a client using another key.
+	cassette, err := icloudtest.LoadCassette(path)
+	require.NoError(t, err)
+	require.Len(t, cassette.Interactions, 2)
+
+	replayer := icloudtest.NewReplayer(cassette)
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	client, err = icloud.NewClient(container, "other-key", privateKey, icloud.Development,
+		icloud.SetBaseURL("http://replay.invalid"),
+		icloud.SetHTTPClient(&http.Client{Transport: replayer}),
+	)
+	require.NoError(t, err)
+
+	// Requests that weren't recorded fail.
+	_, err = client.Records.Lookup(ctx, icloud.Public, icloud.LookupRequest{
+		Records: []icloud.Record{{Name: "c"}},
+	})
+	assert.Error(t, err)
+
+	act, err := client.Records.Lookup(ctx, icloud.Public, lookup)
+	require.NoError(t, err)
+	assert.Equal(t, exp, act)
+
+	// Every interaction is only replayed once.
+	_, err = client.Records.Lookup(ctx, icloud.Public, lookup)
+	assert.Error(t, err)
+
+	assert.Len(t, replayer.Unused(), 1)
+}
+
+func TestBody(t *testing.T) {
+	for _, body := range []string{`{"b":1,"a":[1,2]}`, "binary\x00data", `"string"`, ""} {
+		cassette := icloudtest.Cassette{
+			Interactions: []icloudtest.Interaction{
+				{Request: icloudtest.RecordedRequest{Body: icloudtest.Body(body)}},
+			},
+		}
+
+		path := filepath.Join(t.TempDir(), "cassette.json")
+		require.NoError(t, cassette.Save(path))
+
+		loaded, err := icloudtest.LoadCassette(path)
+		require.NoError(t, err)
+		require.Len(t, loaded.Interactions, 1)
+
+		assert.Equal(t, body, string(loaded.Interactions[0].Request.Body))
+	}
+}
+
+func TestReplayer(t *testing.T) {
+	cassette := &icloudtest.Cassette{
+		Interactions: []icloudtest.Interaction{
+			{
+				Request: icloudtest.RecordedRequest{
+					Method: http.MethodPost,
+					Path:   "/records/modify",
+					Body:   icloudtest.Body(`{"value":9007199254740993}`),
+				},
+				Response: icloudtest.RecordedResponse{
+					StatusCode: http.StatusOK,
+					Header: http.Header{
+						"Content-Type":   {"application/json"},
+						"Content-Length": {"42"},
+					},
+					Body: icloudtest.Body(`{"records":[]}`),
+				},
+			},
+		},
+	}
+	replayer := icloudtest.NewReplayer(cassette)
+
+	newRequest := func(body string) *http.Request {
+		req, err := http.NewRequest(http.MethodPost, "http://replay.invalid/records/modify", strings.NewReader(body))
+		require.NoError(t, err)
+		return req
+	}
+
+	// Numbers beyond the precision of a float64 are compared exactly.
+	_, err := replayer.RoundTrip(newRequest(`{"value":9007199254740992}`))
+	assert.Error(t, err)
+
+	req := newRequest(`{ "value": 9007199254740993 }`)
+	body := req.Body
+
+	resp, err := replayer.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, body, req.Body, "request must not be modified")
+	assert.Equal(t, "14", resp.Header.Get("content-length"))
+	assert.EqualValues(t, 14, resp.ContentLength)
+}
+
+func TestRecorder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(b)
+	}))
+	defer srv.Close()
+
+	recorder := icloudtest.NewRecorder(srv.Client().Transport)
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/echo", strings.NewReader(`{"a":1}`))
+	require.NoError(t, err)
+	body := req.Body
+
+	resp, err := recorder.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, body, req.Body, "request must not be modified")
+
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":1}`, string(b))
+
+	interactions := recorder.Cassette().Interactions
+	require.Len(t, interactions, 1)
+	assert.Equal(t, `{"a":1}`, string(interactions[0].Request.Body))
+	assert.Equal(t, `{"a":1}`, string(interactions[0].Response.Body))
+}
@@ -0,0 +1,134 @@
+package icloudtest
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// databaseKey identifies a database of a container in a specific environment.
+type databaseKey struct {
+	container   string
+	environment icloud.Environment
+	database    icloud.Database
+}
+
+// parseDatabaseKey returns the key of the database of the container in the
+// environment. If database is empty, the database of the key is zero, as for
+// endpoints that don't belong to a database.
+func parseDatabaseKey(container, environment, database string) (databaseKey, error) {
+	key := databaseKey{container: container}
+
+	switch environment {
+	case icloud.Development.String():
+		key.environment = icloud.Development
+	case icloud.Production.String():
+		key.environment = icloud.Production
+	default:
+		return key, fmt.Errorf("unknown environment %q", environment)
+	}
+
+	switch database {
+	case "":
+	case icloud.Public.String():
+		key.database = icloud.Public
+	case icloud.Private.String():
+		key.database = icloud.Private
+	case icloud.Shared.String():
+		key.database = icloud.Shared
+	default:
+		return key, fmt.Errorf("unknown database %q", database)
+	}
+
+	return key, nil
+}
+
+// database is the state of a single database.
+type database struct {
+	public bool
+	zones  map[string]*zone
+}
+
+// zone is the state of a single zone.
+type zone struct {
+	name    string
+	records map[string]*storedRecord
+	// seq is incremented on every change to a record of the zone.
+	seq int
+}
+
+// storedRecord is a record as it is stored by the server. Stored records are
+// never mutated but replaced on change.
+type storedRecord struct {
+	record icloud.Record
+	// created is the order in which records were created.
+	created int
+	// seq is the sequence number of the last change to the record.
+	seq int
+}
+
+// database returns the database identified by key, creating it if it doesn't
+// exist. The caller must hold s.mu.
+func (s *Server) database(key databaseKey) *database {
+	db, ok := s.databases[key]
+	if !ok {
+		db = &database{
+			public: key.database == icloud.Public,
+			zones: map[string]*zone{
+				icloud.DefaultZoneName: newZone(icloud.DefaultZoneName),
+			},
+		}
+		s.databases[key] = db
+	}
+	return db
+}
+
+func newZone(name string) *zone {
+	return &zone{
+		name:    name,
+		records: make(map[string]*storedRecord),
+	}
+}
+
+// zone returns the zone identified by id. If id is nil, the default zone is
+// returned.
+func (db *database) zone(id *icloud.ZoneID) (*zone, *apiError) {
+	name := icloud.DefaultZoneName
+	if id != nil && id.Name != "" {
+		name = id.Name
+	}
+
+	z, ok := db.zones[name]
+	if !ok {
+		return nil, newAPIError(icloud.ZoneNotFound, "zone %q does not exist", name)
+	}
+	return z, nil
+}
+
+// isDefault returns true, if the zone is the default zone.
+func (z *zone) isDefault() bool {
+	return z.name == icloud.DefaultZoneName
+}
+
+// id returns the ZoneID of the zone.
+func (z *zone) id() *icloud.ZoneID {
+	return &icloud.ZoneID{Name: z.name}
+}
+
+// sortedRecords returns the records of the zone which haven't been deleted in
+// the order they have been created.
+func (z *zone) sortedRecords() []*storedRecord {
+	records := make([]*storedRecord, 0, len(z.records))
+	for _, r := range z.records {
+		if !r.record.Deleted {
+			records = append(records, r)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].created < records[j].created
+	})
+
+	return records
+}
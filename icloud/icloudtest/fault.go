@@ -0,0 +1,259 @@
+package icloudtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// A Fault is a failure injected into a request by a FaultTransport. It either
+// fails the request without passing it to next or alters the response of next.
+// The rng must be used for all randomness to keep faults deterministic. It is
+// not shared with faults of other requests.
+type Fault func(req *http.Request, next http.RoundTripper, rng *rand.Rand) (*http.Response, error)
+
+// FaultRule specifies when a Fault is injected into a request.
+type FaultRule struct {
+	// Endpoint the rule applies to, e.g. "records/modify". If not set, the rule
+	// applies to all endpoints.
+	Endpoint string
+	// Skip is the number of matching requests to pass before the fault is
+	// injected.
+	Skip int
+	// Limit is the maximum number of times the fault is injected. If not set,
+	// the number is unlimited.
+	Limit int
+	// Probability of the fault being injected into a matching request. If not
+	// set, the fault is always injected.
+	Probability float64
+	// Fault to inject.
+	Fault Fault
+}
+
+// FaultTransport is an http.RoundTripper that injects CloudKit shaped failures
+// into requests. It is meant to be installed via icloud.SetHTTPClient to test
+// how code deals with failing requests. Rules are evaluated in order and the
+// first one that applies to a request injects its fault. Randomness is derived
+// from a seed, so a FaultTransport behaves the same for the same sequence of
+// requests. It is safe for concurrent use and doesn't serialize requests.
+type FaultTransport struct {
+	transport http.RoundTripper
+	rules     []FaultRule
+
+	mu      sync.Mutex
+	rng     *rand.Rand
+	matched []int
+	fired   []int
+}
+
+// NewFaultTransport returns a new FaultTransport which passes requests to the
+// given transport. If transport is nil, http.DefaultTransport is used. The
+// seed initializes the source of randomness.
+func NewFaultTransport(transport http.RoundTripper, seed int64, rules ...FaultRule) *FaultTransport {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &FaultTransport{
+		transport: transport,
+		rules:     rules,
+
+		rng:     rand.New(rand.NewSource(seed)), //nolint:gosec // Determinism is desired.
+		matched: make([]int, len(rules)),
+		fired:   make([]int, len(rules)),
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *FaultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rule, rng := t.pick(req); rule != nil {
+		return rule.Fault(req, t.transport, rng)
+	}
+	return t.transport.RoundTrip(req)
+}
+
+// pick returns the rule whose fault is injected into the request, if any. The
+// fault gets its own source of randomness, seeded from the one of the
+// transport, so faults of concurrent requests don't share it. The lock is only
+// held while picking, so requests are not serialized.
+func (t *FaultTransport) pick(req *http.Request) (*FaultRule, *rand.Rand) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i := range t.rules {
+		rule := &t.rules[i]
+		if rule.Endpoint != "" && !strings.HasSuffix(req.URL.Path, "/"+rule.Endpoint) {
+			continue
+		}
+
+		t.matched[i]++
+		if t.matched[i] <= rule.Skip || (rule.Limit > 0 && t.fired[i] >= rule.Limit) {
+			continue
+		} else if rule.Probability > 0 && t.rng.Float64() >= rule.Probability {
+			continue
+		}
+
+		t.fired[i]++
+		return rule, rand.New(rand.NewSource(t.rng.Int63())) //nolint:gosec // Determinism is desired.
+	}
+
+	return nil, nil
+}
+
+// Fired returns how many times the fault of the rule with the given index has
+// been injected.
+func (t *FaultTransport) Fired(rule int) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.fired[rule]
+}
+
+// ErrorFault fails the request with the given error code. The HTTP status code
+// matches the one the CloudKit Web Services API uses for the error code. If
+// retryAfter is not zero, it is included in the response.
+func ErrorFault(code icloud.ErrorCode, retryAfter time.Duration) Fault {
+	return func(req *http.Request, _ http.RoundTripper, _ *rand.Rand) (*http.Response, error) {
+		apiErr := newAPIError(code, "%s", code.Description())
+
+		var body struct {
+			errorResponse
+
+			RetryAfter string `json:"retryAfter,omitempty"`
+		}
+		body.errorResponse = errorResponse{
+			UUID:   newUUID(),
+			Code:   code,
+			Reason: apiErr.reason,
+		}
+		if retryAfter > 0 {
+			body.RetryAfter = retryAfter.String()
+		}
+
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+
+		return newResponse(req, apiErr.status, "application/json; charset=UTF-8", b), nil
+	}
+}
+
+// Throttled fails the request with a THROTTLED error which can be retried after
+// the given duration.
+func Throttled(retryAfter time.Duration) Fault {
+	return ErrorFault(icloud.Throttled, retryAfter)
+}
+
+// TryAgainLater fails the request with a TRY_AGAIN_LATER error which can be
+// retried after the given duration.
+func TryAgainLater(retryAfter time.Duration) Fault {
+	return ErrorFault(icloud.TryAgainLater, retryAfter)
+}
+
+// BadGateway fails the request with a non JSON 502 response, as returned by a
+// proxy in front of the API.
+func BadGateway() Fault {
+	return func(req *http.Request, _ http.RoundTripper, _ *rand.Rand) (*http.Response, error) {
+		body := []byte("<html><body><h1>502 Bad Gateway</h1></body></html>\n")
+		return newResponse(req, http.StatusBadGateway, "text/html", body), nil
+	}
+}
+
+// Timeout fails the request with an error that reports a timeout, as returned
+// by an http.Transport when a deadline is exceeded.
+func Timeout() Fault {
+	return func(req *http.Request, _ http.RoundTripper, _ *rand.Rand) (*http.Response, error) {
+		closeBody(req)
+		return nil, timeoutError{}
+	}
+}
+
+// PartialFailure passes the request and replaces each record in the response
+// of a successful records/modify request with an error of the given code with
+// the given probability. If probability is zero, all records are replaced.
+// Note that the server still applied the operations of the replaced records.
+func PartialFailure(code icloud.ErrorCode, probability float64) Fault {
+	return func(req *http.Request, next http.RoundTripper, rng *rand.Rand) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusOK || !strings.HasSuffix(req.URL.Path, "/records/modify") {
+			return resp, err
+		}
+		defer resp.Body.Close()
+
+		var body map[string]json.RawMessage
+		if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return nil, err
+		}
+
+		var records []icloud.Record
+		if err = json.Unmarshal(body["records"], &records); err != nil {
+			return nil, err
+		}
+
+		for i, record := range records {
+			if probability > 0 && rng.Float64() >= probability {
+				continue
+			}
+			records[i] = icloud.Record{
+				Name:            record.Name,
+				Reason:          code.Description(),
+				ServerErrorCode: code,
+			}
+		}
+
+		if body["records"], err = json.Marshal(records); err != nil {
+			return nil, err
+		}
+
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+
+		return newResponse(req, resp.StatusCode, resp.Header.Get("content-type"), b), nil
+	}
+}
+
+// timeoutError is returned by the Timeout fault. It implements net.Error.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "icloudtest: injected timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// newResponse returns a response to the request with the given body. The body
+// of the request is closed, as required by http.RoundTripper.
+func newResponse(req *http.Request, status int, contentType string, body []byte) *http.Response {
+	closeBody(req)
+
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Content-Type":   []string{contentType},
+			"Content-Length": []string{strconv.Itoa(len(body))},
+		},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+}
@@ -0,0 +1,196 @@
+package icloudtest_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+	"github.com/lukasmalkmus/icloud-go/icloud/icloudtest"
+)
+
+func TestFaultTransport_Throttled(t *testing.T) {
+	client, transport, teardown := setupFaults(t, 1, icloudtest.FaultRule{
+		Endpoint: "records/modify",
+		Skip:     1,
+		Limit:    1,
+		Fault:    icloudtest.Throttled(3 * time.Second),
+	})
+	defer teardown()
+
+	ctx := context.Background()
+	req := icloud.RecordsRequest{Operations: []icloud.RecordOperation{create("")}}
+
+	_, err := client.Records.Modify(ctx, icloud.Public, req)
+	require.NoError(t, err)
+
+	_, err = client.Records.Modify(ctx, icloud.Public, req)
+	require.Error(t, err)
+
+	var apiErr icloud.Error
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, icloud.Throttled, apiErr.Code)
+	assert.Equal(t, 3*time.Second, apiErr.RetryAfter)
+
+	_, err = client.Records.Modify(ctx, icloud.Public, req)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, transport.Fired(0))
+}
+
+func TestFaultTransport_BadGateway(t *testing.T) {
+	client, _, teardown := setupFaults(t, 1, icloudtest.FaultRule{
+		Fault: icloudtest.BadGateway(),
+	})
+	defer teardown()
+
+	_, err := client.Zones.List(context.Background(), icloud.Public)
+	require.Error(t, err)
+
+	var httpErr *icloud.HTTPError
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusBadGateway, httpErr.StatusCode)
+	assert.Equal(t, http.MethodGet, httpErr.Method)
+	assert.Equal(t, "/database/1/"+container+"/development/public/zones/list", httpErr.Path)
+	assert.Equal(t, "text/html", httpErr.Header.Get("content-type"))
+	assert.Contains(t, string(httpErr.Body), "<h1>502 Bad Gateway</h1>")
+	assert.True(t, icloud.IsRetryable(err))
+}
+
+func TestFaultTransport_Timeout(t *testing.T) {
+	client, _, teardown := setupFaults(t, 1, icloudtest.FaultRule{
+		Fault: icloudtest.Timeout(),
+	})
+	defer teardown()
+
+	_, err := client.Zones.List(context.Background(), icloud.Public)
+	require.Error(t, err)
+
+	var netErr net.Error
+	require.True(t, errors.As(err, &netErr))
+	assert.True(t, netErr.Timeout())
+}
+
+func TestFaultTransport_PartialFailure(t *testing.T) {
+	// The same seed must fail the same operations.
+	var failures [2][]bool
+	for i := range failures {
+		client, _, teardown := setupFaults(t, 42, icloudtest.FaultRule{
+			Endpoint: "records/modify",
+			Fault:    icloudtest.PartialFailure(icloud.Conflict, 0.5),
+		})
+
+		var ops []icloud.RecordOperation
+		for j := 0; j < 20; j++ {
+			ops = append(ops, create(""))
+		}
+
+		res, err := client.Records.Modify(context.Background(), icloud.Public, icloud.RecordsRequest{
+			Operations: ops,
+		})
+		require.NoError(t, err)
+		require.Len(t, res.Records, len(ops))
+
+		for _, record := range res.Records {
+			if err := record.Err(); err != nil {
+				assertErrorCode(t, icloud.Conflict, err)
+				failures[i] = append(failures[i], true)
+			} else {
+				failures[i] = append(failures[i], false)
+			}
+		}
+
+		teardown()
+	}
+
+	assert.Equal(t, failures[0], failures[1])
+	assert.Contains(t, failures[0], true)
+	assert.Contains(t, failures[0], false)
+}
+
+func TestFaultTransport_Concurrent(t *testing.T) {
+	srv := icloudtest.NewServer()
+	defer srv.Close()
+
+	next := &startTransport{
+		RoundTripper: srv.Client().Transport,
+		started:      make(chan string, 8),
+	}
+	transport := icloudtest.NewFaultTransport(next, 1, icloudtest.FaultRule{
+		Endpoint: "records/modify",
+		Fault:    icloudtest.Timeout(),
+	})
+
+	client, err := srv.NewClient(container, icloud.Development,
+		icloud.SetHTTPClient(&http.Client{Transport: transport}),
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	token, err := client.Tokens.Create(ctx, icloud.CreateTokenRequest{
+		APNSEnvironment: icloud.APNSDevelopment,
+	})
+	require.NoError(t, err)
+
+	// A long poll in flight must not block other requests.
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, token.WebcourierURL, nil)
+	require.NoError(t, err)
+	<-next.started
+
+	polled := make(chan error, 1)
+	go func() {
+		resp, pollErr := transport.RoundTrip(req)
+		if pollErr == nil {
+			pollErr = resp.Body.Close()
+		}
+		polled <- pollErr
+	}()
+	require.Equal(t, req.URL.Path, <-next.started)
+
+	_, err = client.Zones.List(ctx, icloud.Public)
+	require.NoError(t, err)
+
+	select {
+	case <-polled:
+		t.Fatal("long poll returned early")
+	default:
+	}
+
+	require.NoError(t, srv.Notify(token.APNSToken, []byte(`{}`)))
+	require.NoError(t, <-polled)
+}
+
+func setupFaults(t *testing.T, seed int64, rules ...icloudtest.FaultRule) (*icloud.Client, *icloudtest.FaultTransport, func()) {
+	t.Helper()
+
+	srv := icloudtest.NewServer()
+
+	transport := icloudtest.NewFaultTransport(srv.Client().Transport, seed, rules...)
+
+	client, err := srv.NewClient(container, icloud.Development,
+		icloud.SetHTTPClient(&http.Client{Transport: transport}),
+	)
+	require.NoError(t, err)
+
+	return client, transport, srv.Close
+}
+
+// startTransport reports the path of every request it passes on.
+type startTransport struct {
+	http.RoundTripper
+
+	started chan string
+}
+
+func (t *startTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.started <- req.URL.Path
+	return t.RoundTripper.RoundTrip(req)
+}
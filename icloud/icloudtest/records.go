@@ -0,0 +1,411 @@
+package icloudtest
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// modifyRecords handles the records/modify endpoint.
+func (s *Server) modifyRecords(db *database, body []byte, user icloud.User) (interface{}, *apiError) {
+	var req icloud.RecordsRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, newAPIError(icloud.BadRequest, "invalid request body: %s", err)
+	} else if l := len(req.Operations); l > icloud.MaxOperationPerRequest {
+		return nil, newAPIError(icloud.BadRequest, "too many operations: %d > %d", l, icloud.MaxOperationPerRequest)
+	}
+
+	z, apiErr := db.zone(req.ZoneID)
+	if apiErr != nil {
+		return nil, apiErr
+	} else if req.Atomic && z.isDefault() {
+		return nil, newAPIError(icloud.BadRequest, "atomic operations are not supported in the default zone")
+	}
+
+	// Operations are applied to a copy of the zone's records which replaces
+	// the original ones, unless an atomic request failed.
+	tx := &transaction{
+		zone:    z,
+		records: make(map[string]*storedRecord, len(z.records)),
+		seq:     z.seq,
+		user:    user,
+	}
+	for name, r := range z.records {
+		tx.records[name] = r
+	}
+
+	var (
+		res    = icloud.RecordsResponse{Records: make([]icloud.Record, len(req.Operations))}
+		failed bool
+	)
+	for i, op := range req.Operations {
+		record, opErr := s.applyOperation(tx, op)
+		if opErr != nil {
+			failed = true
+			record = icloud.Record{
+				Name:            op.Record.Name,
+				Reason:          opErr.reason,
+				ServerErrorCode: opErr.code,
+			}
+		}
+		res.Records[i] = record
+	}
+
+	if req.Atomic && failed {
+		for i, record := range res.Records {
+			if record.Err() == nil {
+				res.Records[i] = icloud.Record{
+					Name:            record.Name,
+					Reason:          "atomic batch operation failed",
+					ServerErrorCode: icloud.AtomicError,
+				}
+			}
+		}
+		return res, nil
+	}
+
+	z.records, z.seq = tx.records, tx.seq
+
+	if req.NumbersAsStrings {
+		numbersAsStrings(res.Records)
+	}
+
+	return res, nil
+}
+
+// transaction is a set of pending changes to the records of a zone.
+type transaction struct {
+	zone    *zone
+	records map[string]*storedRecord
+	seq     int
+	// user the changes are made on behalf of.
+	user icloud.User
+}
+
+// put stores the record and returns its public representation.
+func (tx *transaction) put(record icloud.Record, created int) icloud.Record {
+	tx.seq++
+	if created == 0 {
+		created = tx.seq
+	}
+
+	record.ZoneID = tx.zone.id()
+	tx.records[record.Name] = &storedRecord{
+		record:  record,
+		created: created,
+		seq:     tx.seq,
+	}
+
+	return copyRecord(record)
+}
+
+// applyOperation applies a single record operation to the transaction.
+func (s *Server) applyOperation(tx *transaction, op icloud.RecordOperation) (icloud.Record, *apiError) {
+	var (
+		name         = op.Record.Name
+		existing, ok = tx.records[name]
+		exists       = ok && !existing.record.Deleted
+	)
+
+	switch op.Type {
+	case icloud.Create:
+		if name == "" {
+			name = newUUID()
+		} else if exists {
+			return icloud.Record{}, newAPIError(icloud.Exists, "record %q already exists", name)
+		}
+	case icloud.Update, icloud.Replace, icloud.Delete:
+		if !exists {
+			return icloud.Record{}, newAPIError(icloud.NotFound, "record %q does not exist", name)
+		} else if tag := existing.record.ChangeTag; tag != op.Record.ChangeTag {
+			return icloud.Record{}, newAPIError(icloud.Conflict, "record %q has been modified, current change tag is %q", name, tag)
+		}
+	case icloud.ForceDelete:
+		if !exists {
+			return icloud.Record{}, newAPIError(icloud.NotFound, "record %q does not exist", name)
+		}
+	case icloud.ForceUpdate, icloud.ForceReplace:
+		if name == "" {
+			return icloud.Record{}, newAPIError(icloud.BadRequest, "missing record name")
+		}
+	default:
+		return icloud.Record{}, newAPIError(icloud.BadRequest, "unknown operation type %q", op.RawType())
+	}
+
+	if op.Type == icloud.Delete || op.Type == icloud.ForceDelete {
+		tx.seq++
+		tx.records[name] = &storedRecord{
+			record: icloud.Record{
+				Name:    name,
+				ZoneID:  tx.zone.id(),
+				Deleted: true,
+			},
+			seq: tx.seq,
+		}
+		return tx.records[name].record, nil
+	}
+
+	fields, apiErr := s.storeFields(op.Record.Fields)
+	if apiErr != nil {
+		return icloud.Record{}, apiErr
+	}
+
+	// Creating a record, either explicitly or by force.
+	if !exists {
+		if op.Record.Type == "" {
+			return icloud.Record{}, newAPIError(icloud.BadRequest, "missing record type for record %q", name)
+		}
+		record := icloud.Record{
+			Name:      name,
+			Type:      op.Record.Type,
+			ChangeTag: s.nextID(),
+			Fields:    fields,
+			Parent:    op.Record.Parent,
+			Share:     op.Record.Share,
+		}
+		if record.Type == icloud.ShareRecordType {
+			if apiErr = s.createShare(tx, &record, op.Record); apiErr != nil {
+				return icloud.Record{}, apiErr
+			}
+		}
+		return tx.put(record, 0), nil
+	}
+
+	record := existing.record
+	record.ChangeTag = s.nextID()
+	if op.Type == icloud.Update || op.Type == icloud.ForceUpdate {
+		record.Fields = mergeFields(record.Fields, fields)
+		if op.Record.Parent != nil {
+			record.Parent = op.Record.Parent
+		}
+		if op.Record.Share != nil {
+			record.Share = op.Record.Share
+		}
+	} else {
+		record.Fields = fields
+		record.Parent, record.Share = op.Record.Parent, op.Record.Share
+	}
+	if record.Type == icloud.ShareRecordType {
+		updateShare(&record, op.Record)
+	}
+
+	return tx.put(record, existing.created), nil
+}
+
+// lookupRecords handles the records/lookup endpoint.
+func (s *Server) lookupRecords(db *database, body []byte) (interface{}, *apiError) {
+	var req icloud.LookupRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, newAPIError(icloud.BadRequest, "invalid request body: %s", err)
+	}
+
+	z, apiErr := db.zone(req.ZoneID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	res := icloud.RecordsResponse{Records: make([]icloud.Record, len(req.Records))}
+	for i, r := range req.Records {
+		stored, ok := z.records[r.Name]
+		if !ok || stored.record.Deleted {
+			res.Records[i] = icloud.Record{
+				Name:            r.Name,
+				Reason:          "record not found",
+				ServerErrorCode: icloud.NotFound,
+			}
+			continue
+		}
+		res.Records[i] = project(stored.record, req.DesiredKeys)
+	}
+
+	if req.NumbersAsStrings {
+		numbersAsStrings(res.Records)
+	}
+
+	return res, nil
+}
+
+// queryRecords handles the records/query endpoint.
+func (s *Server) queryRecords(db *database, body []byte) (interface{}, *apiError) {
+	var req icloud.QueryRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, newAPIError(icloud.BadRequest, "invalid request body: %s", err)
+	} else if req.Query.RecordType == "" {
+		return nil, newAPIError(icloud.BadRequest, "missing record type")
+	}
+
+	z, apiErr := db.zone(req.ZoneID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	var offset int
+	if marker := req.ContinuationMarker; marker != "" {
+		var err error
+		if offset, err = decodeToken(marker); err != nil {
+			return nil, newAPIError(icloud.BadRequest, "invalid continuation marker %q", marker)
+		}
+	}
+
+	var matches []icloud.Record
+	for _, stored := range z.sortedRecords() {
+		if stored.record.Type != req.Query.RecordType {
+			continue
+		}
+
+		match := true
+		for _, filter := range req.Query.FilterBy {
+			ok, apiErr := matchFilter(stored.record, filter)
+			if apiErr != nil {
+				return nil, apiErr
+			}
+			match = match && ok
+		}
+
+		if match {
+			matches = append(matches, stored.record)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		for _, sortBy := range req.Query.SortBy {
+			a, _ := fieldValue(matches[i].Fields, sortBy.FieldName)
+			b, _ := fieldValue(matches[j].Fields, sortBy.FieldName)
+			if c, ok := compareValues(a, b); ok && c != 0 {
+				return (c < 0) == sortBy.Ascending
+			}
+		}
+		return false
+	})
+
+	limit := req.ResultsLimit
+	if limit <= 0 || limit > icloud.MaxOperationPerRequest {
+		limit = icloud.MaxOperationPerRequest
+	}
+
+	var res icloud.QueryResponse
+	for i := offset; i < len(matches) && i < offset+limit; i++ {
+		res.Records = append(res.Records, project(matches[i], req.DesiredKeys))
+	}
+	if offset+limit < len(matches) {
+		res.ContinuationMarker = encodeToken(offset + limit)
+	}
+
+	if req.NumbersAsStrings {
+		numbersAsStrings(res.Records)
+	}
+
+	return res, nil
+}
+
+// recordChanges handles the records/changes endpoint.
+func (s *Server) recordChanges(db *database, body []byte) (interface{}, *apiError) {
+	var req icloud.ChangesRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, newAPIError(icloud.BadRequest, "invalid request body: %s", err)
+	}
+
+	z, apiErr := db.zone(&req.ZoneID)
+	if apiErr != nil {
+		return nil, apiErr
+	} else if z.isDefault() {
+		return nil, newAPIError(icloud.BadRequest, "fetching changes is not supported in the default zone")
+	}
+
+	var since int
+	if token := req.SyncToken; token != "" {
+		var err error
+		if since, err = decodeToken(token); err != nil {
+			return nil, newAPIError(icloud.BadRequest, "invalid sync token %q", token)
+		}
+	}
+
+	var changed []*storedRecord
+	for _, stored := range z.records {
+		if stored.seq > since {
+			changed = append(changed, stored)
+		}
+	}
+	sort.Slice(changed, func(i, j int) bool {
+		return changed[i].seq < changed[j].seq
+	})
+
+	limit := req.ResultsLimit
+	if limit <= 0 || limit > icloud.MaxOperationPerRequest {
+		limit = icloud.MaxOperationPerRequest
+	}
+
+	res := icloud.ChangesResponse{
+		ZoneID:    *z.id(),
+		SyncToken: encodeToken(z.seq),
+	}
+	if len(changed) > limit {
+		changed = changed[:limit]
+		res.SyncToken = encodeToken(changed[limit-1].seq)
+		res.MoreComing = true
+	}
+
+	for _, stored := range changed {
+		if stored.record.Deleted {
+			res.Records = append(res.Records, stored.record)
+			continue
+		}
+		res.Records = append(res.Records, project(stored.record, req.DesiredKeys))
+	}
+
+	return res, nil
+}
+
+// matchFilter returns true, if the record matches the query filter.
+func matchFilter(record icloud.Record, filter icloud.Filter) (bool, *apiError) {
+	var (
+		v, ok = fieldValue(record.Fields, filter.FieldName)
+		fv    = filter.FieldValue.Value
+	)
+
+	switch filter.Comparator {
+	case icloud.Equals:
+		return ok && equalValues(v, fv), nil
+	case icloud.NotEquals:
+		return !ok || !equalValues(v, fv), nil
+	case icloud.LessThan, icloud.LessThanOrEquals, icloud.GreaterThan, icloud.GreaterThanOrEquals:
+		c, comparable := compareValues(v, fv)
+		if !ok || !comparable {
+			return false, nil
+		}
+		switch filter.Comparator {
+		case icloud.LessThan:
+			return c < 0, nil
+		case icloud.LessThanOrEquals:
+			return c <= 0, nil
+		case icloud.GreaterThan:
+			return c > 0, nil
+		default:
+			return c >= 0, nil
+		}
+	case icloud.BeginsWith, icloud.NotBeginsWith:
+		s, isString := v.(string)
+		prefix, isPrefixString := fv.(string)
+		if !isPrefixString {
+			return false, newAPIError(icloud.BadRequest, "%s requires a string value", filter.Comparator)
+		}
+		begins := ok && isString && strings.HasPrefix(s, prefix)
+		return begins == (filter.Comparator == icloud.BeginsWith), nil
+	case icloud.In, icloud.NotIn:
+		list, isList := fv.([]interface{})
+		if !isList {
+			return false, newAPIError(icloud.BadRequest, "%s requires a list value", filter.Comparator)
+		}
+		return ok && containsValue(list, v) == (filter.Comparator == icloud.In), nil
+	case icloud.ListContains, icloud.NotListContains:
+		list, isList := v.([]interface{})
+		contains := ok && isList && containsValue(list, fv)
+		return contains == (filter.Comparator == icloud.ListContains), nil
+	case icloud.ComparatorUnknown, icloud.Near, icloud.ContainsAllTokens, icloud.ContainsAnyTokens, icloud.NotListContainsAny,
+		icloud.ListMemberBeginsWith, icloud.NotListMemberBeginsWith, icloud.ListContainsAll, icloud.NotListContainsAll:
+	}
+
+	return false, newAPIError(icloud.BadRequest, "comparator %s is not supported", filter.RawComparator())
+}
@@ -0,0 +1,398 @@
+// Package icloudtest provides an in-memory fake of the CloudKit Web Services
+// API for use in tests.
+//
+// Usage:
+//
+//	srv := icloudtest.NewServer()
+//	defer srv.Close()
+//
+//	client, err := srv.NewClient("iCloud.com.lukasmalkmus.Example-App", icloud.Development)
+//
+// The fake implements the records, zones, assets, tokens and users endpoints
+// used by the icloud package and mimics the semantics of the real API: record
+// change tags, per record errors (CONFLICT, EXISTS, NOT_FOUND), atomic
+// operations in custom zones, sync tokens and continuation markers. The public
+// database only has the default zone. Every request must be signed by a known
+// key.
+//
+// Each key acts as a different user, but all users share a single private
+// database per container and environment. Shares saved to it can be resolved
+// and accepted by other users. Accepted shares are not mirrored into the
+// shared database.
+//
+// Subscriptions are not implemented. Instead, Notify sends a notification to
+// the web courier URL of a created token, where it is received by a pending or
+// the next poll.
+//
+// A FaultTransport injects failures like throttling, server errors, timeouts
+// and partially failed batches into the requests of a client. A Recorder
+// captures the interactions of a client into a Cassette, which a Replayer
+// serves back later on.
+package icloudtest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// MaxClockSkew is the maximum difference between the date a request was
+// signed at and the time the server receives it.
+const MaxClockSkew = 10 * time.Minute
+
+// KeyID is the identifier of the key the server generates on creation.
+const KeyID = "icloudtest"
+
+// DefaultLongPollTimeout is the default time a poll of a web courier URL is
+// held open without a notification arriving.
+const DefaultLongPollTimeout = 30 * time.Second
+
+// Server is an in-memory fake of the CloudKit Web Services API. It is safe for
+// concurrent use.
+type Server struct {
+	// URL of the server. Pass it to icloud.SetBaseURL.
+	URL string
+	// LongPollTimeout is the time a poll of a web courier URL is held open
+	// before the server responds without a notification. It must be set
+	// before the first poll. Defaults to DefaultLongPollTimeout.
+	LongPollTimeout time.Duration
+
+	srv        *httptest.Server
+	privateKey *ecdsa.PrivateKey
+
+	mu        sync.Mutex
+	keys      map[string]*ecdsa.PublicKey
+	databases map[databaseKey]*database
+	uploads   map[string]*upload
+	tokens    map[string]icloud.Token
+	couriers  map[string]chan []byte
+	counter   int64
+}
+
+// NewServer starts and returns a new Server. The caller should call Close when
+// finished, to shut it down.
+func NewServer() *Server {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(fmt.Sprintf("icloudtest: failed to generate key: %v", err))
+	}
+
+	s := &Server{
+		LongPollTimeout: DefaultLongPollTimeout,
+
+		privateKey: privateKey,
+
+		keys: map[string]*ecdsa.PublicKey{
+			KeyID: &privateKey.PublicKey,
+		},
+		databases: make(map[databaseKey]*database),
+		uploads:   make(map[string]*upload),
+		tokens:    make(map[string]icloud.Token),
+		couriers:  make(map[string]chan []byte),
+	}
+
+	s.srv = httptest.NewServer(s)
+	s.URL = s.srv.URL
+
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Client returns an HTTP client configured for making requests to the server.
+func (s *Server) Client() *http.Client {
+	return s.srv.Client()
+}
+
+// PrivateKey returns the private key of the key identified by KeyID.
+func (s *Server) PrivateKey() *ecdsa.PrivateKey {
+	return s.privateKey
+}
+
+// AddKey registers the public key of a server-to-server key. Requests signed by
+// the corresponding private key are accepted by the server.
+func (s *Server) AddKey(keyID string, publicKey *ecdsa.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[keyID] = publicKey
+}
+
+// NewClient returns a new icloud.Client for the given container and
+// environment which talks to the server. It is authenticated with the key
+// identified by KeyID. Additional options are applied after the ones
+// configuring the client for the server.
+func (s *Server) NewClient(container string, environment icloud.Environment, options ...icloud.Option) (*icloud.Client, error) {
+	options = append([]icloud.Option{
+		icloud.SetBaseURL(s.URL),
+		icloud.SetHTTPClient(s.Client()),
+	}, options...)
+
+	return icloud.NewClient(container, KeyID, s.privateKey, environment, options...)
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Asset data is transferred via pre-signed urls and is not authenticated,
+	// just like polls of web courier urls.
+	if id := strings.TrimPrefix(r.URL.Path, "/assets/upload/"); id != r.URL.Path {
+		s.uploadData(w, r, id)
+		return
+	} else if id = strings.TrimPrefix(r.URL.Path, "/assets/download/"); id != r.URL.Path {
+		s.downloadData(w, r, id)
+		return
+	} else if token := strings.TrimPrefix(r.URL.Path, "/webcourier/"); token != r.URL.Path {
+		s.pollCourier(w, r, token)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, icloud.BadRequest, "failed to read request body")
+		return
+	}
+
+	if err = s.authenticate(r, body); err != nil {
+		writeError(w, http.StatusUnauthorized, icloud.AuthenticationFailed, err.Error())
+		return
+	}
+
+	// Path formats:
+	//	/database/{version}/{container}/{environment}/{database}/{service}/{operation}
+	//	/device/{version}/{container}/{environment}/tokens/{operation}
+	// Tokens don't belong to a database, the paths of the device API lack it.
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 7 && parts[0] == "database" && parts[5] != "tokens":
+	case len(parts) == 6 && parts[0] == "device" && parts[4] == "tokens":
+		parts = []string{parts[0], parts[1], parts[2], parts[3], "", parts[4], parts[5]}
+	default:
+		parts = nil
+	}
+	if len(parts) != 7 || parts[1] != "1" {
+		writeError(w, http.StatusNotFound, icloud.NotFound, "unknown endpoint "+r.URL.Path)
+		return
+	}
+
+	dbKey, err := parseDatabaseKey(parts[2], parts[3], parts[4])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, icloud.BadRequest, err.Error())
+		return
+	}
+
+	user := s.currentUser(parts[2], r.Header.Get("x-apple-cloudkit-request-keyid"))
+
+	type handlerFunc func(*database, []byte) (interface{}, *apiError)
+	var handler handlerFunc
+	switch endpoint := r.Method + " " + parts[5] + "/" + parts[6]; endpoint {
+	case "POST records/modify":
+		handler = func(db *database, body []byte) (interface{}, *apiError) { return s.modifyRecords(db, body, user) }
+	case "POST records/lookup":
+		handler = s.lookupRecords
+	case "POST records/query":
+		handler = s.queryRecords
+	case "POST records/changes":
+		handler = s.recordChanges
+	case "POST records/resolve":
+		handler = func(_ *database, body []byte) (interface{}, *apiError) {
+			return s.resolveShares(dbKey, user, body, false)
+		}
+	case "POST records/accept":
+		handler = func(_ *database, body []byte) (interface{}, *apiError) {
+			return s.resolveShares(dbKey, user, body, true)
+		}
+	case "GET zones/list":
+		handler = s.listZones
+	case "POST zones/modify":
+		handler = s.modifyZones
+	case "POST assets/rereference":
+		handler = s.rereferenceAssets
+	case "POST assets/upload":
+		handler = s.requestUpload
+	case "POST tokens/create":
+		handler = s.createToken
+	case "POST tokens/register":
+		handler = s.registerToken
+	case "GET users/current":
+		handler = func(*database, []byte) (interface{}, *apiError) { return user, nil }
+	default:
+		writeError(w, http.StatusNotFound, icloud.NotFound, "unknown endpoint "+endpoint)
+		return
+	}
+
+	s.mu.Lock()
+	var db *database
+	if dbKey.database != 0 {
+		db = s.database(dbKey)
+	}
+	res, apiErr := handler(db, body)
+	s.mu.Unlock()
+
+	if apiErr != nil {
+		writeError(w, apiErr.status, apiErr.code, apiErr.reason)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+// authenticate verifies the signature of the request.
+func (s *Server) authenticate(r *http.Request, body []byte) error {
+	var (
+		keyID        = r.Header.Get("x-apple-cloudkit-request-keyid")
+		dateStr      = r.Header.Get("x-apple-cloudkit-request-iso8601date")
+		signatureStr = r.Header.Get("x-apple-cloudkit-request-signaturev1")
+	)
+
+	s.mu.Lock()
+	publicKey, ok := s.keys[keyID]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown key %q", keyID)
+	}
+
+	date, err := time.Parse(time.RFC3339, dateStr)
+	if err != nil {
+		return fmt.Errorf("invalid request date %q", dateStr)
+	} else if d := time.Since(date); d > MaxClockSkew || d < -MaxClockSkew {
+		return fmt.Errorf("request date %q is out of range", dateStr)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(signatureStr)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	bodyHash := sha256.Sum256(body)
+	msg := dateStr + ":" + base64.StdEncoding.EncodeToString(bodyHash[:]) + ":" + r.URL.Path
+	msgHash := sha256.Sum256([]byte(msg))
+
+	if !ecdsa.VerifyASN1(publicKey, msgHash[:], signature) {
+		return fmt.Errorf("invalid signature for key %q", keyID)
+	}
+
+	return nil
+}
+
+// nextID returns a new, unique identifier.
+func (s *Server) nextID() string {
+	s.counter++
+	return strconv.FormatInt(s.counter, 36)
+}
+
+// apiError is an error returned for a whole request.
+type apiError struct {
+	status int
+	code   icloud.ErrorCode
+	reason string
+}
+
+func newAPIError(code icloud.ErrorCode, format string, a ...interface{}) *apiError {
+	status := http.StatusBadRequest
+	switch code {
+	case icloud.AuthenticationFailed, icloud.AuthenticationRequired:
+		status = http.StatusUnauthorized
+	case icloud.AccessDenied:
+		status = http.StatusForbidden
+	case icloud.NotFound, icloud.ZoneNotFound:
+		status = http.StatusNotFound
+	case icloud.Conflict, icloud.Exists:
+		status = http.StatusConflict
+	case icloud.QuotaExceeded:
+		status = http.StatusPaymentRequired
+	case icloud.Throttled:
+		status = http.StatusTooManyRequests
+	case icloud.InternalError, icloud.Unknown:
+		status = http.StatusInternalServerError
+	case icloud.TryAgainLater:
+		status = http.StatusServiceUnavailable
+	case icloud.ValidatingReferenceError:
+		status = http.StatusPreconditionFailed
+	case icloud.AtomicError, icloud.BadRequest:
+	}
+
+	return &apiError{
+		status: status,
+		code:   code,
+		reason: fmt.Sprintf(format, a...),
+	}
+}
+
+// errorResponse is the body of an error response.
+type errorResponse struct {
+	UUID   string           `json:"uuid"`
+	Code   icloud.ErrorCode `json:"serverErrorCode"`
+	Reason string           `json:"reason"`
+}
+
+func writeError(w http.ResponseWriter, status int, code icloud.ErrorCode, reason string) {
+	uuid := newUUID()
+	w.Header().Set("x-apple-request-uuid", uuid)
+
+	writeJSON(w, status, errorResponse{
+		UUID:   uuid,
+		Code:   code,
+		Reason: reason,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if w.Header().Get("x-apple-request-uuid") == "" {
+		w.Header().Set("x-apple-request-uuid", newUUID())
+	}
+	w.Header().Set("content-type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	_, _ = io.Copy(w, &buf)
+}
+
+// newUUID returns a random, RFC 4122 formatted UUID.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("icloudtest: failed to generate uuid: %v", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	s := hex.EncodeToString(b[:])
+	return strings.ToUpper(s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:])
+}
+
+// encodeToken encodes n as an opaque token used for sync tokens and
+// continuation markers.
+func encodeToken(n int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(n)))
+}
+
+// decodeToken decodes a token created by encodeToken.
+func decodeToken(s string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(b))
+}
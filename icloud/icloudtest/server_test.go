@@ -0,0 +1,712 @@
+package icloudtest_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+	"github.com/lukasmalkmus/icloud-go/icloud/icloudtest"
+)
+
+const container = "iCloud.com.lukasmalkmus.Example-App"
+
+func TestServer_Authentication(t *testing.T) {
+	srv := icloudtest.NewServer()
+	defer srv.Close()
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	client, err := icloud.NewClient(container, "my-key", privateKey, icloud.Development,
+		icloud.SetBaseURL(srv.URL),
+		icloud.SetHTTPClient(srv.Client()),
+	)
+	require.NoError(t, err)
+
+	_, err = client.Zones.List(context.Background(), icloud.Public)
+	assertErrorCode(t, icloud.AuthenticationFailed, err)
+
+	srv.AddKey("my-key", &privateKey.PublicKey)
+
+	_, err = client.Zones.List(context.Background(), icloud.Public)
+	require.NoError(t, err)
+}
+
+func TestServer_Proxy(t *testing.T) {
+	srv := icloudtest.NewServer()
+	defer srv.Close()
+
+	// The proxy strips its path prefix before forwarding the request. The
+	// signature must be valid for the forwarded path.
+	proxy := httptest.NewServer(http.StripPrefix("/cloudkit", srv))
+	defer proxy.Close()
+
+	client, err := srv.NewClient(container, icloud.Development,
+		icloud.SetBaseURL(proxy.URL+"/cloudkit"),
+		icloud.SetHTTPClient(proxy.Client()),
+	)
+	require.NoError(t, err)
+
+	_, err = client.Zones.List(context.Background(), icloud.Public)
+	require.NoError(t, err)
+}
+
+func TestServer_Records_Modify(t *testing.T) {
+	client, teardown := setup(t)
+	defer teardown()
+
+	ctx := context.Background()
+
+	res, err := client.Records.Modify(ctx, icloud.Public, icloud.RecordsRequest{
+		Operations: []icloud.RecordOperation{
+			create("a", icloud.Field{Name: "title", Value: "A"}),
+			create("b"),
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Records, 2)
+	for _, record := range res.Records {
+		require.NoError(t, record.Err())
+		assert.NotEmpty(t, record.ChangeTag)
+		assert.Equal(t, icloud.DefaultZoneName, record.ZoneID.Name)
+	}
+	a := res.Records[0]
+	assert.Equal(t, icloud.Fields{{Name: "title", Type: "STRING", Value: "A"}}, a.Fields)
+
+	res, err = client.Records.Modify(ctx, icloud.Public, icloud.RecordsRequest{
+		Operations: []icloud.RecordOperation{
+			create("a"),
+			{Type: icloud.Update, Record: icloud.Record{Name: "b"}},
+			{Type: icloud.Update, Record: icloud.Record{Name: "c"}},
+			{Type: icloud.Update, Record: icloud.Record{
+				Name:      "a",
+				ChangeTag: a.ChangeTag,
+				Fields:    icloud.Fields{{Name: "count", Value: 1}},
+			}},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Records, 4)
+	assertErrorCode(t, icloud.Exists, res.Records[0].Err())
+	assertErrorCode(t, icloud.Conflict, res.Records[1].Err())
+	assertErrorCode(t, icloud.NotFound, res.Records[2].Err())
+	require.NoError(t, res.Records[3].Err())
+	assert.NotEqual(t, a.ChangeTag, res.Records[3].ChangeTag)
+	assert.Len(t, res.Records[3].Fields, 2)
+
+	res, err = client.Records.Modify(ctx, icloud.Public, icloud.RecordsRequest{
+		Operations: []icloud.RecordOperation{
+			{Type: icloud.ForceDelete, Record: icloud.Record{Name: "a"}},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Records, 1)
+	assert.True(t, res.Records[0].Deleted)
+
+	res, err = client.Records.Lookup(ctx, icloud.Public, icloud.LookupRequest{
+		Records: []icloud.Record{{Name: "a"}, {Name: "b"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Records, 2)
+	assertErrorCode(t, icloud.NotFound, res.Records[0].Err())
+	require.NoError(t, res.Records[1].Err())
+	assert.Equal(t, "MyRecord", res.Records[1].Type)
+}
+
+func TestServer_Records_Modify_Atomic(t *testing.T) {
+	client, teardown := setup(t)
+	defer teardown()
+
+	ctx := context.Background()
+
+	_, err := client.Records.Modify(ctx, icloud.Private, icloud.RecordsRequest{
+		Operations: []icloud.RecordOperation{create("a")},
+		Atomic:     true,
+	})
+	assertErrorCode(t, icloud.BadRequest, err)
+
+	zoneID := createZone(t, client, "MyZone")
+
+	res, err := client.Records.Modify(ctx, icloud.Private, icloud.RecordsRequest{
+		ZoneID:     &zoneID,
+		Operations: []icloud.RecordOperation{create("a"), create("a")},
+		Atomic:     true,
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Records, 2)
+	assertErrorCode(t, icloud.AtomicError, res.Records[0].Err())
+	assertErrorCode(t, icloud.Exists, res.Records[1].Err())
+
+	res, err = client.Records.Lookup(ctx, icloud.Private, icloud.LookupRequest{
+		ZoneID:  &zoneID,
+		Records: []icloud.Record{{Name: "a"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Records, 1)
+	assertErrorCode(t, icloud.NotFound, res.Records[0].Err())
+}
+
+func TestServer_Records_Query(t *testing.T) {
+	client, teardown := setup(t)
+	defer teardown()
+
+	ctx := context.Background()
+
+	var ops []icloud.RecordOperation
+	for i, name := range []string{"d", "a", "c", "b", "e"} {
+		ops = append(ops, create(name,
+			icloud.Field{Name: "name", Value: name},
+			icloud.Field{Name: "index", Value: i},
+		))
+	}
+	_, err := client.Records.Modify(ctx, icloud.Public, icloud.RecordsRequest{Operations: ops})
+	require.NoError(t, err)
+
+	req := icloud.QueryRequest{
+		ResultsLimit: 2,
+		Query: icloud.Query{
+			RecordType: "MyRecord",
+			FilterBy: []icloud.Filter{
+				{
+					Comparator: icloud.NotEquals,
+					FieldName:  "name",
+					FieldValue: icloud.FieldValue{Value: "c"},
+				},
+			},
+			SortBy: []icloud.Sort{{FieldName: "name", Ascending: true}},
+		},
+		DesiredKeys: []string{"name"},
+	}
+
+	var names []string
+	for {
+		res, err := client.Records.Query(ctx, icloud.Public, req)
+		require.NoError(t, err)
+
+		for _, record := range res.Records {
+			require.Len(t, record.Fields, 1)
+			names = append(names, record.Fields[0].Value.(string))
+		}
+
+		if res.ContinuationMarker == "" {
+			break
+		}
+		req.ContinuationMarker = res.ContinuationMarker
+	}
+	assert.Equal(t, []string{"a", "b", "d", "e"}, names)
+
+	req = icloud.QueryRequest{
+		Query: icloud.Query{
+			RecordType: "MyRecord",
+			FilterBy: []icloud.Filter{
+				{
+					Comparator: icloud.Near,
+					FieldName:  "location",
+				},
+			},
+		},
+	}
+	_, err = client.Records.Query(ctx, icloud.Public, req)
+	assertErrorCode(t, icloud.BadRequest, err)
+}
+
+func TestServer_Records_NumbersAsStrings(t *testing.T) {
+	client, teardown := setup(t)
+	defer teardown()
+
+	ctx := context.Background()
+
+	// 2^53 + 1 can't be represented as float64.
+	const big = int64(9007199254740993)
+
+	res, err := client.Records.Modify(ctx, icloud.Public, icloud.RecordsRequest{
+		Operations: []icloud.RecordOperation{
+			create("a",
+				icloud.Field{Name: "count", Type: "INT64", Value: big},
+				icloud.Field{Name: "counts", Type: "INT64_LIST", Value: []int64{1, big}},
+				icloud.Field{Name: "ratio", Type: "DOUBLE", Value: 0.5},
+				icloud.Field{Name: "published", Type: "TIMESTAMP", Value: int64(1609459200000)},
+			),
+			create("b", icloud.Field{Name: "count", Type: "INT64", Value: big - 1}),
+		},
+		NumbersAsStrings: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Records, 2)
+	assert.Equal(t, icloud.Fields{{Name: "count", Type: "INT64", Value: big - 1}}, res.Records[1].Fields)
+
+	for _, numbersAsStrings := range []bool{false, true} {
+		lookupRes, err := client.Records.Lookup(ctx, icloud.Public, icloud.LookupRequest{
+			Records:          []icloud.Record{{Name: "a"}},
+			NumbersAsStrings: numbersAsStrings,
+		})
+		require.NoError(t, err)
+		require.Len(t, lookupRes.Records, 1)
+
+		values := make(map[string]interface{})
+		for _, field := range lookupRes.Records[0].Fields {
+			values[field.Name] = field.Value
+		}
+		assert.Equal(t, map[string]interface{}{
+			"count":     big,
+			"counts":    []interface{}{int64(1), big},
+			"ratio":     0.5,
+			"published": float64(1609459200000),
+		}, values)
+	}
+
+	queryRes, err := client.Records.Query(ctx, icloud.Public, icloud.QueryRequest{
+		Query: icloud.Query{
+			RecordType: "MyRecord",
+			FilterBy: []icloud.Filter{
+				{
+					Comparator: icloud.Equals,
+					FieldName:  "count",
+					FieldValue: icloud.FieldValue{Type: "INT64", Value: big},
+				},
+			},
+		},
+		DesiredKeys:      []string{"count"},
+		NumbersAsStrings: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, queryRes.Records, 1)
+	assert.Equal(t, "a", queryRes.Records[0].Name)
+	assert.Equal(t, big, queryRes.Records[0].Fields[0].Value)
+}
+
+func TestServer_Records_Changes(t *testing.T) {
+	client, teardown := setup(t)
+	defer teardown()
+
+	ctx := context.Background()
+
+	_, err := client.Records.Changes(ctx, icloud.Private, icloud.ChangesRequest{
+		ZoneID: icloud.ZoneID{Name: icloud.DefaultZoneName},
+	})
+	assertErrorCode(t, icloud.BadRequest, err)
+
+	zoneID := createZone(t, client, "MyZone")
+
+	_, err = client.Records.Modify(ctx, icloud.Private, icloud.RecordsRequest{
+		ZoneID:     &zoneID,
+		Operations: []icloud.RecordOperation{create("a"), create("b"), create("c")},
+	})
+	require.NoError(t, err)
+
+	res, err := client.Records.Changes(ctx, icloud.Private, icloud.ChangesRequest{
+		ZoneID:       zoneID,
+		ResultsLimit: 2,
+	})
+	require.NoError(t, err)
+	assert.True(t, res.MoreComing)
+	assert.Len(t, res.Records, 2)
+
+	res, err = client.Records.Changes(ctx, icloud.Private, icloud.ChangesRequest{
+		ZoneID:    zoneID,
+		SyncToken: res.SyncToken,
+	})
+	require.NoError(t, err)
+	assert.False(t, res.MoreComing)
+	require.Len(t, res.Records, 1)
+	assert.Equal(t, "c", res.Records[0].Name)
+
+	_, err = client.Records.Modify(ctx, icloud.Private, icloud.RecordsRequest{
+		ZoneID: &zoneID,
+		Operations: []icloud.RecordOperation{
+			{Type: icloud.ForceDelete, Record: icloud.Record{Name: "a"}},
+		},
+	})
+	require.NoError(t, err)
+
+	res, err = client.Records.Changes(ctx, icloud.Private, icloud.ChangesRequest{
+		ZoneID:    zoneID,
+		SyncToken: res.SyncToken,
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Records, 1)
+	assert.Equal(t, "a", res.Records[0].Name)
+	assert.True(t, res.Records[0].Deleted)
+}
+
+func TestServer_Zones(t *testing.T) {
+	client, teardown := setup(t)
+	defer teardown()
+
+	ctx := context.Background()
+
+	res, err := client.Zones.Modify(ctx, icloud.Public, icloud.ZonesRequest{
+		Operations: []icloud.ZoneOperation{
+			{Type: icloud.Create, Zone: icloud.Zone{ZoneID: icloud.ZoneID{Name: "MyZone"}}},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Zones, 1)
+	assertErrorCode(t, icloud.BadRequest, res.Zones[0].Err())
+
+	createZone(t, client, "MyZone")
+
+	res, err = client.Zones.List(ctx, icloud.Private)
+	require.NoError(t, err)
+	require.Len(t, res.Zones, 2)
+	assert.Equal(t, "MyZone", res.Zones[0].ZoneID.Name)
+	assert.True(t, res.Zones[0].Atomic)
+	assert.Equal(t, icloud.DefaultZoneName, res.Zones[1].ZoneID.Name)
+
+	res, err = client.Zones.Modify(ctx, icloud.Private, icloud.ZonesRequest{
+		Operations: []icloud.ZoneOperation{
+			{Type: icloud.Create, Zone: icloud.Zone{ZoneID: icloud.ZoneID{Name: "MyZone"}}},
+			{Type: icloud.Delete, Zone: icloud.Zone{ZoneID: icloud.ZoneID{Name: "MyZone"}}},
+			{Type: icloud.Delete, Zone: icloud.Zone{ZoneID: icloud.ZoneID{Name: "MyZone"}}},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Zones, 3)
+	assertErrorCode(t, icloud.Exists, res.Zones[0].Err())
+	assert.True(t, res.Zones[1].Deleted)
+	assertErrorCode(t, icloud.ZoneNotFound, res.Zones[2].Err())
+}
+
+func TestServer_Users(t *testing.T) {
+	client, teardown := setup(t)
+	defer teardown()
+
+	user, err := client.Users.Current(context.Background())
+	require.NoError(t, err)
+	assert.Regexp(t, "^_[0-9a-f]{32}$", user.RecordName)
+
+	other, err := client.Users.Current(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, user, other)
+}
+
+func TestServer_Tokens(t *testing.T) {
+	client, teardown := setup(t)
+	defer teardown()
+
+	ctx := context.Background()
+
+	token, err := client.Tokens.Create(ctx, icloud.CreateTokenRequest{APNSEnvironment: icloud.APNSDevelopment})
+	require.NoError(t, err)
+	assert.Equal(t, icloud.APNSDevelopment, token.APNSEnvironment)
+	assert.Regexp(t, "^[0-9a-f]{64}$", token.APNSToken)
+	assert.True(t, strings.HasSuffix(token.WebcourierURL, "/webcourier/"+token.APNSToken), token.WebcourierURL)
+
+	_, err = client.Tokens.Create(ctx, icloud.CreateTokenRequest{})
+	assertErrorCode(t, icloud.BadRequest, err)
+
+	registered, err := client.Tokens.Register(ctx, icloud.RegisterTokenRequest{
+		APNSEnvironment: icloud.APNSProduction,
+		APNSToken:       "cafe",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &icloud.Token{APNSEnvironment: icloud.APNSProduction, APNSToken: "cafe"}, registered)
+
+	_, err = client.Tokens.Register(ctx, icloud.RegisterTokenRequest{
+		APNSEnvironment: icloud.APNSProduction,
+		APNSToken:       "not a token",
+	})
+	assertErrorCode(t, icloud.BadRequest, err)
+}
+
+func TestServer_Notify(t *testing.T) {
+	srv := icloudtest.NewServer()
+	defer srv.Close()
+	srv.LongPollTimeout = 10 * time.Millisecond
+
+	client, err := srv.NewClient(container, icloud.Development)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	token, err := client.Tokens.Create(ctx, icloud.CreateTokenRequest{APNSEnvironment: icloud.APNSDevelopment})
+	require.NoError(t, err)
+
+	l, err := icloud.NewNotificationListener(client, token.WebcourierURL)
+	require.NoError(t, err)
+	l.OnError = func(err error) { t.Error(err) }
+
+	notifications := l.Notifications(ctx)
+
+	// Let a few polls time out before sending the notifications.
+	time.Sleep(50 * time.Millisecond)
+
+	for _, id := range []string{"first", "second"} {
+		payload := `{"ck": {"cid": "` + container + `", "nid": "` + id + `", "met": {"dbs": 2, "sid": "changes"}}}`
+		require.NoError(t, srv.Notify(token.APNSToken, []byte(payload)))
+	}
+
+	for _, id := range []string{"first", "second"} {
+		n := <-notifications
+		require.NotNil(t, n)
+		assert.Equal(t, icloud.NotificationHeader{
+			ContainerID:    container,
+			NotificationID: id,
+			SubscriptionID: "changes",
+			Database:       icloud.Private,
+		}, n.Header())
+	}
+
+	cancel()
+	_, ok := <-notifications
+	assert.False(t, ok)
+
+	assert.EqualError(t, srv.Notify("cafe", nil), `icloudtest: unknown token "cafe"`)
+}
+
+func TestServer_Sharing(t *testing.T) {
+	srv := icloudtest.NewServer()
+	defer srv.Close()
+
+	alice, err := srv.NewClient(container, icloud.Development)
+	require.NoError(t, err)
+
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	srv.AddKey("bob", &privateKey.PublicKey)
+	bob, err := icloud.NewClient(container, "bob", privateKey, icloud.Development,
+		icloud.SetBaseURL(srv.URL),
+		icloud.SetHTTPClient(srv.Client()),
+	)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	bobUser, err := bob.Users.Current(ctx)
+	require.NoError(t, err)
+
+	zoneID := createZone(t, alice, "Shared")
+
+	root := icloud.Record{Name: "list", Type: "List"}
+	share := icloud.NewShare(&root, "")
+	share.Participants = []icloud.Participant{{
+		UserIdentity: icloud.UserIdentity{
+			LookupInfo: &icloud.LookupInfo{UserRecordName: bobUser.RecordName},
+		},
+		Permission: icloud.PermissionReadWrite,
+	}}
+
+	res, err := alice.Records.Modify(ctx, icloud.Public, icloud.RecordsRequest{
+		Operations: []icloud.RecordOperation{{Type: icloud.Create, Record: share}},
+	})
+	require.NoError(t, err)
+	assertErrorCode(t, icloud.BadRequest, res.Records[0].Err())
+
+	res, err = alice.Records.Modify(ctx, icloud.Private, icloud.RecordsRequest{
+		ZoneID: &zoneID,
+		Operations: []icloud.RecordOperation{
+			{Type: icloud.Create, Record: root},
+			{Type: icloud.Create, Record: share},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Records, 2)
+	require.NoError(t, res.Records[1].Err())
+	assert.Equal(t, &icloud.Reference{RecordName: "Share-list"}, res.Records[0].Share)
+
+	saved := res.Records[1]
+	require.NotEmpty(t, saved.ShortGUID)
+	assert.Equal(t, icloud.PermissionNone, saved.PublicPermission)
+	assert.Equal(t, icloud.ParticipantOwner, saved.Owner.Type)
+	require.Len(t, saved.Participants, 1)
+	assert.Equal(t, bobUser.RecordName, saved.Participants[0].UserIdentity.UserRecordName)
+	assert.Equal(t, icloud.ParticipantUser, saved.Participants[0].Type)
+	assert.Equal(t, icloud.StatusPending, saved.Participants[0].AcceptanceStatus)
+
+	req := icloud.ShareRequest{ShortGUIDs: []icloud.ShortGUID{
+		{Value: saved.ShortGUID, ShouldFetchRootRecord: true},
+		{Value: "unknown"},
+	}}
+
+	resolveRes, err := bob.Records.Resolve(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, resolveRes.Results, 2)
+	metadata := resolveRes.Results[0]
+	require.NoError(t, metadata.Err())
+	assert.Equal(t, container, metadata.ContainerIdentifier)
+	assert.Equal(t, "list", metadata.RootRecordName)
+	assert.Equal(t, "list", metadata.RootRecord.Name)
+	assert.Equal(t, icloud.PermissionReadWrite, metadata.ParticipantPermission)
+	assert.Equal(t, icloud.StatusPending, metadata.ParticipantStatus)
+	assertErrorCode(t, icloud.NotFound, resolveRes.Results[1].Err())
+
+	acceptRes, err := bob.Records.Accept(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, acceptRes.Results, 2)
+	require.NoError(t, acceptRes.Results[0].Err())
+	assert.Equal(t, icloud.StatusAccepted, acceptRes.Results[0].ParticipantStatus)
+	assert.Equal(t, icloud.StatusAccepted, acceptRes.Results[0].Share.Participants[0].AcceptanceStatus)
+
+	// Replacing the participant revokes access.
+	saved = *acceptRes.Results[0].Share
+	saved.Participants = []icloud.Participant{{
+		UserIdentity: icloud.UserIdentity{
+			LookupInfo: &icloud.LookupInfo{EmailAddress: "carol@example.com"},
+		},
+	}}
+	res, err = alice.Records.Modify(ctx, icloud.Private, icloud.RecordsRequest{
+		ZoneID:     &zoneID,
+		Operations: []icloud.RecordOperation{{Type: icloud.Update, Record: saved}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, res.Records[0].Err())
+	require.Len(t, res.Records[0].Participants, 1)
+	assert.Equal(t, icloud.PermissionReadOnly, res.Records[0].Participants[0].Permission)
+	assert.Equal(t, icloud.StatusPending, res.Records[0].Participants[0].AcceptanceStatus)
+
+	resolveRes, err = bob.Records.Resolve(ctx, req)
+	require.NoError(t, err)
+	assertErrorCode(t, icloud.AccessDenied, resolveRes.Results[0].Err())
+}
+
+func TestServer_Assets(t *testing.T) {
+	client, teardown := setup(t)
+	defer teardown()
+
+	ctx := context.Background()
+
+	uploadRes, err := client.Assets.Upload(ctx, icloud.Public, icloud.UploadRequest{
+		Tokens: []icloud.UploadToken{
+			{RecordName: "a", RecordType: "MyRecord", FieldName: "image"},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, uploadRes.Tokens, 1)
+
+	data := strings.Repeat("icloud-go", 100)
+	asset, err := client.Assets.UploadData(ctx, uploadRes.Tokens[0].URL, strings.NewReader(data))
+	require.NoError(t, err)
+	assert.EqualValues(t, len(data), asset.Size)
+	assert.NotEmpty(t, asset.Receipt)
+
+	_, err = client.Records.Modify(ctx, icloud.Public, icloud.RecordsRequest{
+		Operations: []icloud.RecordOperation{
+			create("a", icloud.Field{Name: "image", Type: "ASSETID", Value: asset}),
+		},
+	})
+	require.NoError(t, err)
+
+	res, err := client.Records.Lookup(ctx, icloud.Public, icloud.LookupRequest{
+		Records: []icloud.Record{{Name: "a"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Records, 1)
+	require.Len(t, res.Records[0].Fields, 1)
+
+	value, ok := res.Records[0].Fields[0].Value.(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, asset.FileChecksum, value["fileChecksum"])
+	assert.Nil(t, value["receipt"])
+
+	var buf bytes.Buffer
+	err = client.Assets.Download(ctx, icloud.Asset{DownloadURL: value["downloadURL"].(string)}, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, data, buf.String())
+
+	b, err := json.Marshal(value)
+	require.NoError(t, err)
+	var existing icloud.Asset
+	require.NoError(t, json.Unmarshal(b, &existing))
+
+	rereferenceRes, err := client.Assets.Rereference(ctx, icloud.Public, icloud.RereferenceRequest{
+		Assets: []icloud.AssetReference{
+			{RecordName: "b", RecordType: "MyRecord", FieldName: "image", Asset: existing},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, rereferenceRes.Assets, 1)
+	assert.Equal(t, "b", rereferenceRes.Assets[0].RecordName)
+	assert.Equal(t, asset.FileChecksum, rereferenceRes.Assets[0].FileChecksum)
+	assert.NotEmpty(t, rereferenceRes.Assets[0].Receipt)
+
+	_, err = client.Records.Modify(ctx, icloud.Public, icloud.RecordsRequest{
+		Operations: []icloud.RecordOperation{
+			create("b", icloud.Field{Name: "image", Type: "ASSETID", Value: rereferenceRes.Assets[0].Asset}),
+		},
+	})
+	require.NoError(t, err)
+
+	res, err = client.Records.Lookup(ctx, icloud.Public, icloud.LookupRequest{
+		Records: []icloud.Record{{Name: "b"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Records, 1)
+	require.Len(t, res.Records[0].Fields, 1)
+
+	value, ok = res.Records[0].Fields[0].Value.(map[string]interface{})
+	require.True(t, ok)
+	assert.NotEqual(t, existing.DownloadURL, value["downloadURL"])
+
+	buf.Reset()
+	err = client.Assets.Download(ctx, icloud.Asset{DownloadURL: value["downloadURL"].(string)}, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, data, buf.String())
+
+	_, err = client.Assets.Rereference(ctx, icloud.Public, icloud.RereferenceRequest{
+		Assets: []icloud.AssetReference{
+			{RecordName: "c", FieldName: "image", Asset: icloud.Asset{FileChecksum: "unknown"}},
+		},
+	})
+	assertErrorCode(t, icloud.BadRequest, err)
+}
+
+func setup(t *testing.T) (*icloud.Client, func()) {
+	t.Helper()
+
+	srv := icloudtest.NewServer()
+
+	client, err := srv.NewClient(container, icloud.Development)
+	require.NoError(t, err)
+
+	return client, srv.Close
+}
+
+func create(name string, fields ...icloud.Field) icloud.RecordOperation {
+	return icloud.RecordOperation{
+		Type: icloud.Create,
+		Record: icloud.Record{
+			Name:   name,
+			Type:   "MyRecord",
+			Fields: fields,
+		},
+	}
+}
+
+func createZone(t *testing.T, client *icloud.Client, name string) icloud.ZoneID {
+	t.Helper()
+
+	zoneID := icloud.ZoneID{Name: name}
+	res, err := client.Zones.Modify(context.Background(), icloud.Private, icloud.ZonesRequest{
+		Operations: []icloud.ZoneOperation{
+			{Type: icloud.Create, Zone: icloud.Zone{ZoneID: zoneID}},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Zones, 1)
+	require.NoError(t, res.Zones[0].Err())
+
+	return zoneID
+}
+
+func assertErrorCode(t *testing.T, exp icloud.ErrorCode, err error) {
+	t.Helper()
+
+	var apiErr icloud.Error
+	if assert.True(t, errors.As(err, &apiErr), "expected icloud.Error, got %v", err) {
+		assert.Equal(t, exp, apiErr.Code, apiErr.Reason)
+	}
+}
@@ -0,0 +1,202 @@
+package icloudtest
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// createShare sets up a share record about to be created on behalf of the
+// user of the transaction. Shares are only supported in custom zones.
+func (s *Server) createShare(tx *transaction, record *icloud.Record, req icloud.Record) *apiError {
+	if tx.zone.isDefault() {
+		return newAPIError(icloud.BadRequest, "shares are not supported in the default zone")
+	}
+
+	record.ShortGUID = strings.ToLower(strings.ReplaceAll(newUUID(), "-", ""))[:20]
+	record.Owner = &icloud.Participant{
+		UserIdentity:     icloud.UserIdentity{UserRecordName: tx.user.RecordName},
+		Type:             icloud.ParticipantOwner,
+		Permission:       icloud.PermissionReadWrite,
+		AcceptanceStatus: icloud.StatusAccepted,
+	}
+	record.PublicPermission = icloud.PermissionNone
+	updateShare(record, req)
+
+	return nil
+}
+
+// updateShare applies the public permission and the participants of the
+// requested share record to the stored one. Participants that are part of the
+// share already keep their type and acceptance status.
+func updateShare(record *icloud.Record, req icloud.Record) {
+	if req.PublicPermission != 0 {
+		record.PublicPermission = req.PublicPermission
+	}
+	if req.Participants == nil {
+		return
+	}
+
+	participants := make([]icloud.Participant, 0, len(req.Participants))
+	for _, p := range req.Participants {
+		if p.UserIdentity.UserRecordName == "" && p.UserIdentity.LookupInfo != nil {
+			p.UserIdentity.UserRecordName = p.UserIdentity.LookupInfo.UserRecordName
+		}
+		if p.Permission == 0 {
+			p.Permission = icloud.PermissionReadOnly
+		}
+		if p.Type != icloud.ParticipantAdministrator {
+			p.Type = icloud.ParticipantUser
+		}
+		p.AcceptanceStatus = icloud.StatusPending
+
+		for _, existing := range record.Participants {
+			if sameUser(existing.UserIdentity, p.UserIdentity) {
+				p.Type, p.AcceptanceStatus = existing.Type, existing.AcceptanceStatus
+				break
+			}
+		}
+
+		participants = append(participants, p)
+	}
+	record.Participants = participants
+}
+
+// sameUser returns true, if both identities identify the same user.
+func sameUser(a, b icloud.UserIdentity) bool {
+	if a.UserRecordName != "" || b.UserRecordName != "" {
+		return a.UserRecordName == b.UserRecordName
+	}
+	return a.LookupInfo != nil && b.LookupInfo != nil && *a.LookupInfo == *b.LookupInfo
+}
+
+// resolveShares handles the records/resolve and records/accept endpoints.
+// Shares are looked up in the private database of the container and
+// environment identified by key.
+func (s *Server) resolveShares(key databaseKey, user icloud.User, body []byte, accept bool) (interface{}, *apiError) {
+	var req icloud.ShareRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, newAPIError(icloud.BadRequest, "invalid request body: %s", err)
+	} else if len(req.ShortGUIDs) == 0 {
+		return nil, newAPIError(icloud.BadRequest, "missing short GUIDs")
+	}
+
+	key.database = icloud.Private
+	db := s.database(key)
+
+	res := icloud.ShareResponse{Results: make([]icloud.ShareMetadata, len(req.ShortGUIDs))}
+	for i, shortGUID := range req.ShortGUIDs {
+		metadata, apiErr := s.resolveShare(db, user, shortGUID, accept)
+		if apiErr != nil {
+			metadata = icloud.ShareMetadata{
+				ShortGUID:       shortGUID.Value,
+				Reason:          apiErr.reason,
+				ServerErrorCode: apiErr.code,
+			}
+		}
+		metadata.ContainerIdentifier = key.container
+		metadata.Environment = key.environment.String()
+		res.Results[i] = metadata
+	}
+
+	return res, nil
+}
+
+// resolveShare returns the metadata of the share identified by the short GUID
+// for the user. If accept is true, the user accepts the share.
+func (s *Server) resolveShare(db *database, user icloud.User, shortGUID icloud.ShortGUID, accept bool) (icloud.ShareMetadata, *apiError) {
+	z, stored := findShare(db, shortGUID.Value)
+	if stored == nil {
+		return icloud.ShareMetadata{}, newAPIError(icloud.NotFound, "share %q not found", shortGUID.Value)
+	}
+	share := copyRecord(stored.record)
+
+	var participant icloud.Participant
+	switch i := participantIndex(share.Participants, user); {
+	case share.Owner != nil && share.Owner.UserIdentity.UserRecordName == user.RecordName:
+		participant = *share.Owner
+	case i >= 0:
+		participant = share.Participants[i]
+		if accept && participant.AcceptanceStatus != icloud.StatusAccepted {
+			participant.AcceptanceStatus = icloud.StatusAccepted
+			share.Participants[i] = participant
+			share = s.storeShare(z, stored, share)
+		}
+	case share.PublicPermission == icloud.PermissionReadOnly || share.PublicPermission == icloud.PermissionReadWrite:
+		participant = icloud.Participant{
+			UserIdentity:     icloud.UserIdentity{UserRecordName: user.RecordName},
+			Type:             icloud.ParticipantPublicUser,
+			Permission:       share.PublicPermission,
+			AcceptanceStatus: icloud.StatusPending,
+		}
+		if accept {
+			participant.AcceptanceStatus = icloud.StatusAccepted
+			share.Participants = append(share.Participants, participant)
+			share = s.storeShare(z, stored, share)
+		}
+	default:
+		return icloud.ShareMetadata{}, newAPIError(icloud.AccessDenied, "user %q is not a participant of share %q", user.RecordName, shortGUID.Value)
+	}
+
+	metadata := icloud.ShareMetadata{
+		ShortGUID:             shortGUID.Value,
+		ZoneID:                z.id(),
+		Share:                 &share,
+		ParticipantType:       participant.Type,
+		ParticipantPermission: participant.Permission,
+		ParticipantStatus:     participant.AcceptanceStatus,
+	}
+	if share.Owner != nil {
+		ownerIdentity := share.Owner.UserIdentity
+		metadata.OwnerIdentity = &ownerIdentity
+	}
+	for _, r := range z.records {
+		if !r.record.Deleted && r.record.Share != nil && r.record.Share.RecordName == share.Name {
+			metadata.RootRecordName = r.record.Name
+			if shortGUID.ShouldFetchRootRecord {
+				root := copyRecord(r.record)
+				metadata.RootRecord = &root
+			}
+			break
+		}
+	}
+
+	return metadata, nil
+}
+
+// findShare returns the share record with the given short GUID and its zone.
+func findShare(db *database, shortGUID string) (*zone, *storedRecord) {
+	for _, z := range db.zones {
+		for _, stored := range z.records {
+			if !stored.record.Deleted && stored.record.Type == icloud.ShareRecordType && stored.record.ShortGUID == shortGUID {
+				return z, stored
+			}
+		}
+	}
+	return nil, nil
+}
+
+// participantIndex returns the index of the user in the participants or -1,
+// if the user isn't a participant. Removed participants are ignored.
+func participantIndex(participants []icloud.Participant, user icloud.User) int {
+	for i, p := range participants {
+		if p.UserIdentity.UserRecordName == user.RecordName && p.AcceptanceStatus != icloud.StatusRemoved {
+			return i
+		}
+	}
+	return -1
+}
+
+// storeShare replaces the stored share record with the changed one and
+// returns its public representation.
+func (s *Server) storeShare(z *zone, stored *storedRecord, share icloud.Record) icloud.Record {
+	z.seq++
+	share.ChangeTag = s.nextID()
+	z.records[share.Name] = &storedRecord{
+		record:  share,
+		created: stored.created,
+		seq:     z.seq,
+	}
+	return copyRecord(share)
+}
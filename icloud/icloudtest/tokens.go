@@ -0,0 +1,127 @@
+package icloudtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// createToken handles the tokens/create endpoint. Tokens are random and
+// notifications for them are served below /webcourier/ of the server.
+func (s *Server) createToken(_ *database, body []byte) (interface{}, *apiError) {
+	var req icloud.CreateTokenRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, newAPIError(icloud.BadRequest, "invalid request body: %s", err)
+	} else if req.APNSEnvironment == 0 {
+		return nil, newAPIError(icloud.BadRequest, "missing APNs environment")
+	}
+
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("icloudtest: failed to generate token: %v", err))
+	}
+
+	token := icloud.Token{
+		APNSEnvironment: req.APNSEnvironment,
+		APNSToken:       hex.EncodeToString(b[:]),
+	}
+	token.WebcourierURL = s.URL + "/webcourier/" + token.APNSToken
+	s.tokens[token.APNSToken] = token
+
+	return token, nil
+}
+
+// registerToken handles the tokens/register endpoint.
+func (s *Server) registerToken(_ *database, body []byte) (interface{}, *apiError) {
+	var req icloud.RegisterTokenRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, newAPIError(icloud.BadRequest, "invalid request body: %s", err)
+	} else if req.APNSEnvironment == 0 {
+		return nil, newAPIError(icloud.BadRequest, "missing APNs environment")
+	} else if _, err = hex.DecodeString(req.APNSToken); err != nil || req.APNSToken == "" {
+		return nil, newAPIError(icloud.BadRequest, "invalid APNs token %q", req.APNSToken)
+	}
+
+	token := icloud.Token{
+		APNSEnvironment: req.APNSEnvironment,
+		APNSToken:       req.APNSToken,
+	}
+	s.tokens[token.APNSToken] = token
+
+	return token, nil
+}
+
+// courierBuffer is the number of notifications a web courier URL holds until
+// they are received.
+const courierBuffer = 64
+
+// Notify sends the notification payload to the web courier URL of the created
+// token with the given APNs token. Payloads are received in the order they are
+// sent. It fails if the token is unknown or too many notifications haven't
+// been received yet.
+func (s *Server) Notify(apnsToken string, payload []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	courier, ok := s.courier(apnsToken)
+	if !ok {
+		return fmt.Errorf("icloudtest: unknown token %q", apnsToken)
+	}
+
+	select {
+	case courier <- payload:
+		return nil
+	default:
+		return fmt.Errorf("icloudtest: too many pending notifications for token %q", apnsToken)
+	}
+}
+
+// courier returns the notifications pending for the web courier URL of the
+// created token with the given APNs token. It must be called with s.mu held.
+func (s *Server) courier(apnsToken string) (chan []byte, bool) {
+	if token, ok := s.tokens[apnsToken]; !ok || token.WebcourierURL == "" {
+		return nil, false
+	}
+
+	courier, ok := s.couriers[apnsToken]
+	if !ok {
+		courier = make(chan []byte, courierBuffer)
+		s.couriers[apnsToken] = courier
+	}
+	return courier, true
+}
+
+// pollCourier handles a poll of a web courier URL. It is held open until a
+// notification is sent to the token, the poll is cancelled or the long poll
+// times out. The latter is answered without content.
+func (s *Server) pollCourier(w http.ResponseWriter, r *http.Request, apnsToken string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, icloud.BadRequest, "method not allowed")
+		return
+	}
+
+	s.mu.Lock()
+	courier, ok := s.courier(apnsToken)
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, icloud.NotFound, "unknown web courier url")
+		return
+	}
+
+	timer := time.NewTimer(s.LongPollTimeout)
+	defer timer.Stop()
+
+	select {
+	case payload := <-courier:
+		w.Header().Set("content-type", "application/json")
+		_, _ = w.Write(payload)
+	case <-timer.C:
+		w.WriteHeader(http.StatusNoContent)
+	case <-r.Context().Done():
+	}
+}
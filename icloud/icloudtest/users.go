@@ -0,0 +1,18 @@
+package icloudtest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// currentUser handles the users/current endpoint. The user record name is
+// derived from the container and the key the request is signed with, so it is
+// stable for a key.
+func (s *Server) currentUser(container, keyID string) icloud.User {
+	h := sha256.Sum256([]byte(container + ":" + keyID))
+	return icloud.User{
+		RecordName: "_" + hex.EncodeToString(h[:16]),
+	}
+}
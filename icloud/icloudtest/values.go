@@ -0,0 +1,216 @@
+package icloudtest
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// storeFields prepares fields received from a client for storage: Uploaded
+// assets are resolved and missing types are inferred from the values.
+func (s *Server) storeFields(fields icloud.Fields) (icloud.Fields, *apiError) {
+	stored := make(icloud.Fields, 0, len(fields))
+	for _, field := range fields {
+		if m, ok := field.Value.(map[string]interface{}); ok {
+			if receipt, ok := m["receipt"].(string); ok {
+				asset, apiErr := s.resolveAsset(receipt)
+				if apiErr != nil {
+					return nil, apiErr
+				}
+				field.Type, field.Value = icloud.TypeAssetID.String(), asset
+			}
+		}
+
+		if field.Type == "" {
+			field.Type = inferType(field.Value).String()
+		}
+
+		stored = append(stored, field)
+	}
+	return stored, nil
+}
+
+// numbersAsStrings represents the numbers of INT64, DOUBLE and TIMESTAMP fields
+// of the records as strings, as requested by the numbersAsStrings flag. The
+// fields of the records must not be shared with stored records.
+func numbersAsStrings(records []icloud.Record) {
+	for _, record := range records {
+		for i, field := range record.Fields {
+			switch field.Type {
+			case icloud.TypeInt64.String(), icloud.TypeDouble.String(), icloud.TypeTimestamp.String():
+				record.Fields[i].Value = numberAsString(field.Value)
+			case icloud.TypeInt64List.String(), icloud.TypeDoubleList.String(), icloud.TypeTimestampList.String():
+				if list, ok := field.Value.([]interface{}); ok {
+					values := make([]interface{}, len(list))
+					for j, v := range list {
+						values[j] = numberAsString(v)
+					}
+					record.Fields[i].Value = values
+				}
+			}
+		}
+	}
+}
+
+// numberAsString returns the string representation of a number or v as is,
+// if it isn't a number.
+func numberAsString(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return v
+}
+
+// mergeFields returns the fields of a with the fields of b added or replaced.
+func mergeFields(a, b icloud.Fields) icloud.Fields {
+	merged := append(make(icloud.Fields, 0, len(a)+len(b)), a...)
+	for _, field := range b {
+		merged.Set(field)
+	}
+	return merged
+}
+
+// project returns a copy of the record only containing the desired fields. If
+// no keys are given, all fields are kept.
+func project(record icloud.Record, desiredKeys []string) icloud.Record {
+	record = copyRecord(record)
+	if len(desiredKeys) == 0 {
+		return record
+	}
+
+	fields := make(icloud.Fields, 0, len(desiredKeys))
+	for _, field := range record.Fields {
+		for _, key := range desiredKeys {
+			if field.Name == key {
+				fields = append(fields, field)
+				break
+			}
+		}
+	}
+	record.Fields = fields
+
+	return record
+}
+
+// copyRecord returns a copy of the record which doesn't share its fields and
+// participants with the original.
+func copyRecord(record icloud.Record) icloud.Record {
+	if record.Fields != nil {
+		record.Fields = append(make(icloud.Fields, 0, len(record.Fields)), record.Fields...)
+	}
+	if record.ZoneID != nil {
+		zoneID := *record.ZoneID
+		record.ZoneID = &zoneID
+	}
+	if record.Participants != nil {
+		record.Participants = append(make([]icloud.Participant, 0, len(record.Participants)), record.Participants...)
+	}
+	return record
+}
+
+// fieldValue returns the value of the field with the given name.
+func fieldValue(fields icloud.Fields, name string) (interface{}, bool) {
+	if field, ok := fields.Get(name); ok {
+		return field.Value, true
+	}
+	return nil, false
+}
+
+// inferType returns the field type of a value as decoded from JSON.
+func inferType(v interface{}) icloud.FieldType {
+	switch v := v.(type) {
+	case string:
+		return icloud.TypeString
+	case float64:
+		if v == math.Trunc(v) {
+			return icloud.TypeInt64
+		}
+		return icloud.TypeDouble
+	case map[string]interface{}:
+		switch {
+		case v["latitude"] != nil:
+			return icloud.TypeLocation
+		case v["recordName"] != nil:
+			return icloud.TypeReference
+		case v["fileChecksum"] != nil:
+			return icloud.TypeAssetID
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			return icloud.TypeUnknownList
+		}
+		return inferType(v[0]).List()
+	}
+	return 0
+}
+
+// compareValues compares two values as decoded from JSON. Only numbers and
+// strings are comparable. Integers are compared exactly.
+func compareValues(a, b interface{}) (int, bool) {
+	if ia, ok := a.(int64); ok {
+		if ib, ok := b.(int64); ok {
+			switch {
+			case ia < ib:
+				return -1, true
+			case ia > ib:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+
+	if fa, ok := toFloat(a); ok {
+		if fb, ok := toFloat(b); ok {
+			switch {
+			case fa < fb:
+				return -1, true
+			case fa > fb:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+
+	if sa, ok := a.(string); ok {
+		if sb, ok := b.(string); ok {
+			return strings.Compare(sa, sb), true
+		}
+	}
+
+	return 0, false
+}
+
+// toFloat returns the number v as float64.
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// equalValues returns true, if both values as decoded from JSON are equal.
+func equalValues(a, b interface{}) bool {
+	if c, ok := compareValues(a, b); ok {
+		return c == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// containsValue returns true, if the list contains the value.
+func containsValue(list []interface{}, v interface{}) bool {
+	for _, elem := range list {
+		if equalValues(elem, v) {
+			return true
+		}
+	}
+	return false
+}
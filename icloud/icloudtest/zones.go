@@ -0,0 +1,92 @@
+package icloudtest
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// listZones handles the zones/list endpoint.
+func (s *Server) listZones(db *database, _ []byte) (interface{}, *apiError) {
+	names := make([]string, 0, len(db.zones))
+	for name := range db.zones {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	res := icloud.ZonesResponse{Zones: make([]icloud.Zone, len(names))}
+	for i, name := range names {
+		res.Zones[i] = db.zones[name].describe()
+	}
+
+	return res, nil
+}
+
+// modifyZones handles the zones/modify endpoint.
+func (s *Server) modifyZones(db *database, body []byte) (interface{}, *apiError) {
+	var req icloud.ZonesRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, newAPIError(icloud.BadRequest, "invalid request body: %s", err)
+	}
+
+	res := icloud.ZonesResponse{Zones: make([]icloud.Zone, len(req.Operations))}
+	for i, op := range req.Operations {
+		zone, apiErr := db.applyZoneOperation(op)
+		if apiErr != nil {
+			zone = icloud.Zone{
+				ZoneID:          op.Zone.ZoneID,
+				Reason:          apiErr.reason,
+				ServerErrorCode: apiErr.code,
+			}
+		}
+		res.Zones[i] = zone
+	}
+
+	return res, nil
+}
+
+// applyZoneOperation applies a single zone operation to the database.
+func (db *database) applyZoneOperation(op icloud.ZoneOperation) (icloud.Zone, *apiError) {
+	name := op.Zone.ZoneID.Name
+	switch {
+	case name == "":
+		return icloud.Zone{}, newAPIError(icloud.BadRequest, "missing zone name")
+	case name == icloud.DefaultZoneName:
+		return icloud.Zone{}, newAPIError(icloud.BadRequest, "the default zone can't be modified")
+	case db.public:
+		return icloud.Zone{}, newAPIError(icloud.BadRequest, "the public database doesn't support custom zones")
+	}
+
+	_, exists := db.zones[name]
+
+	switch op.Type {
+	case icloud.Create:
+		if exists {
+			return icloud.Zone{}, newAPIError(icloud.Exists, "zone %q already exists", name)
+		}
+		db.zones[name] = newZone(name)
+		return db.zones[name].describe(), nil
+	case icloud.Delete:
+		if !exists {
+			return icloud.Zone{}, newAPIError(icloud.ZoneNotFound, "zone %q does not exist", name)
+		}
+		delete(db.zones, name)
+		return icloud.Zone{
+			ZoneID:  icloud.ZoneID{Name: name},
+			Deleted: true,
+		}, nil
+	case icloud.OperationUnknown, icloud.Update, icloud.ForceUpdate, icloud.Replace, icloud.ForceReplace, icloud.ForceDelete:
+	}
+
+	return icloud.Zone{}, newAPIError(icloud.BadRequest, "unsupported operation type %q", op.RawType())
+}
+
+// describe returns the public representation of the zone.
+func (z *zone) describe() icloud.Zone {
+	return icloud.Zone{
+		ZoneID:    *z.id(),
+		SyncToken: encodeToken(z.seq),
+		Atomic:    !z.isDefault(),
+	}
+}
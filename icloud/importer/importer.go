@@ -0,0 +1,540 @@
+// Package importer imports CSV files into CloudKit.
+//
+// Usage:
+//
+//	mapping := importer.Mapping{
+//		RecordType: "Post",
+//		KeyColumns: []string{"id"},
+//		Columns: []importer.Column{
+//			{Name: "title", Type: icloud.TypeString},
+//			{Name: "published", Type: icloud.TypeTimestamp, Layout: "2006-01-02"},
+//			{Name: "tags", Type: icloud.TypeStringList, Separator: ";"},
+//			{Field: "location", Type: icloud.TypeLocation, Latitude: "lat", Longitude: "lon"},
+//		},
+//	}
+//
+//	imp, err := importer.New(client, mapping, importer.Options{})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	res, err := imp.Import(ctx, f)
+//
+// The first line of the CSV file must be a header naming the columns. Every
+// following row is mapped to a record and written in batches. Rows which can't
+// be mapped or written are rejected and reported in the Result, without
+// failing the import.
+package importer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// DefaultSeparator separates the values of list fields, if not configured
+// otherwise.
+const DefaultSeparator = ","
+
+// Column maps a CSV column to a record field.
+type Column struct {
+	// Name of the CSV column. Not used for fields of type TypeLocation.
+	Name string `json:"name,omitempty"`
+	// Field is the name of the record field. Defaults to the name of the
+	// column.
+	Field string `json:"field,omitempty"`
+	// Type of the record field. Supported types are TypeString, TypeInt64,
+	// TypeDouble, TypeTimestamp, TypeBytes (base64 encoded), TypeLocation and
+	// the list variants of all of them but TypeLocation.
+	Type icloud.FieldType `json:"type"`
+	// Layout of timestamps, as understood by time.Parse. Defaults to
+	// time.RFC3339.
+	Layout string `json:"layout,omitempty"`
+	// Separator of the values of list fields. Defaults to DefaultSeparator.
+	Separator string `json:"separator,omitempty"`
+	// Latitude is the name of the column holding the latitude of fields of
+	// type TypeLocation.
+	Latitude string `json:"latitude,omitempty"`
+	// Longitude is the name of the column holding the longitude of fields of
+	// type TypeLocation.
+	Longitude string `json:"longitude,omitempty"`
+	// Required rejects rows with an empty value. Otherwise, the field is left
+	// out of the record.
+	Required bool `json:"required,omitempty"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It is in place to reject field
+// types unknown to the package, which are unmarshalled as icloud.TypeUnknown
+// otherwise.
+func (col *Column) UnmarshalJSON(b []byte) error {
+	type LocalColumn Column
+	localColumn := struct {
+		*LocalColumn
+
+		Type string `json:"type"`
+	}{
+		LocalColumn: (*LocalColumn)(col),
+	}
+
+	if err := json.Unmarshal(b, &localColumn); err != nil {
+		return err
+	}
+
+	var err error
+	col.Type, err = icloud.ParseFieldType(localColumn.Type)
+
+	return err
+}
+
+// Mapping describes how the rows of a CSV file are mapped to records.
+type Mapping struct {
+	// RecordType of the records to create.
+	RecordType string `json:"recordType"`
+	// Columns to map to record fields. Columns of the CSV file not listed are
+	// ignored.
+	Columns []Column `json:"columns"`
+	// KeyColumns are the names of the columns the record name is derived from,
+	// see RecordName. If not set, the record names are generated by the
+	// server and importing the same file twice creates duplicate records.
+	KeyColumns []string `json:"keyColumns,omitempty"`
+}
+
+// Options configure an import.
+type Options struct {
+	// Database to import into. Defaults to the public database.
+	Database icloud.Database
+	// ZoneID of the zone to import into. If not set, the default zone is used.
+	ZoneID *icloud.ZoneID
+	// OperationType used to write the records. Defaults to icloud.ForceUpdate
+	// if key columns are set, so repeated imports update existing records,
+	// and to icloud.Create otherwise.
+	OperationType icloud.OperationType
+	// BatchSize is the number of records written per request. Defaults to and
+	// is limited by icloud.MaxOperationPerRequest.
+	BatchSize int
+	// DryRun maps the rows to records without writing them.
+	DryRun bool
+}
+
+// Result is the outcome of an import.
+type Result struct {
+	// Rows is the number of rows read, excluding the header.
+	Rows int
+	// Imported is the number of records written or, in dry-run mode, the
+	// number of records that would have been written.
+	Imported int
+	// Rejected are the rows which were not imported.
+	Rejected []Rejection
+}
+
+// Rejection is a row which was not imported.
+type Rejection struct {
+	// Line of the row in the CSV file, starting at 1 for the header.
+	Line int
+	// Row is the raw row.
+	Row []string
+	// RecordName of the record the row maps to, if known.
+	RecordName string
+	// Reason the row was rejected.
+	Reason string
+	// Code is the server error code, if the record was rejected by the server.
+	Code icloud.ErrorCode
+}
+
+// Importer imports CSV files according to a Mapping.
+type Importer struct {
+	client  *icloud.Client
+	mapping Mapping
+	options Options
+}
+
+// New returns a new Importer which writes the records using the given client.
+// It returns an error if the mapping or the options are invalid. Unset options
+// take the defaults documented on Options.
+func New(client *icloud.Client, mapping Mapping, opts Options) (*Importer, error) {
+	if mapping.RecordType == "" {
+		return nil, errors.New("missing record type")
+	} else if len(mapping.Columns) == 0 {
+		return nil, errors.New("missing columns")
+	}
+
+	for i, col := range mapping.Columns {
+		if err := col.validate(); err != nil {
+			return nil, fmt.Errorf("column %d: %w", i+1, err)
+		}
+	}
+
+	if opts.Database == 0 {
+		opts.Database = icloud.Public
+	}
+	if opts.OperationType == 0 {
+		opts.OperationType = icloud.Create
+		if len(mapping.KeyColumns) > 0 {
+			opts.OperationType = icloud.ForceUpdate
+		}
+	}
+	switch opts.OperationType {
+	case icloud.Create, icloud.ForceUpdate, icloud.ForceReplace:
+	default:
+		return nil, fmt.Errorf("unsupported operation type %q", opts.OperationType)
+	}
+	if opts.BatchSize <= 0 || opts.BatchSize > icloud.MaxOperationPerRequest {
+		opts.BatchSize = icloud.MaxOperationPerRequest
+	}
+
+	return &Importer{
+		client:  client,
+		mapping: mapping,
+		options: opts,
+	}, nil
+}
+
+// validate returns an error if the column is not valid.
+func (col Column) validate() error {
+	if col.Type == icloud.TypeLocation {
+		if col.Field == "" {
+			return errors.New("missing field name of location")
+		} else if col.Latitude == "" || col.Longitude == "" {
+			return errors.New("missing latitude or longitude column of location")
+		}
+		return nil
+	} else if col.Name == "" {
+		return errors.New("missing column name")
+	}
+
+	typ := col.Type
+	if typ.IsList() {
+		typ = typ.Elem()
+	}
+
+	switch typ {
+	case icloud.TypeString, icloud.TypeInt64, icloud.TypeDouble, icloud.TypeTimestamp, icloud.TypeBytes:
+		return nil
+	}
+
+	return fmt.Errorf("unsupported type %q", col.Type)
+}
+
+// fieldName returns the name of the record field the column maps to.
+func (col Column) fieldName() string {
+	if col.Field != "" {
+		return col.Field
+	}
+	return col.Name
+}
+
+// Import reads the CSV data from r and imports its rows. Rows which are
+// rejected are reported in the result. An error is returned if the data can't
+// be read or a request fails as a whole, along with the result up to that
+// point.
+func (imp *Importer) Import(ctx context.Context, r io.Reader) (*Result, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("missing header")
+	} else if err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[strings.TrimSpace(name)] = i
+	}
+	for _, name := range imp.columnNames() {
+		if _, ok := index[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+
+	var (
+		res   = new(Result)
+		batch = make([]row, 0, imp.options.BatchSize)
+		seen  = make(map[string]int)
+	)
+	for line := 2; ; line++ {
+		values, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return res, fmt.Errorf("read line %d: %w", line, err)
+		}
+		res.Rows++
+
+		record, err := imp.mapRow(values, index)
+		if err != nil {
+			res.reject(line, values, record.Name, err.Error(), 0)
+			continue
+		}
+
+		if record.Name != "" {
+			if prev, ok := seen[record.Name]; ok {
+				res.reject(line, values, record.Name, fmt.Sprintf("duplicate key of line %d", prev), 0)
+				continue
+			}
+			seen[record.Name] = line
+		}
+
+		if batch = append(batch, row{line, values, record}); len(batch) == cap(batch) {
+			if err = imp.write(ctx, batch, res); err != nil {
+				return res, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if err = imp.write(ctx, batch, res); err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
+
+// columnNames returns the names of all CSV columns used by the mapping.
+func (imp *Importer) columnNames() []string {
+	names := append([]string(nil), imp.mapping.KeyColumns...)
+	for _, col := range imp.mapping.Columns {
+		if col.Type == icloud.TypeLocation {
+			names = append(names, col.Latitude, col.Longitude)
+		} else {
+			names = append(names, col.Name)
+		}
+	}
+	return names
+}
+
+// row is a row of the CSV file mapped to a record.
+type row struct {
+	line   int
+	values []string
+	record icloud.Record
+}
+
+// mapRow maps the values of a row to a record. If the record name can be
+// derived, it is set on the returned record even if mapping the fields fails.
+func (imp *Importer) mapRow(values []string, index map[string]int) (icloud.Record, error) {
+	value := func(name string) string {
+		if i := index[name]; i < len(values) {
+			return strings.TrimSpace(values[i])
+		}
+		return ""
+	}
+
+	record := icloud.Record{Type: imp.mapping.RecordType}
+
+	if len(imp.mapping.KeyColumns) > 0 {
+		keys := make([]string, len(imp.mapping.KeyColumns))
+		for i, name := range imp.mapping.KeyColumns {
+			if keys[i] = value(name); keys[i] == "" {
+				return record, fmt.Errorf("empty key column %q", name)
+			}
+		}
+		record.Name = RecordName(imp.mapping.RecordType, keys...)
+	}
+
+	for _, col := range imp.mapping.Columns {
+		var (
+			v   interface{}
+			err error
+		)
+		if col.Type == icloud.TypeLocation {
+			if lat, lon := value(col.Latitude), value(col.Longitude); lat != "" || lon != "" {
+				v, err = parseLocation(lat, lon)
+			}
+		} else if s := value(col.Name); s != "" {
+			v, err = col.parse(s)
+		}
+
+		if err != nil {
+			return record, fmt.Errorf("field %q: %w", col.fieldName(), err)
+		} else if v == nil && col.Required {
+			return record, fmt.Errorf("field %q: missing value", col.fieldName())
+		} else if v == nil {
+			continue
+		}
+
+		record.Fields = append(record.Fields, icloud.Field{
+			Name:  col.fieldName(),
+			Type:  col.Type.String(),
+			Value: v,
+		})
+	}
+
+	return record, nil
+}
+
+// parse parses a non-empty value of the column.
+func (col Column) parse(s string) (interface{}, error) {
+	if !col.Type.IsList() {
+		return col.parseValue(col.Type, s)
+	}
+
+	sep := col.Separator
+	if sep == "" {
+		sep = DefaultSeparator
+	}
+
+	parts := strings.Split(s, sep)
+	list := make([]interface{}, len(parts))
+	for i, part := range parts {
+		v, err := col.parseValue(col.Type.Elem(), strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		list[i] = v
+	}
+
+	return list, nil
+}
+
+// parseValue parses a single value of the given type.
+func (col Column) parseValue(typ icloud.FieldType, s string) (interface{}, error) {
+	switch typ {
+	case icloud.TypeInt64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return i, nil
+	case icloud.TypeDouble:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	case icloud.TypeTimestamp:
+		layout := col.Layout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return nil, err
+		}
+		return t.UnixNano() / int64(time.Millisecond), nil
+	case icloud.TypeBytes:
+		if _, err := base64.StdEncoding.DecodeString(s); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	return s, nil
+}
+
+// parseLocation parses a location from its latitude and longitude.
+func parseLocation(lat, lon string) (icloud.Location, error) {
+	latitude, err := strconv.ParseFloat(lat, 64)
+	if err != nil {
+		return icloud.Location{}, fmt.Errorf("invalid latitude: %w", err)
+	} else if latitude < -90 || latitude > 90 {
+		return icloud.Location{}, fmt.Errorf("latitude %v out of range", latitude)
+	}
+
+	longitude, err := strconv.ParseFloat(lon, 64)
+	if err != nil {
+		return icloud.Location{}, fmt.Errorf("invalid longitude: %w", err)
+	} else if longitude < -180 || longitude > 180 {
+		return icloud.Location{}, fmt.Errorf("longitude %v out of range", longitude)
+	}
+
+	return icloud.Location{Latitude: latitude, Longitude: longitude}, nil
+}
+
+// write writes the records of a batch of rows and records the outcome.
+func (imp *Importer) write(ctx context.Context, batch []row, res *Result) error {
+	if len(batch) == 0 {
+		return nil
+	} else if imp.options.DryRun {
+		res.Imported += len(batch)
+		return nil
+	}
+
+	req := icloud.RecordsRequest{
+		ZoneID:     imp.options.ZoneID,
+		Operations: make([]icloud.RecordOperation, len(batch)),
+	}
+	for i, row := range batch {
+		req.Operations[i] = icloud.RecordOperation{
+			Type:   imp.options.OperationType,
+			Record: row.record,
+		}
+	}
+
+	modRes, err := imp.client.Records.Modify(ctx, imp.options.Database, req)
+	if err != nil {
+		return fmt.Errorf("write lines %d to %d: %w", batch[0].line, batch[len(batch)-1].line, err)
+	}
+
+	// The records of the response are in the order of the operations.
+	for i, row := range batch {
+		if i >= len(modRes.Records) {
+			res.reject(row.line, row.values, row.record.Name, "missing in response", 0)
+		} else if record := modRes.Records[i]; record.Err() != nil {
+			res.reject(row.line, row.values, row.record.Name, record.Reason, record.ServerErrorCode)
+		} else {
+			res.Imported++
+		}
+	}
+
+	return nil
+}
+
+// reject records a rejected row.
+func (res *Result) reject(line int, values []string, recordName, reason string, code icloud.ErrorCode) {
+	res.Rejected = append(res.Rejected, Rejection{
+		Line:       line,
+		Row:        values,
+		RecordName: recordName,
+		Reason:     reason,
+		Code:       code,
+	})
+}
+
+// WriteReport writes the rejected rows as CSV to w. Every line holds the line
+// number, the record name, the error code and the reason, followed by the
+// values of the rejected row.
+func (res *Result) WriteReport(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"line", "record_name", "error_code", "reason"}); err != nil {
+		return err
+	}
+
+	for _, rej := range res.Rejected {
+		var code string
+		if rej.Code != icloud.Unknown {
+			code = rej.Code.String()
+		}
+		row := append([]string{strconv.Itoa(rej.Line), rej.RecordName, code, rej.Reason}, rej.Row...)
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// RecordName derives a deterministic record name from the record type and the
+// values of the key columns of a row. The same keys always yield the same
+// name, so repeated imports address the same records.
+func RecordName(recordType string, keys ...string) string {
+	h := sha256.New()
+	_, _ = io.WriteString(h, recordType)
+	for _, key := range keys {
+		_, _ = h.Write([]byte{0})
+		_, _ = io.WriteString(h, key)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
@@ -0,0 +1,220 @@
+package importer_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+	"github.com/lukasmalkmus/icloud-go/icloud/icloudtest"
+	"github.com/lukasmalkmus/icloud-go/icloud/importer"
+)
+
+const container = "iCloud.com.lukasmalkmus.Example-App"
+
+const data = `id,title,published,tags,lat,lon,likes
+1,Hello,2021-03-01,go;cloudkit,52.52,13.405,10
+2,World,2021-03-02,,,,
+3,Broken,yesterday,,,,
+4,Far away,2021-03-04,,91,0,
+1,Again,2021-03-05,,,,
+,No key,2021-03-06,,,,
+5,Many likes,2021-03-07,,,,a lot
+`
+
+var mapping = importer.Mapping{
+	RecordType: "Post",
+	KeyColumns: []string{"id"},
+	Columns: []importer.Column{
+		{Name: "title", Type: icloud.TypeString, Required: true},
+		{Name: "published", Type: icloud.TypeTimestamp, Layout: "2006-01-02"},
+		{Name: "tags", Type: icloud.TypeStringList, Separator: ";"},
+		{Field: "location", Type: icloud.TypeLocation, Latitude: "lat", Longitude: "lon"},
+		{Name: "likes", Field: "likeCount", Type: icloud.TypeInt64},
+	},
+}
+
+func setup(t *testing.T) (*icloud.Client, func()) {
+	srv := icloudtest.NewServer()
+
+	client, err := srv.NewClient(container, icloud.Development)
+	require.NoError(t, err)
+
+	return client, srv.Close
+}
+
+func TestImporter(t *testing.T) {
+	client, teardown := setup(t)
+	defer teardown()
+
+	ctx := context.Background()
+
+	imp, err := importer.New(client, mapping, importer.Options{BatchSize: 1})
+	require.NoError(t, err)
+
+	res, err := imp.Import(ctx, strings.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 7, res.Rows)
+	assert.Equal(t, 2, res.Imported)
+	require.Len(t, res.Rejected, 5)
+
+	lines := make([]int, len(res.Rejected))
+	for i, rej := range res.Rejected {
+		lines[i] = rej.Line
+	}
+	assert.Equal(t, []int{4, 5, 6, 7, 8}, lines)
+	assert.Contains(t, res.Rejected[0].Reason, `field "published"`)
+	assert.Contains(t, res.Rejected[1].Reason, "latitude 91 out of range")
+	assert.Equal(t, "duplicate key of line 2", res.Rejected[2].Reason)
+	assert.Equal(t, `empty key column "id"`, res.Rejected[3].Reason)
+	assert.Contains(t, res.Rejected[4].Reason, `field "likeCount"`)
+
+	lookup, err := client.Records.Lookup(ctx, icloud.Public, icloud.LookupRequest{
+		Records: []icloud.Record{
+			{Name: importer.RecordName("Post", "1")},
+			{Name: importer.RecordName("Post", "2")},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, lookup.Records, 2)
+
+	hello := lookup.Records[0]
+	require.NoError(t, hello.Err())
+	assert.Equal(t, "Post", hello.Type)
+
+	fields := make(map[string]icloud.Field)
+	for _, field := range hello.Fields {
+		fields[field.Name] = field
+	}
+	assert.Equal(t, "Hello", fields["title"].Value)
+	assert.EqualValues(t, time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC).UnixNano()/int64(time.Millisecond), fields["published"].Value)
+	assert.Equal(t, "TIMESTAMP", fields["published"].Type)
+	assert.Equal(t, []interface{}{"go", "cloudkit"}, fields["tags"].Value)
+	assert.Equal(t, map[string]interface{}{"latitude": 52.52, "longitude": 13.405}, fields["location"].Value)
+	assert.Equal(t, "LOCATION", fields["location"].Type)
+	assert.EqualValues(t, 10, fields["likeCount"].Value)
+
+	world := lookup.Records[1]
+	require.NoError(t, world.Err())
+	assert.Len(t, world.Fields, 2)
+
+	// Importing again updates the existing records.
+	res, err = imp.Import(ctx, strings.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Imported)
+
+	var report bytes.Buffer
+	require.NoError(t, res.WriteReport(&report))
+	reportLines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	require.Len(t, reportLines, 6)
+	assert.Equal(t, "line,record_name,error_code,reason", reportLines[0])
+	assert.True(t, strings.HasPrefix(reportLines[3], "6,"+importer.RecordName("Post", "1")+",,duplicate key of line 2,1,Again,"), reportLines[3])
+}
+
+func TestImporter_ServerRejection(t *testing.T) {
+	client, teardown := setup(t)
+	defer teardown()
+
+	imp, err := importer.New(client, mapping, importer.Options{OperationType: icloud.Create})
+	require.NoError(t, err)
+
+	res, err := imp.Import(context.Background(), strings.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Imported)
+
+	res, err = imp.Import(context.Background(), strings.NewReader(data))
+	require.NoError(t, err)
+	assert.Zero(t, res.Imported)
+	require.Len(t, res.Rejected, 7)
+	assert.Equal(t, icloud.Exists, res.Rejected[5].Code)
+	assert.Equal(t, 2, res.Rejected[5].Line)
+}
+
+func TestImporter_DryRun(t *testing.T) {
+	client, teardown := setup(t)
+	defer teardown()
+
+	ctx := context.Background()
+
+	imp, err := importer.New(client, mapping, importer.Options{DryRun: true})
+	require.NoError(t, err)
+
+	res, err := imp.Import(ctx, strings.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Imported)
+	assert.Len(t, res.Rejected, 5)
+
+	query, err := client.Records.Query(ctx, icloud.Public, icloud.QueryRequest{
+		Query: icloud.Query{RecordType: "Post"},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, query.Records)
+}
+
+func TestImporter_MissingColumn(t *testing.T) {
+	client, teardown := setup(t)
+	defer teardown()
+
+	imp, err := importer.New(client, mapping, importer.Options{})
+	require.NoError(t, err)
+
+	_, err = imp.Import(context.Background(), strings.NewReader("id,title\n1,Hello\n"))
+	assert.EqualError(t, err, `missing column "published"`)
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping importer.Mapping
+		options importer.Options
+		err     string
+	}{
+		{
+			name: "missing record type",
+			err:  "missing record type",
+		},
+		{
+			name:    "unsupported type",
+			mapping: importer.Mapping{RecordType: "Post", Columns: []importer.Column{{Name: "a", Type: icloud.TypeReference}}},
+			err:     `column 1: unsupported type "REFERENCE"`,
+		},
+		{
+			name:    "location without columns",
+			mapping: importer.Mapping{RecordType: "Post", Columns: []importer.Column{{Field: "a", Type: icloud.TypeLocation}}},
+			err:     "column 1: missing latitude or longitude column of location",
+		},
+		{
+			name:    "unsupported operation type",
+			mapping: importer.Mapping{RecordType: "Post", Columns: []importer.Column{{Name: "a", Type: icloud.TypeInt64List}}},
+			options: importer.Options{OperationType: icloud.Delete},
+			err:     `unsupported operation type "delete"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := importer.New(nil, tt.mapping, tt.options)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestColumn_UnmarshalJSON(t *testing.T) {
+	var col importer.Column
+	require.NoError(t, json.Unmarshal([]byte(`{"name":"a","type":"int64_list"}`), &col))
+	assert.Equal(t, importer.Column{Name: "a", Type: icloud.TypeInt64List}, col)
+
+	assert.EqualError(t, json.Unmarshal([]byte(`{"name":"a","type":"INTEGER"}`), &col), `unknown field type "INTEGER"`)
+}
+
+func TestRecordName(t *testing.T) {
+	assert.Equal(t, importer.RecordName("Post", "1"), importer.RecordName("Post", "1"))
+	assert.NotEqual(t, importer.RecordName("Post", "1"), importer.RecordName("Comment", "1"))
+	assert.NotEqual(t, importer.RecordName("Post", "1", "2"), importer.RecordName("Post", "12"))
+	assert.Len(t, importer.RecordName("Post", "1"), 32)
+}
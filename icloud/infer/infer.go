@@ -0,0 +1,415 @@
+// Package infer infers a draft schema from the records of a container, e.g.
+// for legacy containers whose schema was never exported:
+//
+//	res, err := infer.Sample(ctx, client, infer.Options{
+//		Database:    icloud.Public,
+//		RecordTypes: []string{"Author", "Post"},
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	fmt.Print(res.Schema)
+//	res.WriteReport(os.Stderr)
+//
+// The type of a field is inferred from the types the server reports for its
+// values. Fields whose values are of different types across the sampled
+// records are inferred to have the type seen most often and are reported as
+// inconsistent. The draft schema should be reviewed before it is imported:
+// Indexes and grants can't be inferred from records.
+package infer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+	"github.com/lukasmalkmus/icloud-go/icloud/schema"
+)
+
+// DefaultLimit is the default maximum number of records sampled per record
+// type and zone.
+const DefaultLimit = 1000
+
+// Options configure the sampling of records.
+type Options struct {
+	// Database to sample. Defaults to the public database.
+	Database icloud.Database
+	// RecordTypes to sample. Records of these types are queried in every zone.
+	// Queries require the record types to be known upfront. If not set, the
+	// record types are discovered by fetching the changes of the zones, which
+	// is only supported by custom zones.
+	RecordTypes []string
+	// Zones to sample. If not set, the default zone is sampled.
+	Zones []icloud.ZoneID
+	// Limit is the maximum number of records sampled per record type and zone
+	// when querying and per zone when fetching changes. Defaults to
+	// DefaultLimit.
+	Limit int
+}
+
+// Result is the outcome of a schema inference.
+type Result struct {
+	// Schema is the inferred draft schema. Every record type has the system
+	// fields CloudKit adds to all record types.
+	Schema *schema.Schema
+	// Records is the number of records sampled.
+	Records int
+	// Fields holds the statistics of all sampled fields, sorted by record type
+	// and field name.
+	Fields []FieldStats
+}
+
+// Inconsistencies returns the statistics of the fields whose values are of
+// different types.
+func (r *Result) Inconsistencies() []FieldStats {
+	var res []FieldStats
+	for _, field := range r.Fields {
+		if field.Inconsistent() {
+			res = append(res, field)
+		}
+	}
+	return res
+}
+
+// WriteReport writes a human readable report of the fields whose types are
+// inconsistent or couldn't be inferred to w.
+func (r *Result) WriteReport(w io.Writer) error {
+	var sb strings.Builder
+
+	fmt.Fprintf(&sb, "sampled %d records of %d record types\n", r.Records, len(r.Schema.RecordTypes))
+	for _, field := range r.Fields {
+		switch {
+		case field.Type == 0:
+			fmt.Fprintf(&sb, "%s.%s: unknown type, only empty lists seen (%d)\n",
+				field.RecordType, field.Name, field.Count)
+		case field.Inconsistent():
+			fmt.Fprintf(&sb, "%s.%s: inconsistent types %s, inferred %s\n",
+				field.RecordType, field.Name, field.typeCounts(), field.Type)
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// FieldStats are the statistics of a field of a record type.
+type FieldStats struct {
+	// RecordType the field belongs to.
+	RecordType string
+	// Name of the field.
+	Name string
+	// Type is the inferred type of the field. It is zero if only empty lists
+	// were seen, which don't carry the type of their elements.
+	Type icloud.FieldType
+	// Types maps the types seen to the number of records with a value of that
+	// type. Empty lists are counted as icloud.TypeUnknownList.
+	Types map[icloud.FieldType]int
+	// Count is the number of records the field is set on.
+	Count int
+	// Optional is true, if the field isn't set on every sampled record of the
+	// record type.
+	Optional bool
+}
+
+// Inconsistent returns true, if values of different types were seen for the
+// field. Empty lists are consistent with every list type.
+func (fs FieldStats) Inconsistent() bool {
+	var n int
+	for typ := range fs.Types {
+		if typ != icloud.TypeUnknownList {
+			n++
+		}
+	}
+	return n > 1
+}
+
+// typeCounts returns the types seen, ordered by their number of occurrences.
+func (fs FieldStats) typeCounts() string {
+	types := sortedTypes(fs.Types)
+	s := make([]string, len(types))
+	for i, typ := range types {
+		s[i] = fmt.Sprintf("%s (%d)", typ, fs.Types[typ])
+	}
+	return strings.Join(s, ", ")
+}
+
+// Inferrer infers a schema from records. Sample uses it to infer a schema from
+// the records of a container but records obtained otherwise, e.g. from an
+// NDJSON export, can be added directly. The zero value is ready to use.
+type Inferrer struct {
+	records int
+	types   map[string]*recordTypeStats
+}
+
+// recordTypeStats are the statistics of a record type.
+type recordTypeStats struct {
+	records int
+	fields  map[string]map[icloud.FieldType]int
+	counts  map[string]int
+}
+
+// New returns a new Inferrer.
+func New() *Inferrer {
+	return new(Inferrer)
+}
+
+// Add adds the records to the sample. Deleted records are ignored.
+func (inf *Inferrer) Add(records ...icloud.Record) {
+	if inf.types == nil {
+		inf.types = make(map[string]*recordTypeStats)
+	}
+
+	for _, record := range records {
+		if record.Deleted || record.Type == "" {
+			continue
+		}
+
+		rt, ok := inf.types[record.Type]
+		if !ok {
+			rt = &recordTypeStats{
+				fields: make(map[string]map[icloud.FieldType]int),
+				counts: make(map[string]int),
+			}
+			inf.types[record.Type] = rt
+		}
+
+		inf.records++
+		rt.records++
+
+		for _, field := range record.Fields {
+			if field.Value == nil {
+				continue
+			}
+
+			if rt.fields[field.Name] == nil {
+				rt.fields[field.Name] = make(map[icloud.FieldType]int)
+			}
+			rt.counts[field.Name]++
+			if typ := schemaType(field.Type); typ != 0 {
+				rt.fields[field.Name][typ]++
+			}
+		}
+	}
+}
+
+// Result returns the schema inferred from the records added so far.
+func (inf *Inferrer) Result() *Result {
+	res := &Result{
+		Schema:  new(schema.Schema),
+		Records: inf.records,
+	}
+
+	names := make([]string, 0, len(inf.types))
+	for name := range inf.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		rt := inf.types[name]
+
+		recordType := schema.RecordType{
+			Name:   name,
+			Fields: systemFields(),
+		}
+
+		fieldNames := make([]string, 0, len(rt.fields))
+		for fieldName := range rt.fields {
+			fieldNames = append(fieldNames, fieldName)
+		}
+		sort.Strings(fieldNames)
+
+		for _, fieldName := range fieldNames {
+			types := make(map[icloud.FieldType]int, len(rt.fields[fieldName]))
+			for typ, n := range rt.fields[fieldName] {
+				types[typ] = n
+			}
+
+			stats := FieldStats{
+				RecordType: name,
+				Name:       fieldName,
+				Type:       inferType(types),
+				Types:      types,
+				Count:      rt.counts[fieldName],
+				Optional:   rt.counts[fieldName] < rt.records,
+			}
+			res.Fields = append(res.Fields, stats)
+
+			if stats.Type != 0 {
+				recordType.Fields = append(recordType.Fields, schema.Field{
+					Name: fieldName,
+					Type: stats.Type,
+				})
+			}
+		}
+
+		res.Schema.RecordTypes = append(res.Schema.RecordTypes, recordType)
+	}
+
+	return res
+}
+
+// Sample samples the records selected by the options and infers a schema
+// from them.
+func Sample(ctx context.Context, client *icloud.Client, opts Options) (*Result, error) {
+	if opts.Database == 0 {
+		opts.Database = icloud.Public
+	}
+	if len(opts.Zones) == 0 {
+		opts.Zones = []icloud.ZoneID{{Name: icloud.DefaultZoneName}}
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultLimit
+	}
+
+	inf := New()
+	for i := range opts.Zones {
+		zoneID := opts.Zones[i]
+
+		if len(opts.RecordTypes) == 0 {
+			if zoneID.Name == icloud.DefaultZoneName {
+				return nil, fmt.Errorf("the record types of zone %q can't be discovered, specify the record types to sample", zoneID.Name)
+			}
+			if err := sampleChanges(ctx, client, inf, opts, zoneID); err != nil {
+				return nil, fmt.Errorf("sample records of zone %q: %w", zoneID.Name, err)
+			}
+			continue
+		}
+
+		for _, recordType := range opts.RecordTypes {
+			if err := sampleQuery(ctx, client, inf, opts, zoneID, recordType); err != nil {
+				return nil, fmt.Errorf("sample %q records of zone %q: %w", recordType, zoneID.Name, err)
+			}
+		}
+	}
+
+	return inf.Result(), nil
+}
+
+// sampleQuery adds up to opts.Limit records of the record type in the zone to
+// the inferrer.
+func sampleQuery(ctx context.Context, client *icloud.Client, inf *Inferrer, opts Options, zoneID icloud.ZoneID, recordType string) error {
+	req := icloud.QueryRequest{
+		ZoneID: &zoneID,
+		Query:  icloud.Query{RecordType: recordType},
+	}
+
+	for remaining := opts.Limit; remaining > 0; {
+		req.ResultsLimit = pageSize(remaining)
+
+		page, err := client.Records.Query(ctx, opts.Database, req)
+		if err != nil {
+			return err
+		}
+
+		records := page.Records
+		if len(records) > remaining {
+			records = records[:remaining]
+		}
+		inf.Add(records...)
+		remaining -= len(records)
+
+		if page.ContinuationMarker == "" {
+			return nil
+		}
+		req.ContinuationMarker = page.ContinuationMarker
+	}
+
+	return nil
+}
+
+// sampleChanges adds up to opts.Limit records of the zone to the inferrer.
+func sampleChanges(ctx context.Context, client *icloud.Client, inf *Inferrer, opts Options, zoneID icloud.ZoneID) error {
+	req := icloud.ChangesRequest{ZoneID: zoneID}
+
+	for remaining := opts.Limit; remaining > 0; {
+		req.ResultsLimit = pageSize(remaining)
+
+		page, err := client.Records.Changes(ctx, opts.Database, req)
+		if err != nil {
+			return err
+		}
+
+		records := page.Records
+		if len(records) > remaining {
+			records = records[:remaining]
+		}
+		inf.Add(records...)
+		remaining -= len(records)
+
+		if !page.MoreComing {
+			return nil
+		}
+		req.SyncToken = page.SyncToken
+	}
+
+	return nil
+}
+
+// pageSize returns the number of records to request when the given number of
+// records remains to be sampled.
+func pageSize(remaining int) int {
+	if remaining > icloud.MaxOperationPerRequest {
+		return icloud.MaxOperationPerRequest
+	}
+	return remaining
+}
+
+// schemaType returns the schema type of a field of the given type returned
+// by the server. The server returns asset fields as ASSETID. Zero is returned
+// for unknown types.
+func schemaType(s string) icloud.FieldType {
+	typ, _ := icloud.ParseFieldType(s)
+
+	switch typ {
+	case icloud.TypeAssetID:
+		return icloud.TypeAsset
+	case icloud.TypeAssetIDList:
+		return icloud.TypeAssetList
+	default:
+		return typ
+	}
+}
+
+// inferType returns the type seen most often, ignoring empty lists. It
+// returns zero if no other type was seen.
+func inferType(types map[icloud.FieldType]int) icloud.FieldType {
+	for _, typ := range sortedTypes(types) {
+		if typ != icloud.TypeUnknownList {
+			return typ
+		}
+	}
+	return 0
+}
+
+// sortedTypes returns the types ordered by their number of occurrences, most
+// frequent first. Ties are broken by the order of the types.
+func sortedTypes(types map[icloud.FieldType]int) []icloud.FieldType {
+	res := make([]icloud.FieldType, 0, len(types))
+	for typ := range types {
+		res = append(res, typ)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if types[res[i]] != types[res[j]] {
+			return types[res[i]] > types[res[j]]
+		}
+		return res[i] < res[j]
+	})
+	return res
+}
+
+// systemFields returns the system fields CloudKit adds to every record type.
+func systemFields() []schema.Field {
+	return []schema.Field{
+		{Name: schema.SystemFieldPrefix + "createTime", Type: icloud.TypeTimestamp},
+		{Name: schema.SystemFieldPrefix + "createdBy", Type: icloud.TypeReference},
+		{Name: schema.SystemFieldPrefix + "etag", Type: icloud.TypeString},
+		{Name: schema.SystemFieldPrefix + "modTime", Type: icloud.TypeTimestamp},
+		{Name: schema.SystemFieldPrefix + "modifiedBy", Type: icloud.TypeReference},
+		{Name: schema.SystemFieldPrefix + "recordID", Type: icloud.TypeReference, Queryable: true},
+	}
+}
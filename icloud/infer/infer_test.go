@@ -0,0 +1,187 @@
+package infer_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+	"github.com/lukasmalkmus/icloud-go/icloud/icloudtest"
+	"github.com/lukasmalkmus/icloud-go/icloud/infer"
+)
+
+var records = []icloud.Record{
+	{Name: "alice", Type: "Author", Fields: icloud.Fields{
+		{Name: "name", Type: "STRING", Value: "Alice"},
+	}},
+	{Name: "hello", Type: "Post", Fields: icloud.Fields{
+		{Name: "title", Type: "STRING", Value: "Hello"},
+		{Name: "likes", Type: "INT64", Value: 42},
+		{Name: "cover", Type: "ASSETID", Value: map[string]interface{}{"fileChecksum": "abc"}},
+		{Name: "tags", Type: "UNKNOWN_LIST", Value: []interface{}{}},
+		{Name: "drafts", Type: "UNKNOWN_LIST", Value: []interface{}{}},
+	}},
+	{Name: "bye", Type: "Post", Fields: icloud.Fields{
+		{Name: "title", Type: "STRING", Value: "Bye"},
+		{Name: "likes", Type: "DOUBLE", Value: 4.5},
+		{Name: "tags", Type: "STRING_LIST", Value: []interface{}{"go"}},
+		{Name: "cover", Value: nil},
+	}},
+	{Name: "draft", Type: "Post", Fields: icloud.Fields{
+		{Name: "title", Type: "STRING", Value: "Draft"},
+		{Name: "likes", Type: "INT64", Value: 0},
+	}},
+	{Name: "gone", Type: "Comment", Deleted: true},
+}
+
+const expSchema = `DEFINE SCHEMA
+
+    RECORD TYPE Author (
+        "___createTime" TIMESTAMP,
+        "___createdBy"  REFERENCE,
+        "___etag"       STRING,
+        "___modTime"    TIMESTAMP,
+        "___modifiedBy" REFERENCE,
+        "___recordID"   REFERENCE QUERYABLE,
+        name            STRING
+    );
+
+    RECORD TYPE Post (
+        "___createTime" TIMESTAMP,
+        "___createdBy"  REFERENCE,
+        "___etag"       STRING,
+        "___modTime"    TIMESTAMP,
+        "___modifiedBy" REFERENCE,
+        "___recordID"   REFERENCE QUERYABLE,
+        cover           ASSET,
+        likes           INT64,
+        tags            LIST<STRING>,
+        title           STRING
+    );
+`
+
+func TestInferrer(t *testing.T) {
+	inf := infer.New()
+	inf.Add(records...)
+
+	res := inf.Result()
+	assert.Equal(t, 4, res.Records)
+	assert.Equal(t, expSchema, res.Schema.String())
+
+	assert.Equal(t, []infer.FieldStats{
+		{RecordType: "Author", Name: "name", Type: icloud.TypeString, Types: map[icloud.FieldType]int{icloud.TypeString: 1}, Count: 1},
+		{RecordType: "Post", Name: "cover", Type: icloud.TypeAsset, Types: map[icloud.FieldType]int{icloud.TypeAsset: 1}, Count: 1, Optional: true},
+		{RecordType: "Post", Name: "drafts", Types: map[icloud.FieldType]int{icloud.TypeUnknownList: 1}, Count: 1, Optional: true},
+		{RecordType: "Post", Name: "likes", Type: icloud.TypeInt64, Types: map[icloud.FieldType]int{icloud.TypeInt64: 2, icloud.TypeDouble: 1}, Count: 3},
+		{RecordType: "Post", Name: "tags", Type: icloud.TypeStringList, Types: map[icloud.FieldType]int{icloud.TypeStringList: 1, icloud.TypeUnknownList: 1}, Count: 2, Optional: true},
+		{RecordType: "Post", Name: "title", Type: icloud.TypeString, Types: map[icloud.FieldType]int{icloud.TypeString: 3}, Count: 3},
+	}, res.Fields)
+
+	if inconsistencies := res.Inconsistencies(); assert.Len(t, inconsistencies, 1) {
+		assert.Equal(t, "likes", inconsistencies[0].Name)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, res.WriteReport(&buf))
+	assert.Equal(t, `sampled 4 records of 2 record types
+Post.drafts: unknown type, only empty lists seen (1)
+Post.likes: inconsistent types INT64 (2), DOUBLE (1), inferred INT64
+`, buf.String())
+}
+
+func TestInferrer_ZeroValue(t *testing.T) {
+	var inf infer.Inferrer
+
+	res := inf.Result()
+	assert.Zero(t, res.Records)
+	assert.Empty(t, res.Schema.RecordTypes)
+
+	inf.Add(records[0])
+	assert.Equal(t, 1, inf.Result().Records)
+}
+
+func TestSample(t *testing.T) {
+	srv := icloudtest.NewServer()
+	defer srv.Close()
+
+	client, err := srv.NewClient("iCloud.com.lukasmalkmus.Example-App", icloud.Development)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	zoneID := icloud.ZoneID{Name: "Legacy"}
+	zoneRes, err := client.Zones.Modify(ctx, icloud.Private, icloud.ZonesRequest{
+		Operations: []icloud.ZoneOperation{
+			{Type: icloud.Create, Zone: icloud.Zone{ZoneID: zoneID}},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, zoneRes.Zones[0].Err())
+
+	var ops []icloud.RecordOperation
+	for _, database := range []icloud.Database{icloud.Public, icloud.Private} {
+		ops = ops[:0]
+		for _, record := range records {
+			if record.Deleted {
+				continue
+			}
+			record.Fields = withoutAssets(record.Fields)
+			ops = append(ops, icloud.RecordOperation{Type: icloud.Create, Record: record})
+		}
+
+		req := icloud.RecordsRequest{Operations: ops}
+		if database == icloud.Private {
+			req.ZoneID = &zoneID
+		}
+		res, modifyErr := client.Records.Modify(ctx, database, req)
+		require.NoError(t, modifyErr)
+		for _, record := range res.Records {
+			require.NoError(t, record.Err())
+		}
+	}
+
+	t.Run("query", func(t *testing.T) {
+		res, err := infer.Sample(ctx, client, infer.Options{
+			RecordTypes: []string{"Post"},
+			Limit:       2,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, res.Records)
+		if assert.Len(t, res.Schema.RecordTypes, 1) {
+			assert.Equal(t, "Post", res.Schema.RecordTypes[0].Name)
+		}
+	})
+
+	t.Run("changes", func(t *testing.T) {
+		res, err := infer.Sample(ctx, client, infer.Options{
+			Database: icloud.Private,
+			Zones:    []icloud.ZoneID{zoneID},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, 4, res.Records)
+		assert.Len(t, res.Schema.RecordTypes, 2)
+		assert.Len(t, res.Inconsistencies(), 1)
+	})
+
+	t.Run("default zone without record types", func(t *testing.T) {
+		_, err := infer.Sample(ctx, client, infer.Options{})
+		assert.EqualError(t, err, `the record types of zone "_defaultZone" can't be discovered, specify the record types to sample`)
+	})
+}
+
+// withoutAssets returns the fields without the asset fields, which must be
+// uploaded before they can be saved.
+func withoutAssets(fields icloud.Fields) icloud.Fields {
+	var res icloud.Fields
+	for _, field := range fields {
+		if field.Type != "ASSETID" {
+			res = append(res, field)
+		}
+	}
+	return res
+}
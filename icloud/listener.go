@@ -0,0 +1,171 @@
+package icloud
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Default backoff of a NotificationListener.
+const (
+	DefaultMinBackoff = time.Second
+	DefaultMaxBackoff = time.Minute
+)
+
+// NotificationListener receives the notifications of a token created by
+// TokensService.Create by long polling its web courier URL. It lets clients
+// without an APNs device token, like a backend, receive the notifications of
+// their subscriptions:
+//
+//	token, err := client.Tokens.Create(ctx, icloud.CreateTokenRequest{
+//		APNSEnvironment: icloud.APNSDevelopment,
+//	})
+//	if err != nil {
+//		return err
+//	}
+//
+//	l, err := icloud.NewNotificationListener(client, token.WebcourierURL)
+//	if err != nil {
+//		return err
+//	}
+//
+//	for n := range l.Notifications(ctx) {
+//		// Handle n.
+//	}
+//
+// A poll that fails is retried after a backoff which doubles with every
+// consecutive failure. The fields must not be changed while listening.
+type NotificationListener struct {
+	// MinBackoff is the time to wait before polling again after a failed
+	// poll. If not set, DefaultMinBackoff is used.
+	MinBackoff time.Duration
+	// MaxBackoff is the maximum time to wait before polling again after
+	// consecutive failed polls. A longer retry after duration suggested by
+	// the server takes precedence. If not set, DefaultMaxBackoff is used.
+	MaxBackoff time.Duration
+	// OnError is called with the error of a failed poll or of a notification
+	// that can't be parsed. Listening continues afterwards. Optional.
+	OnError func(error)
+
+	client *Client
+	url    string
+}
+
+// NewNotificationListener returns a new NotificationListener for the given web
+// courier URL. Polls are sent by the HTTP client and invoke the hooks of the
+// given client.
+func NewNotificationListener(client *Client, webcourierURL string) (*NotificationListener, error) {
+	if _, err := url.ParseRequestURI(webcourierURL); err != nil {
+		return nil, err
+	}
+
+	return &NotificationListener{
+		client: client,
+		url:    webcourierURL,
+	}, nil
+}
+
+// Listen polls the web courier URL and calls handler with every notification
+// received, until the context is cancelled. The handler is called from the
+// calling goroutine, so no polls are sent while it runs. The returned error is
+// always the error of the context.
+func (l *NotificationListener) Listen(ctx context.Context, handler func(Notification)) error {
+	minBackoff, maxBackoff := l.MinBackoff, l.MaxBackoff
+	if minBackoff <= 0 {
+		minBackoff = DefaultMinBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+
+	backoff := minBackoff
+	for {
+		payload, err := l.poll(ctx)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		if err != nil {
+			l.onError(err)
+
+			wait := backoff
+			var apiErr Error
+			if errors.As(err, &apiErr) && apiErr.RetryAfter > wait {
+				wait = apiErr.RetryAfter
+			}
+
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+			continue
+		}
+		backoff = minBackoff
+
+		// The web courier responds without a notification if none arrived
+		// before the long poll timed out.
+		if len(payload) == 0 {
+			continue
+		}
+
+		n, err := ParseNotification(payload)
+		if err != nil {
+			l.onError(err)
+			continue
+		}
+		handler(n)
+	}
+}
+
+// Notifications listens in a separate goroutine and delivers the notifications
+// received on the returned channel, until the context is cancelled. The
+// channel is closed when listening stopped. It must be drained, as no polls are
+// sent while a notification waits for delivery.
+func (l *NotificationListener) Notifications(ctx context.Context) <-chan Notification {
+	ch := make(chan Notification)
+	go func() {
+		defer close(ch)
+
+		_ = l.Listen(ctx, func(n Notification) {
+			select {
+			case ch <- n:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return ch
+}
+
+// poll sends a single long poll to the web courier URL and returns the
+// payload of the response, which is empty if no notification arrived.
+func (l *NotificationListener) poll(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("user-agent", l.client.userAgent)
+
+	var buf bytes.Buffer
+	if err = l.client.do(req, &buf); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSpace(buf.Bytes()), nil
+}
+
+// onError passes err to the OnError callback, if set.
+func (l *NotificationListener) onError(err error) {
+	if l.OnError != nil {
+		l.OnError(err)
+	}
+}
@@ -0,0 +1,114 @@
+package icloud
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationListener(t *testing.T) {
+	var (
+		polls    int32
+		payloads = make(chan string)
+	)
+
+	// The stand-in for the web courier fails the first poll and holds the
+	// following ones open until a payload is sent.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "icloud-go", r.Header.Get("user-agent"))
+
+		if atomic.AddInt32(&polls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		select {
+		case payload := <-payloads:
+			_, _ = w.Write([]byte(payload))
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(container, keyID, privateKey, environment, SetHTTPClient(srv.Client()))
+	require.NoError(t, err)
+
+	l, err := NewNotificationListener(client, srv.URL+"/webcourier/cafe")
+	require.NoError(t, err)
+	l.MinBackoff = time.Millisecond
+
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	l.OnError = func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	notifications := l.Notifications(ctx)
+
+	payloads <- ""
+	payloads <- `{"aps": {}}`
+	payloads <- `{"ck": {"cid": "` + container + `", "nid": "1", "met": {"dbs": 1, "sid": "changes"}}}`
+
+	n := <-notifications
+	require.NotNil(t, n)
+	assert.Equal(t, &DatabaseNotification{
+		NotificationHeader: NotificationHeader{
+			ContainerID:    container,
+			NotificationID: "1",
+			SubscriptionID: "changes",
+			Database:       Public,
+		},
+	}, n)
+
+	cancel()
+	_, ok := <-notifications
+	assert.False(t, ok)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if assert.Len(t, errs, 2) {
+		assert.EqualError(t, errs[0], "GET /webcourier/cafe: 503 "+http.StatusText(http.StatusServiceUnavailable))
+		assert.EqualError(t, errs[1], "not a CloudKit notification: missing ck dictionary")
+	}
+	assert.GreaterOrEqual(t, atomic.LoadInt32(&polls), int32(4))
+}
+
+func TestNotificationListener_Listen_Cancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(container, keyID, privateKey, environment, SetHTTPClient(srv.Client()))
+	require.NoError(t, err)
+
+	l, err := NewNotificationListener(client, srv.URL)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = l.Listen(ctx, func(Notification) { t.Error("unexpected notification") })
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestNewNotificationListener_InvalidURL(t *testing.T) {
+	_, err := NewNotificationListener(nil, "webcourier")
+	assert.Error(t, err)
+}
@@ -0,0 +1,522 @@
+// Package migrate copies records between the environments of a container, e.g.
+// to seed the production environment with data maintained in the development
+// environment before an app update is released:
+//
+//	res, err := migrate.Migrate(ctx, devClient, prodClient, migrate.Options{
+//		Database:    icloud.Public,
+//		RecordTypes: []string{"Author", "Post"},
+//	})
+//
+// Records keep their names, which makes a migration repeatable: Records missing
+// in the destination are created, records that differ are replaced and records
+// that are equal are skipped. Fields of type REFERENCE are rewritten to point
+// to the zones of the destination and assets are downloaded from the source
+// and uploaded to the destination.
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// Options configure a migration.
+type Options struct {
+	// Database to migrate. Defaults to the public database.
+	Database icloud.Database
+	// RecordTypes to migrate. The types are migrated in the given order, which
+	// matters for references that are validated by the server: Referenced
+	// types must be migrated before the types referencing them.
+	RecordTypes []string
+	// Zones to migrate. Zones missing in the destination are created. If not
+	// set, the default zone is migrated.
+	Zones []icloud.ZoneID
+	// PageSize is the number of records read and written per request. Defaults
+	// to and is limited by icloud.MaxOperationPerRequest.
+	PageSize int
+	// DryRun compares the records of both environments without writing to the
+	// destination. The result reports what a migration would do.
+	DryRun bool
+}
+
+// Result is the outcome of a migration.
+type Result struct {
+	// Created is the number of records created in the destination.
+	Created int
+	// Updated is the number of records replaced in the destination because
+	// they differed from the source.
+	Updated int
+	// Skipped is the number of records which already are equal in both
+	// environments.
+	Skipped int
+	// Failed are the records the destination rejected. They carry the error.
+	Failed []icloud.Record
+}
+
+// Migrate copies the records selected by the options from the src to the dst
+// client. Both clients must access the same container but different
+// environments. Records rejected by the destination are reported in the
+// result. An error is returned if a request fails as a whole, along with the
+// result up to that point.
+//
+// References to records in zones which aren't migrated are copied unchanged.
+func Migrate(ctx context.Context, src, dst *icloud.Client, opts Options) (*Result, error) {
+	if src.Container() != dst.Container() {
+		return nil, fmt.Errorf("containers %q and %q differ", src.Container(), dst.Container())
+	} else if src.Environment() == dst.Environment() {
+		return nil, fmt.Errorf("source and destination use the same environment %q", src.Environment())
+	} else if len(opts.RecordTypes) == 0 {
+		return nil, errors.New("missing record types")
+	}
+
+	if opts.Database == 0 {
+		opts.Database = icloud.Public
+	}
+	if len(opts.Zones) == 0 {
+		opts.Zones = []icloud.ZoneID{{Name: icloud.DefaultZoneName}}
+	}
+	if opts.PageSize <= 0 || opts.PageSize > icloud.MaxOperationPerRequest {
+		opts.PageSize = icloud.MaxOperationPerRequest
+	}
+
+	m := &migration{
+		src:  src,
+		dst:  dst,
+		opts: opts,
+		res:  new(Result),
+	}
+
+	if err := m.prepareZones(ctx); err != nil {
+		return m.res, err
+	}
+
+	for _, zoneID := range opts.Zones {
+		for _, recordType := range opts.RecordTypes {
+			if err := m.migrateType(ctx, zoneID, recordType); err != nil {
+				return m.res, fmt.Errorf("migrate %q records of zone %q: %w", recordType, zoneID.Name, err)
+			}
+		}
+	}
+
+	return m.res, nil
+}
+
+// migration is the state of a single migration.
+type migration struct {
+	src, dst *icloud.Client
+	opts     Options
+	res      *Result
+
+	// zones maps the names of the migrated zones to their ids in the
+	// destination.
+	zones map[string]icloud.ZoneID
+	// missing holds the names of the zones not present in the destination.
+	// Only populated on a dry run, otherwise missing zones are created.
+	missing map[string]bool
+}
+
+// prepareZones looks up the migrated zones in the destination and creates the
+// ones that are missing.
+func (m *migration) prepareZones(ctx context.Context) error {
+	m.zones = make(map[string]icloud.ZoneID, len(m.opts.Zones))
+	m.missing = make(map[string]bool)
+
+	var custom []string
+	for _, zoneID := range m.opts.Zones {
+		if zoneID.Name == icloud.DefaultZoneName {
+			m.zones[zoneID.Name] = icloud.ZoneID{Name: icloud.DefaultZoneName}
+		} else {
+			custom = append(custom, zoneID.Name)
+		}
+	}
+
+	if len(custom) == 0 {
+		return nil
+	}
+
+	res, err := m.dst.Zones.List(ctx, m.opts.Database)
+	if err != nil {
+		return fmt.Errorf("list destination zones: %w", err)
+	}
+
+	existing := make(map[string]icloud.ZoneID, len(res.Zones))
+	for _, zone := range res.Zones {
+		existing[zone.ZoneID.Name] = zone.ZoneID
+	}
+
+	var create []icloud.ZoneOperation
+	for _, name := range custom {
+		if zoneID, ok := existing[name]; ok {
+			m.zones[name] = zoneID
+			continue
+		}
+
+		m.zones[name] = icloud.ZoneID{Name: name}
+		if m.opts.DryRun {
+			m.missing[name] = true
+			continue
+		}
+		create = append(create, icloud.ZoneOperation{
+			Type: icloud.Create,
+			Zone: icloud.Zone{ZoneID: icloud.ZoneID{Name: name}},
+		})
+	}
+
+	if len(create) == 0 {
+		return nil
+	}
+
+	res, err = m.dst.Zones.Modify(ctx, m.opts.Database, icloud.ZonesRequest{Operations: create})
+	if err != nil {
+		return fmt.Errorf("create destination zones: %w", err)
+	}
+	for _, zone := range res.Zones {
+		if err = zone.Err(); err != nil {
+			return fmt.Errorf("create destination zone %q: %w", zone.ZoneID.Name, err)
+		}
+		m.zones[zone.ZoneID.Name] = zone.ZoneID
+	}
+
+	return nil
+}
+
+// migrateType migrates the records of the given type in the zone page by
+// page.
+func (m *migration) migrateType(ctx context.Context, zoneID icloud.ZoneID, recordType string) error {
+	req := icloud.QueryRequest{
+		ZoneID:       &zoneID,
+		ResultsLimit: m.opts.PageSize,
+		Query:        icloud.Query{RecordType: recordType},
+	}
+
+	for {
+		page, err := m.src.Records.Query(ctx, m.opts.Database, req)
+		if err != nil {
+			return err
+		}
+
+		if err = m.migratePage(ctx, zoneID.Name, page.Records); err != nil {
+			return err
+		}
+
+		if page.ContinuationMarker == "" {
+			return nil
+		}
+		req.ContinuationMarker = page.ContinuationMarker
+	}
+}
+
+// migratePage compares a page of source records with their counterparts in
+// the destination and writes the ones that are missing or differ.
+func (m *migration) migratePage(ctx context.Context, zoneName string, records []icloud.Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	zoneID := m.zones[zoneName]
+
+	existing, err := m.lookup(ctx, zoneID, records)
+	if err != nil {
+		return err
+	}
+
+	var (
+		ops      []icloud.RecordOperation
+		counters []*int
+	)
+	for _, record := range records {
+		record = icloud.Record{
+			Name:   record.Name,
+			Type:   record.Type,
+			Fields: m.rewriteReferences(record.Fields),
+		}
+
+		other, ok := existing[record.Name]
+		switch {
+		case !ok || other.ServerErrorCode == icloud.NotFound:
+			ops = append(ops, icloud.RecordOperation{Type: icloud.Create, Record: record})
+			counters = append(counters, &m.res.Created)
+		case other.Err() != nil:
+			m.res.Failed = append(m.res.Failed, other)
+		case other.Type == record.Type && equalFields(other.Fields, record.Fields):
+			m.res.Skipped++
+		default:
+			ops = append(ops, icloud.RecordOperation{Type: icloud.ForceReplace, Record: record})
+			counters = append(counters, &m.res.Updated)
+		}
+	}
+
+	if m.opts.DryRun {
+		for _, counter := range counters {
+			*counter++
+		}
+		return nil
+	}
+
+	if len(ops) == 0 {
+		return nil
+	}
+
+	if err = m.copyAssets(ctx, zoneID, ops); err != nil {
+		return err
+	}
+
+	res, err := m.dst.Records.Modify(ctx, m.opts.Database, icloud.RecordsRequest{
+		ZoneID:     &zoneID,
+		Operations: ops,
+	})
+	if err != nil {
+		return fmt.Errorf("write batch of %d records: %w", len(ops), err)
+	}
+
+	for i, record := range res.Records {
+		if record.Err() != nil {
+			m.res.Failed = append(m.res.Failed, record)
+		} else if i < len(counters) {
+			*counters[i]++
+		}
+	}
+
+	return nil
+}
+
+// lookup fetches the destination counterparts of the records by name. Nothing
+// is fetched if the zone is missing in the destination.
+func (m *migration) lookup(ctx context.Context, zoneID icloud.ZoneID, records []icloud.Record) (map[string]icloud.Record, error) {
+	if m.missing[zoneID.Name] {
+		return nil, nil
+	}
+
+	req := icloud.LookupRequest{
+		ZoneID:  &zoneID,
+		Records: make([]icloud.Record, len(records)),
+	}
+	for i, record := range records {
+		req.Records[i] = icloud.Record{Name: record.Name}
+	}
+
+	res, err := m.dst.Records.Lookup(ctx, m.opts.Database, req)
+	if err != nil {
+		return nil, fmt.Errorf("look up %d destination records: %w", len(records), err)
+	}
+
+	existing := make(map[string]icloud.Record, len(res.Records))
+	for _, record := range res.Records {
+		existing[record.Name] = record
+	}
+	return existing, nil
+}
+
+// rewriteReferences returns a copy of the fields in which the zones of
+// references to migrated zones are replaced by their destination ids.
+func (m *migration) rewriteReferences(fields icloud.Fields) icloud.Fields {
+	rewritten := make(icloud.Fields, len(fields))
+	for i, field := range fields {
+		switch field.Type {
+		case icloud.TypeReference.String():
+			field.Value = m.rewriteReference(field.Value)
+		case icloud.TypeReferenceList.String():
+			if list, ok := field.Value.([]interface{}); ok {
+				refs := make([]interface{}, len(list))
+				for j, v := range list {
+					refs[j] = m.rewriteReference(v)
+				}
+				field.Value = refs
+			}
+		}
+		rewritten[i] = field
+	}
+	return rewritten
+}
+
+// rewriteReference rewrites the zone of a single reference value.
+func (m *migration) rewriteReference(v interface{}) interface{} {
+	ref, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+	zone, ok := ref["zoneID"].(map[string]interface{})
+	if !ok {
+		return v
+	}
+	name, _ := zone["zoneName"].(string)
+	zoneID, ok := m.zones[name]
+	if !ok {
+		return v
+	}
+
+	rewritten := make(map[string]interface{}, len(ref))
+	for k, v := range ref {
+		rewritten[k] = v
+	}
+	zone = map[string]interface{}{"zoneName": zoneID.Name}
+	if zoneID.OwnerName != "" {
+		zone["ownerRecordName"] = zoneID.OwnerName
+	}
+	rewritten["zoneID"] = zone
+
+	return rewritten
+}
+
+// assetSlot is a field value holding an asset which has to be copied to the
+// destination.
+type assetSlot struct {
+	value *interface{}
+	asset icloud.Asset
+}
+
+// copyAssets downloads the assets of the records from the source, uploads
+// them to the destination and replaces the field values with the uploaded
+// assets.
+func (m *migration) copyAssets(ctx context.Context, zoneID icloud.ZoneID, ops []icloud.RecordOperation) error {
+	var (
+		slots  []assetSlot
+		tokens []icloud.UploadToken
+	)
+	for _, op := range ops {
+		for i := range op.Record.Fields {
+			field := &op.Record.Fields[i]
+
+			var values []*interface{}
+			switch field.Type {
+			case icloud.TypeAsset.String(), icloud.TypeAssetID.String():
+				values = append(values, &field.Value)
+			case icloud.TypeAssetList.String(), icloud.TypeAssetIDList.String():
+				list, ok := field.Value.([]interface{})
+				if !ok {
+					continue
+				}
+				list = append([]interface{}(nil), list...)
+				for j := range list {
+					values = append(values, &list[j])
+				}
+				field.Value = list
+			default:
+				continue
+			}
+
+			for _, value := range values {
+				asset, ok := decodeAsset(*value)
+				if !ok {
+					continue
+				}
+				slots = append(slots, assetSlot{value: value, asset: asset})
+				tokens = append(tokens, icloud.UploadToken{
+					RecordName: op.Record.Name,
+					RecordType: op.Record.Type,
+					FieldName:  field.Name,
+				})
+			}
+		}
+	}
+
+	if len(slots) == 0 {
+		return nil
+	}
+
+	res, err := m.dst.Assets.Upload(ctx, m.opts.Database, icloud.UploadRequest{
+		ZoneID: &zoneID,
+		Tokens: tokens,
+	})
+	if err != nil {
+		return fmt.Errorf("request upload of %d assets: %w", len(tokens), err)
+	} else if len(res.Tokens) != len(slots) {
+		return fmt.Errorf("requested upload of %d assets, got %d upload urls", len(slots), len(res.Tokens))
+	}
+
+	var buf bytes.Buffer
+	for i, slot := range slots {
+		buf.Reset()
+		if err = m.src.Assets.Download(ctx, slot.asset, &buf); err != nil {
+			return fmt.Errorf("download asset of field %q of record %q: %w", tokens[i].FieldName, tokens[i].RecordName, err)
+		}
+
+		asset, err := m.dst.Assets.UploadData(ctx, res.Tokens[i].URL, &buf)
+		if err != nil {
+			return fmt.Errorf("upload asset of field %q of record %q: %w", tokens[i].FieldName, tokens[i].RecordName, err)
+		}
+		*slot.value = *asset
+	}
+
+	return nil
+}
+
+// decodeAsset returns the asset of a field value as decoded from JSON. Only
+// assets with a download url can be copied.
+func decodeAsset(v interface{}) (icloud.Asset, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return icloud.Asset{}, false
+	}
+	downloadURL, _ := m["downloadURL"].(string)
+	fileChecksum, _ := m["fileChecksum"].(string)
+	return icloud.Asset{
+		FileChecksum: fileChecksum,
+		DownloadURL:  downloadURL,
+	}, downloadURL != ""
+}
+
+// equalFields returns true, if both sets of fields hold the same values,
+// regardless of their order. Assets are compared by their checksum and
+// references by the record and zone they point to.
+func equalFields(a, b icloud.Fields) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	values := make(map[string]icloud.Field, len(a))
+	for _, field := range a {
+		values[field.Name] = field
+	}
+
+	for _, field := range b {
+		other, ok := values[field.Name]
+		if !ok || other.Type != field.Type {
+			return false
+		}
+		typ, _ := icloud.ParseFieldType(field.Type)
+		if !reflect.DeepEqual(identity(typ, other.Value), identity(typ, field.Value)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// identity returns the part of a field value as decoded from JSON which
+// identifies it across environments.
+func identity(typ icloud.FieldType, v interface{}) interface{} {
+	if typ.IsList() {
+		list, ok := v.([]interface{})
+		if !ok {
+			return v
+		}
+		values := make([]interface{}, len(list))
+		for i, elem := range list {
+			values[i] = identity(typ.Elem(), elem)
+		}
+		return values
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return v
+	}
+
+	switch typ {
+	case icloud.TypeAsset, icloud.TypeAssetID:
+		return m["fileChecksum"]
+	case icloud.TypeReference:
+		var zoneName interface{} = icloud.DefaultZoneName
+		if zone, ok := m["zoneID"].(map[string]interface{}); ok {
+			zoneName = zone["zoneName"]
+		}
+		return [3]interface{}{m["recordName"], zoneName, m["action"]}
+	}
+
+	return v
+}
@@ -0,0 +1,190 @@
+package migrate_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+	"github.com/lukasmalkmus/icloud-go/icloud/icloudtest"
+	"github.com/lukasmalkmus/icloud-go/icloud/migrate"
+)
+
+const container = "iCloud.com.lukasmalkmus.Example-App"
+
+var (
+	zoneID  = icloud.ZoneID{Name: "Seed"}
+	options = migrate.Options{
+		Database:    icloud.Private,
+		RecordTypes: []string{"Author", "Post"},
+		Zones:       []icloud.ZoneID{zoneID},
+		PageSize:    1,
+	}
+)
+
+func setup(t *testing.T) (dev, prod *icloud.Client, teardown func()) {
+	t.Helper()
+
+	srv := icloudtest.NewServer()
+
+	dev, err := srv.NewClient(container, icloud.Development)
+	require.NoError(t, err)
+	prod, err = srv.NewClient(container, icloud.Production)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	zoneRes, err := dev.Zones.Modify(ctx, icloud.Private, icloud.ZonesRequest{
+		Operations: []icloud.ZoneOperation{
+			{Type: icloud.Create, Zone: icloud.Zone{ZoneID: zoneID}},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, zoneRes.Zones[0].Err())
+
+	uploadRes, err := dev.Assets.Upload(ctx, icloud.Private, icloud.UploadRequest{
+		ZoneID: &zoneID,
+		Tokens: []icloud.UploadToken{
+			{RecordName: "hello", RecordType: "Post", FieldName: "cover"},
+		},
+	})
+	require.NoError(t, err)
+
+	cover, err := dev.Assets.UploadData(ctx, uploadRes.Tokens[0].URL, strings.NewReader("cover image"))
+	require.NoError(t, err)
+
+	res, err := dev.Records.Modify(ctx, icloud.Private, icloud.RecordsRequest{
+		ZoneID: &zoneID,
+		Operations: []icloud.RecordOperation{
+			{Type: icloud.Create, Record: icloud.Record{
+				Name: "alice",
+				Type: "Author",
+				Fields: icloud.Fields{
+					{Name: "name", Value: "Alice"},
+				},
+			}},
+			{Type: icloud.Create, Record: icloud.Record{
+				Name: "hello",
+				Type: "Post",
+				Fields: icloud.Fields{
+					{Name: "title", Value: "Hello"},
+					{Name: "author", Type: "REFERENCE", Value: map[string]interface{}{
+						"recordName": "alice",
+						"zoneID":     zoneID,
+						"action":     "DELETE_SELF",
+					}},
+					{Name: "cover", Type: "ASSETID", Value: cover},
+				},
+			}},
+		},
+	})
+	require.NoError(t, err)
+	for _, record := range res.Records {
+		require.NoError(t, record.Err())
+	}
+
+	return dev, prod, srv.Close
+}
+
+func TestMigrate(t *testing.T) {
+	dev, prod, teardown := setup(t)
+	defer teardown()
+
+	ctx := context.Background()
+
+	res, err := migrate.Migrate(ctx, dev, prod, options)
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Created)
+	assert.Zero(t, res.Updated)
+	assert.Zero(t, res.Skipped)
+	assert.Empty(t, res.Failed)
+
+	lookup, err := prod.Records.Lookup(ctx, icloud.Private, icloud.LookupRequest{
+		ZoneID:  &zoneID,
+		Records: []icloud.Record{{Name: "hello"}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, lookup.Records[0].Err())
+	assert.Equal(t, "Post", lookup.Records[0].Type)
+
+	fields := make(map[string]icloud.Field)
+	for _, field := range lookup.Records[0].Fields {
+		fields[field.Name] = field
+	}
+	assert.Equal(t, "Hello", fields["title"].Value)
+	assert.Equal(t, map[string]interface{}{
+		"recordName": "alice",
+		"zoneID":     map[string]interface{}{"zoneName": "Seed"},
+		"action":     "DELETE_SELF",
+	}, fields["author"].Value)
+
+	cover, ok := fields["cover"].Value.(map[string]interface{})
+	require.True(t, ok)
+
+	var buf bytes.Buffer
+	err = prod.Assets.Download(ctx, icloud.Asset{DownloadURL: cover["downloadURL"].(string)}, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, "cover image", buf.String())
+
+	// Migrating again skips the unchanged records.
+	res, err = migrate.Migrate(ctx, dev, prod, options)
+	require.NoError(t, err)
+	assert.Zero(t, res.Created)
+	assert.Zero(t, res.Updated)
+	assert.Equal(t, 2, res.Skipped)
+
+	// Changed records are updated.
+	_, err = dev.Records.Modify(ctx, icloud.Private, icloud.RecordsRequest{
+		ZoneID: &zoneID,
+		Operations: []icloud.RecordOperation{
+			{Type: icloud.ForceUpdate, Record: icloud.Record{
+				Name: "alice",
+				Fields: icloud.Fields{
+					{Name: "name", Value: "Alice Liddell"},
+				},
+			}},
+		},
+	})
+	require.NoError(t, err)
+
+	res, err = migrate.Migrate(ctx, dev, prod, options)
+	require.NoError(t, err)
+	assert.Zero(t, res.Created)
+	assert.Equal(t, 1, res.Updated)
+	assert.Equal(t, 1, res.Skipped)
+}
+
+func TestMigrate_DryRun(t *testing.T) {
+	dev, prod, teardown := setup(t)
+	defer teardown()
+
+	ctx := context.Background()
+
+	opts := options
+	opts.DryRun = true
+
+	res, err := migrate.Migrate(ctx, dev, prod, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Created)
+
+	zones, err := prod.Zones.List(ctx, icloud.Private)
+	require.NoError(t, err)
+	for _, zone := range zones.Zones {
+		assert.NotEqual(t, zoneID.Name, zone.ZoneID.Name)
+	}
+}
+
+func TestMigrate_Validation(t *testing.T) {
+	dev, prod, teardown := setup(t)
+	defer teardown()
+
+	_, err := migrate.Migrate(context.Background(), dev, dev, options)
+	assert.EqualError(t, err, `source and destination use the same environment "development"`)
+
+	_, err = migrate.Migrate(context.Background(), dev, prod, migrate.Options{})
+	assert.EqualError(t, err, "missing record types")
+}
@@ -0,0 +1,89 @@
+// Package ndjson implements a lossless interchange format for records based on
+// newline delimited JSON. Every line holds a single record in the JSON format
+// of the CloudKit Web Services API, including its type, name, zone, change tag
+// and typed fields:
+//
+//	{"recordName":"a","recordType":"Post","recordChangeTag":"1","zoneID":{"zoneName":"_defaultZone"},"fields":{"title":{"type":"STRING","value":"Hello"}}}
+//
+// Export and Import stream records between a database and the format without
+// loading them into memory as a whole, e.g. to back up a database or to copy
+// records from the development to the production environment:
+//
+//	var buf bytes.Buffer
+//	if _, err := ndjson.Export(ctx, devClient, &buf, ndjson.ExportOptions{
+//		Query: &icloud.Query{RecordType: "Post"},
+//	}); err != nil {
+//		log.Fatal(err)
+//	}
+//
+//	res, err := ndjson.Import(ctx, prodClient, &buf, ndjson.ImportOptions{})
+package ndjson
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// An Encoder writes records as newline delimited JSON to an output stream.
+type Encoder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+// NewEncoder returns a new encoder that writes to w. The encoder buffers its
+// output, Flush must be called after the last record has been encoded.
+func NewEncoder(w io.Writer) *Encoder {
+	bw := bufio.NewWriter(w)
+	return &Encoder{
+		w:   bw,
+		enc: json.NewEncoder(bw),
+	}
+}
+
+// Encode writes the record as a single line to the stream.
+func (e *Encoder) Encode(record icloud.Record) error {
+	return e.enc.Encode(record)
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (e *Encoder) Flush() error {
+	return e.w.Flush()
+}
+
+// A Decoder reads records as newline delimited JSON from an input stream.
+//
+// Records are decoded just like the ones returned by the server, so values of
+// fields of type INT64 are int64 and survive a round trip without losing
+// precision.
+type Decoder struct {
+	dec *json.Decoder
+	n   int
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{dec: json.NewDecoder(r)}
+}
+
+// Decode reads the next record from the stream. It returns io.EOF if there are
+// no more records.
+func (d *Decoder) Decode() (icloud.Record, error) {
+	var raw json.RawMessage
+	if err := d.dec.Decode(&raw); errors.Is(err, io.EOF) {
+		return icloud.Record{}, io.EOF
+	} else if err != nil {
+		return icloud.Record{}, fmt.Errorf("record %d: %w", d.n+1, err)
+	}
+	d.n++
+
+	var record icloud.Record
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return record, fmt.Errorf("record %d: %w", d.n, err)
+	}
+	return record, nil
+}
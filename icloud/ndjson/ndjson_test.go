@@ -0,0 +1,291 @@
+package ndjson_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+	"github.com/lukasmalkmus/icloud-go/icloud/icloudtest"
+	"github.com/lukasmalkmus/icloud-go/icloud/ndjson"
+)
+
+const container = "iCloud.com.lukasmalkmus.Example-App"
+
+func TestEncoderDecoder(t *testing.T) {
+	// 2^53 + 1 can't be represented as float64.
+	const input = `{"recordName":"a","recordType":"Post","recordChangeTag":"3","zoneID":{"zoneName":"Posts"},"fields":{"likes":{"type":"INT64","value":9007199254740993},"title":{"type":"STRING","value":"Hello"}}}
+{"recordName":"b","recordType":"Post","deleted":true}
+`
+
+	dec := ndjson.NewDecoder(strings.NewReader(input))
+
+	a, err := dec.Decode()
+	require.NoError(t, err)
+	assert.Equal(t, "a", a.Name)
+	assert.Equal(t, "3", a.ChangeTag)
+	assert.Equal(t, &icloud.ZoneID{Name: "Posts"}, a.ZoneID)
+	assert.Equal(t, icloud.Fields{
+		{Name: "likes", Type: "INT64", Value: int64(9007199254740993)},
+		{Name: "title", Type: "STRING", Value: "Hello"},
+	}, a.Fields)
+
+	b, err := dec.Decode()
+	require.NoError(t, err)
+	assert.True(t, b.Deleted)
+
+	_, err = dec.Decode()
+	assert.Equal(t, io.EOF, err)
+
+	var buf bytes.Buffer
+	enc := ndjson.NewEncoder(&buf)
+	require.NoError(t, enc.Encode(a))
+	require.NoError(t, enc.Encode(b))
+	require.NoError(t, enc.Flush())
+
+	assert.Equal(t, input, buf.String())
+}
+
+func TestEncoderDecoder_UnknownValues(t *testing.T) {
+	const input = `{"recordName":"a","recordType":"cloudkit.share","parent":{"recordName":"b","action":"ARCHIVE"},` +
+		`"participants":[{"userIdentity":{"userRecordName":"_bob"},"type":"GUEST","permission":"READ_COMMENT","acceptanceStatus":"INVITED"}],` +
+		`"publicPermission":"READ_COMMENT"}
+`
+
+	record, err := ndjson.NewDecoder(strings.NewReader(input)).Decode()
+	require.NoError(t, err)
+	assert.Equal(t, icloud.PermissionUnknown, record.PublicPermission)
+	assert.Equal(t, "READ_COMMENT", record.RawPublicPermission())
+	assert.Equal(t, "ARCHIVE", record.Parent.RawAction())
+	require.Len(t, record.Participants, 1)
+	assert.Equal(t, "GUEST", record.Participants[0].RawType())
+	assert.Equal(t, "READ_COMMENT", record.Participants[0].RawPermission())
+	assert.Equal(t, "INVITED", record.Participants[0].RawAcceptanceStatus())
+
+	var buf bytes.Buffer
+	enc := ndjson.NewEncoder(&buf)
+	require.NoError(t, enc.Encode(record))
+	require.NoError(t, enc.Flush())
+
+	assert.Equal(t, input, buf.String())
+}
+
+func TestDecoder_Error(t *testing.T) {
+	dec := ndjson.NewDecoder(strings.NewReader(`{"recordName":"a"}` + "\n" + `{"recordName":`))
+
+	_, err := dec.Decode()
+	require.NoError(t, err)
+
+	_, err = dec.Decode()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "record 2")
+}
+
+func TestExportImport(t *testing.T) {
+	srv := icloudtest.NewServer()
+	defer srv.Close()
+
+	dev, err := srv.NewClient(container, icloud.Development)
+	require.NoError(t, err)
+	prod, err := srv.NewClient(container, icloud.Production)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	zoneID := &icloud.ZoneID{Name: "Posts"}
+
+	for _, client := range []*icloud.Client{dev, prod} {
+		res, err := client.Zones.Modify(ctx, icloud.Private, icloud.ZonesRequest{
+			Operations: []icloud.ZoneOperation{
+				{Type: icloud.Create, Zone: icloud.Zone{ZoneID: *zoneID}},
+			},
+		})
+		require.NoError(t, err)
+		require.NoError(t, res.Zones[0].Err())
+	}
+
+	_, err = dev.Records.Modify(ctx, icloud.Private, icloud.RecordsRequest{
+		ZoneID: zoneID,
+		Operations: []icloud.RecordOperation{
+			create("a", "Hello", 3),
+			create("b", "World", 5),
+			create("c", "Again", 1),
+		},
+	})
+	require.NoError(t, err)
+
+	// Export all records of the zone.
+	var backup bytes.Buffer
+	exportRes, err := ndjson.Export(ctx, dev, &backup, ndjson.ExportOptions{
+		Database: icloud.Private,
+		ZoneID:   zoneID,
+		PageSize: 2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, exportRes.Records)
+	assert.NotEmpty(t, exportRes.SyncToken)
+	assert.Equal(t, 3, strings.Count(backup.String(), "\n"))
+
+	importRes, err := ndjson.Import(ctx, prod, &backup, ndjson.ImportOptions{
+		Database:  icloud.Private,
+		BatchSize: 2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, importRes.Records)
+	assert.Equal(t, 3, importRes.Imported)
+	assert.Empty(t, importRes.Failed)
+
+	lookup, err := prod.Records.Lookup(ctx, icloud.Private, icloud.LookupRequest{
+		ZoneID:  zoneID,
+		Records: []icloud.Record{{Name: "b"}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, lookup.Records[0].Err())
+	assert.Equal(t, "Post", lookup.Records[0].Type)
+	assert.ElementsMatch(t, icloud.Fields{
+		{Name: "title", Type: "STRING", Value: "World"},
+		{Name: "likes", Type: "INT64", Value: int64(5)},
+	}, lookup.Records[0].Fields)
+
+	// Export and import the changes since the last export, including a
+	// deletion.
+	_, err = dev.Records.Modify(ctx, icloud.Private, icloud.RecordsRequest{
+		ZoneID: zoneID,
+		Operations: []icloud.RecordOperation{
+			{Type: icloud.ForceDelete, Record: icloud.Record{Name: "a"}},
+		},
+	})
+	require.NoError(t, err)
+
+	var changes bytes.Buffer
+	exportRes, err = ndjson.Export(ctx, dev, &changes, ndjson.ExportOptions{
+		Database:  icloud.Private,
+		ZoneID:    zoneID,
+		SyncToken: exportRes.SyncToken,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, exportRes.Records)
+
+	importRes, err = ndjson.Import(ctx, prod, &changes, ndjson.ImportOptions{Database: icloud.Private})
+	require.NoError(t, err)
+	assert.Equal(t, 1, importRes.Imported)
+
+	lookup, err = prod.Records.Lookup(ctx, icloud.Private, icloud.LookupRequest{
+		ZoneID:  zoneID,
+		Records: []icloud.Record{{Name: "a"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, icloud.NotFound, lookup.Records[0].ServerErrorCode)
+}
+
+func TestExportImport_Query(t *testing.T) {
+	srv := icloudtest.NewServer()
+	defer srv.Close()
+
+	client, err := srv.NewClient(container, icloud.Development)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	_, err = client.Records.Modify(ctx, icloud.Public, icloud.RecordsRequest{
+		Operations: []icloud.RecordOperation{
+			create("a", "Hello", 3),
+			create("b", "World", 5),
+		},
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	exportRes, err := ndjson.Export(ctx, client, &buf, ndjson.ExportOptions{
+		Query: &icloud.Query{
+			RecordType: "Post",
+			FilterBy: []icloud.Filter{
+				{Comparator: icloud.GreaterThan, FieldName: "likes", FieldValue: icloud.FieldValue{Value: 4}},
+			},
+		},
+		DesiredKeys: []string{"title"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, exportRes.Records)
+	assert.Empty(t, exportRes.SyncToken)
+
+	var record map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "b", record["recordName"])
+	assert.NotContains(t, record["fields"], "likes")
+
+	// Creating existing records fails for every record.
+	importRes, err := ndjson.Import(ctx, client, &buf, ndjson.ImportOptions{OperationType: icloud.Create})
+	require.NoError(t, err)
+	assert.Zero(t, importRes.Imported)
+	require.Len(t, importRes.Failed, 1)
+	assert.Equal(t, icloud.Exists, importRes.Failed[0].ServerErrorCode)
+}
+
+func TestImport_ServerSetAttributes(t *testing.T) {
+	srv := icloudtest.NewServer()
+	defer srv.Close()
+
+	var body []byte
+	client, err := srv.NewClient(container, icloud.Development, icloud.AddHooks(icloud.Hooks{
+		BeforeRequest: func(ctx context.Context, req *icloud.RequestInfo) context.Context {
+			body = req.Body
+			return ctx
+		},
+		IncludeBodies: true,
+	}))
+	require.NoError(t, err)
+
+	const input = `{"recordName":"a","recordType":"Post","recordChangeTag":"1","zoneID":{"zoneName":"_defaultZone"},` +
+		`"fields":{"title":{"type":"STRING","value":"Hello"}},"shortGUID":"0abcDEF",` +
+		`"participants":[{"userIdentity":{"userRecordName":"_bob"},"type":"USER","permission":"READ_ONLY","acceptanceStatus":"ACCEPTED"},` +
+		`{"userIdentity":{"userRecordName":"_carol"},"type":"GUEST","permission":"READ_COMMENT","acceptanceStatus":"INVITED"}],` +
+		`"owner":{"userIdentity":{"userRecordName":"_alice"},"type":"OWNER"},` +
+		`"currentUserParticipant":{"userIdentity":{"userRecordName":"_alice"},"type":"OWNER"}}
+`
+
+	res, err := ndjson.Import(context.Background(), client, strings.NewReader(input), ndjson.ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Imported)
+
+	var req struct {
+		Operations []struct {
+			Record map[string]json.RawMessage `json:"record"`
+		} `json:"operations"`
+	}
+	require.NoError(t, json.Unmarshal(body, &req))
+	require.Len(t, req.Operations, 1)
+
+	record := req.Operations[0].Record
+	for _, key := range []string{"recordChangeTag", "zoneID", "shortGUID", "owner", "currentUserParticipant"} {
+		assert.NotContains(t, record, key)
+	}
+	assert.JSONEq(t, `[
+		{"userIdentity":{"userRecordName":"_bob"},"permission":"READ_ONLY"},
+		{"userIdentity":{"userRecordName":"_carol"},"permission":"READ_COMMENT"}
+	]`, string(record["participants"]))
+}
+
+func TestExport_ChangesWithoutZone(t *testing.T) {
+	_, err := ndjson.Export(context.Background(), nil, io.Discard, ndjson.ExportOptions{})
+	assert.EqualError(t, err, "exporting changes requires a zone")
+}
+
+func create(name, title string, likes int64) icloud.RecordOperation {
+	return icloud.RecordOperation{
+		Type: icloud.Create,
+		Record: icloud.Record{
+			Name: name,
+			Type: "Post",
+			Fields: icloud.Fields{
+				{Name: "title", Value: title},
+				{Name: "likes", Value: likes},
+			},
+		},
+	}
+}
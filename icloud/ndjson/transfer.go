@@ -0,0 +1,330 @@
+package ndjson
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// ExportOptions configure an export.
+type ExportOptions struct {
+	// Database to export from. Defaults to the public database.
+	Database icloud.Database
+	// ZoneID of the zone to export from. If not set, the default zone is
+	// used. Required for exporting changes.
+	ZoneID *icloud.ZoneID
+	// Query selects the records to export. If not set, the changes of the zone
+	// since SyncToken are exported instead. The default zone doesn't support
+	// fetching changes.
+	Query *icloud.Query
+	// SyncToken returned by a previous export of changes. If not set, all
+	// records of the zone are exported.
+	SyncToken string
+	// DesiredKeys limits the fields exported for each record. If not set, all
+	// fields are exported.
+	DesiredKeys []string
+	// PageSize is the number of records fetched per request. If not set, the
+	// server decides.
+	PageSize int
+}
+
+// ExportResult is the outcome of an export.
+type ExportResult struct {
+	// Records is the number of records written, including deleted ones.
+	Records int
+	// SyncToken to pass to the next export of changes. Only set when exporting
+	// changes.
+	SyncToken string
+}
+
+// Export writes the records selected by the options as newline delimited JSON
+// to w. The records are written page by page. When exporting changes, deleted
+// records are included and marked as such.
+func Export(ctx context.Context, client *icloud.Client, w io.Writer, opts ExportOptions) (*ExportResult, error) {
+	if opts.Database == 0 {
+		opts.Database = icloud.Public
+	}
+
+	var (
+		enc = NewEncoder(w)
+		res = new(ExportResult)
+		err error
+	)
+	if opts.Query != nil {
+		err = exportQuery(ctx, client, enc, res, opts)
+	} else {
+		err = exportChanges(ctx, client, enc, res, opts)
+	}
+
+	if flushErr := enc.Flush(); err == nil {
+		err = flushErr
+	}
+	return res, err
+}
+
+// exportQuery exports the records matching the query of the options.
+func exportQuery(ctx context.Context, client *icloud.Client, enc *Encoder, res *ExportResult, opts ExportOptions) error {
+	req := icloud.QueryRequest{
+		ZoneID:       opts.ZoneID,
+		ResultsLimit: opts.PageSize,
+		Query:        *opts.Query,
+		DesiredKeys:  opts.DesiredKeys,
+	}
+
+	for {
+		page, err := client.Records.Query(ctx, opts.Database, req)
+		if err != nil {
+			return err
+		}
+
+		if err = encodeAll(enc, res, page.Records); err != nil {
+			return err
+		}
+
+		if page.ContinuationMarker == "" {
+			return nil
+		}
+		req.ContinuationMarker = page.ContinuationMarker
+	}
+}
+
+// exportChanges exports the changes of the zone of the options.
+func exportChanges(ctx context.Context, client *icloud.Client, enc *Encoder, res *ExportResult, opts ExportOptions) error {
+	if opts.ZoneID == nil {
+		return errors.New("exporting changes requires a zone")
+	}
+
+	req := icloud.ChangesRequest{
+		ZoneID:       *opts.ZoneID,
+		SyncToken:    opts.SyncToken,
+		ResultsLimit: opts.PageSize,
+		DesiredKeys:  opts.DesiredKeys,
+	}
+
+	for {
+		page, err := client.Records.Changes(ctx, opts.Database, req)
+		if err != nil {
+			return err
+		}
+
+		if err = encodeAll(enc, res, page.Records); err != nil {
+			return err
+		}
+		res.SyncToken = page.SyncToken
+
+		if !page.MoreComing {
+			return nil
+		}
+		req.SyncToken = page.SyncToken
+	}
+}
+
+// encodeAll encodes the records and counts them.
+func encodeAll(enc *Encoder, res *ExportResult, records []icloud.Record) error {
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+		res.Records++
+	}
+	return nil
+}
+
+// ImportOptions configure an import.
+type ImportOptions struct {
+	// Database to import into. Defaults to the public database.
+	Database icloud.Database
+	// ZoneID of the zone to import into. If not set, every record is imported
+	// into the zone it was exported from.
+	ZoneID *icloud.ZoneID
+	// OperationType used to write the records. Defaults to icloud.ForceReplace,
+	// which makes the imported records exact copies of the exported ones.
+	// Records marked as deleted are always deleted using icloud.ForceDelete.
+	OperationType icloud.OperationType
+	// BatchSize is the number of records written per request. Defaults to and
+	// is limited by icloud.MaxOperationPerRequest.
+	BatchSize int
+}
+
+// ImportResult is the outcome of an import.
+type ImportResult struct {
+	// Records is the number of records read.
+	Records int
+	// Imported is the number of records written successfully.
+	Imported int
+	// Failed are the records the server rejected. They carry the error.
+	Failed []icloud.Record
+}
+
+// Import reads records as newline delimited JSON from r and writes them in
+// batches. Records rejected by the server are reported in the result. An error
+// is returned if the data can't be read or a request fails as a whole, along
+// with the result up to that point.
+//
+// Change tags are only sent for operations which require them, as the change
+// tags of exported records are meaningless to other containers or
+// environments.
+func Import(ctx context.Context, client *icloud.Client, r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	if opts.Database == 0 {
+		opts.Database = icloud.Public
+	}
+	if opts.OperationType == 0 {
+		opts.OperationType = icloud.ForceReplace
+	}
+	if opts.BatchSize <= 0 || opts.BatchSize > icloud.MaxOperationPerRequest {
+		opts.BatchSize = icloud.MaxOperationPerRequest
+	}
+
+	var (
+		dec   = NewDecoder(r)
+		res   = new(ImportResult)
+		batch = icloud.RecordsRequest{ZoneID: opts.ZoneID}
+	)
+	for {
+		record, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return res, err
+		}
+		res.Records++
+
+		// A request can only address a single zone, so the batch is written
+		// when the zone changes.
+		zoneID := record.ZoneID
+		if opts.ZoneID != nil {
+			zoneID = opts.ZoneID
+		}
+		if len(batch.Operations) > 0 && !sameZone(batch.ZoneID, zoneID) {
+			if err = write(ctx, client, opts.Database, batch, res); err != nil {
+				return res, err
+			}
+			batch.Operations = batch.Operations[:0]
+		}
+		batch.ZoneID = zoneID
+
+		op, err := operation(record, opts.OperationType)
+		if err != nil {
+			return res, fmt.Errorf("record %d: %w", res.Records, err)
+		}
+		batch.Operations = append(batch.Operations, op)
+		if len(batch.Operations) == opts.BatchSize {
+			if err = write(ctx, client, opts.Database, batch, res); err != nil {
+				return res, err
+			}
+			batch.Operations = batch.Operations[:0]
+		}
+	}
+
+	if len(batch.Operations) > 0 {
+		if err := write(ctx, client, opts.Database, batch, res); err != nil {
+			return res, err
+		}
+	}
+
+	return res, nil
+}
+
+// operation returns the operation which writes the record.
+func operation(record icloud.Record, typ icloud.OperationType) (icloud.RecordOperation, error) {
+	if record.Deleted {
+		typ = icloud.ForceDelete
+		record.Fields = nil
+	}
+
+	switch typ {
+	case icloud.Update, icloud.Replace, icloud.Delete:
+	default:
+		record.ChangeTag = ""
+	}
+
+	// The zone is part of the request and attributes set by the server are
+	// not accepted.
+	record.ZoneID = nil
+	record.ShortGUID = ""
+	record.Owner = nil
+	record.CurrentUserParticipant = nil
+	record.Deleted = false
+	record.Reason = ""
+	record.ServerErrorCode = icloud.Unknown
+
+	// Participants are added by their identity and permission. A copy is
+	// made, so the participants of the record are left untouched.
+	if record.Participants != nil {
+		participants := make([]icloud.Participant, len(record.Participants))
+		for i, p := range record.Participants {
+			var err error
+			if participants[i], err = addedParticipant(p); err != nil {
+				return icloud.RecordOperation{}, err
+			}
+		}
+		record.Participants = participants
+	}
+
+	return icloud.RecordOperation{Type: typ, Record: record}, nil
+}
+
+// addedParticipant returns the participant as it is added to a share, with
+// only its identity and permission. It is copied through JSON, which retains
+// permissions unknown to the icloud package.
+func addedParticipant(p icloud.Participant) (icloud.Participant, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return icloud.Participant{}, err
+	}
+
+	var added struct {
+		UserIdentity json.RawMessage `json:"userIdentity"`
+		Permission   json.RawMessage `json:"permission,omitempty"`
+	}
+	if err = json.Unmarshal(b, &added); err != nil {
+		return icloud.Participant{}, err
+	}
+	if b, err = json.Marshal(added); err != nil {
+		return icloud.Participant{}, err
+	}
+
+	var res icloud.Participant
+	err = json.Unmarshal(b, &res)
+
+	return res, err
+}
+
+// write sends a batch of operations and records the outcome.
+func write(ctx context.Context, client *icloud.Client, database icloud.Database, req icloud.RecordsRequest, res *ImportResult) error {
+	modRes, err := client.Records.Modify(ctx, database, req)
+	if err != nil {
+		return fmt.Errorf("write batch of %d records: %w", len(req.Operations), err)
+	}
+
+	for _, record := range modRes.Records {
+		if record.Err() != nil {
+			res.Failed = append(res.Failed, record)
+		} else {
+			res.Imported++
+		}
+	}
+
+	return nil
+}
+
+// sameZone returns true, if both zone ids identify the same zone. Nil
+// identifies the default zone.
+func sameZone(a, b *icloud.ZoneID) bool {
+	if a == nil || b == nil {
+		return zoneName(a) == zoneName(b)
+	}
+	return *a == *b
+}
+
+// zoneName returns the name of the zone identified by the id.
+func zoneName(id *icloud.ZoneID) string {
+	if id == nil {
+		return icloud.DefaultZoneName
+	}
+	return id.Name
+}
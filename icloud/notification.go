@@ -0,0 +1,248 @@
+package icloud
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+)
+
+//go:generate ../bin/stringer -type=NotificationReason -linecomment -output=notification_string.go
+
+// NotificationReason is the reason a query subscription fired.
+type NotificationReason uint8
+
+// All available notification reasons.
+const (
+	// NotificationReasonUnknown is the reason of a notification that fired
+	// for a reason unknown to the package.
+	NotificationReasonUnknown NotificationReason = iota // UNKNOWN
+	// RecordCreated is the reason of a notification about a record that was
+	// created.
+	RecordCreated // RECORD_CREATED
+	// RecordUpdated is the reason of a notification about a record that was
+	// updated.
+	RecordUpdated // RECORD_UPDATED
+	// RecordDeleted is the reason of a notification about a record that was
+	// deleted.
+	RecordDeleted // RECORD_DELETED
+)
+
+// parseNotificationReason returns the NotificationReason with the given
+// numeric representation. Reasons unknown to the package are returned as
+// NotificationReasonUnknown, along with their string representation. For known
+// reasons, the returned string is empty.
+func parseNotificationReason(n int) (NotificationReason, string) {
+	switch reason := NotificationReason(n); reason {
+	case RecordCreated, RecordUpdated, RecordDeleted:
+		if int(reason) == n {
+			return reason, ""
+		}
+	}
+	return NotificationReasonUnknown, strconv.Itoa(n)
+}
+
+// parseDatabaseScope returns the Database with the given numeric
+// representation, as used by notifications. Scopes unknown to the package are
+// returned as zero, along with their string representation. For known scopes,
+// the returned string is empty.
+func parseDatabaseScope(n int) (Database, string) {
+	switch db := Database(n); db {
+	case Public, Private, Shared:
+		if int(db) == n {
+			return db, ""
+		}
+	}
+	return 0, strconv.Itoa(n)
+}
+
+// Notification is a push notification sent by CloudKit when a subscription
+// fires. It is a *QueryNotification, *ZoneNotification or
+// *DatabaseNotification:
+//
+//	n, err := icloud.ParseNotification(payload)
+//	if err != nil {
+//		return err
+//	}
+//
+//	switch n := n.(type) {
+//	case *icloud.QueryNotification:
+//		switch n.Reason {
+//		case icloud.RecordCreated, icloud.RecordUpdated:
+//			// Fetch n.Record.Name from n.Record.ZoneID.
+//		case icloud.RecordDeleted:
+//			// Forget about n.Record.Name.
+//		}
+//	case *icloud.ZoneNotification:
+//		// Fetch the changes of n.ZoneID.
+//	case *icloud.DatabaseNotification:
+//		// Fetch the changed zones of n.Database.
+//	}
+type Notification interface {
+	// Header returns the information shared by all notifications.
+	Header() NotificationHeader
+}
+
+// NotificationHeader is the information shared by all notifications.
+type NotificationHeader struct {
+	// ContainerID is the identifier of the container the notification
+	// belongs to.
+	ContainerID string
+	// NotificationID uniquely identifies the notification.
+	NotificationID string
+	// SubscriptionID is the identifier of the subscription that fired.
+	SubscriptionID string
+	// Database the subscription belongs to. Zero, if the database scope of
+	// the notification is unknown to the package.
+	Database Database
+
+	rawDatabase string
+}
+
+// Header implements Notification.
+func (h NotificationHeader) Header() NotificationHeader {
+	return h
+}
+
+// RawDatabase returns the database the subscription belongs to as sent by
+// CloudKit. Unlike Database, it also holds the numeric scope of databases
+// unknown to the package.
+func (h NotificationHeader) RawDatabase() string {
+	return rawString(h.rawDatabase, h.Database)
+}
+
+// QueryNotification is sent when a record matching the query of a query
+// subscription is created, updated or deleted.
+type QueryNotification struct {
+	NotificationHeader
+
+	// Reason the subscription fired.
+	Reason NotificationReason
+	// Record that matched the query. Only its name and zone are set, as well
+	// as the fields the subscription asked to be included in the
+	// notification. The field values are untyped.
+	Record Record
+
+	rawReason string
+}
+
+// RawReason returns the reason the subscription fired as sent by CloudKit.
+// Unlike Reason, it also holds the numeric value of reasons unknown to the
+// package.
+func (n QueryNotification) RawReason() string {
+	return rawString(n.rawReason, n.Reason)
+}
+
+// ZoneNotification is sent when a record in the zone of a zone subscription
+// changes.
+type ZoneNotification struct {
+	NotificationHeader
+
+	// ZoneID of the zone that changed.
+	ZoneID ZoneID
+}
+
+// DatabaseNotification is sent when a zone of the database of a database
+// subscription changes.
+type DatabaseNotification struct {
+	NotificationHeader
+}
+
+// notificationPayload is the payload of a push notification sent by CloudKit.
+// The CloudKit specific information is in the "ck" dictionary, which uses
+// abbreviated keys.
+type notificationPayload struct {
+	CK *struct {
+		ContainerID    string `json:"cid"`
+		NotificationID string `json:"nid"`
+
+		Query *struct {
+			notificationScope
+			Reason     int                    `json:"fo"`
+			RecordName string                 `json:"rid"`
+			ZoneName   string                 `json:"zid"`
+			ZoneOwner  string                 `json:"zoid"`
+			Fields     map[string]interface{} `json:"af"`
+		} `json:"qry"`
+
+		Zone *struct {
+			notificationScope
+			ZoneName  string `json:"zid"`
+			ZoneOwner string `json:"zoid"`
+		} `json:"fet"`
+
+		Database *struct {
+			notificationScope
+		} `json:"met"`
+	} `json:"ck"`
+}
+
+// notificationScope holds the keys shared by all kinds of notifications.
+type notificationScope struct {
+	Database       int    `json:"dbs"`
+	SubscriptionID string `json:"sid"`
+}
+
+// ParseNotification parses the payload of a push notification sent by
+// CloudKit, as delivered by APNs or the web courier. The payload must contain
+// the "ck" dictionary.
+func ParseNotification(payload []byte) (Notification, error) {
+	var p notificationPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, err
+	} else if p.CK == nil {
+		return nil, errors.New("not a CloudKit notification: missing ck dictionary")
+	}
+
+	header := NotificationHeader{
+		ContainerID:    p.CK.ContainerID,
+		NotificationID: p.CK.NotificationID,
+	}
+
+	var scope notificationScope
+	switch {
+	case p.CK.Query != nil:
+		scope = p.CK.Query.notificationScope
+	case p.CK.Zone != nil:
+		scope = p.CK.Zone.notificationScope
+	case p.CK.Database != nil:
+		scope = p.CK.Database.notificationScope
+	default:
+		return nil, errors.New("unknown kind of notification")
+	}
+
+	header.SubscriptionID = scope.SubscriptionID
+	header.Database, header.rawDatabase = parseDatabaseScope(scope.Database)
+
+	switch {
+	case p.CK.Query != nil:
+		q := p.CK.Query
+
+		n := &QueryNotification{
+			NotificationHeader: header,
+			Record: Record{
+				Name:   q.RecordName,
+				ZoneID: &ZoneID{Name: q.ZoneName, OwnerName: q.ZoneOwner},
+			},
+		}
+		n.Reason, n.rawReason = parseNotificationReason(q.Reason)
+
+		names := make([]string, 0, len(q.Fields))
+		for name := range q.Fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			n.Record.Fields = append(n.Record.Fields, Field{Name: name, Value: q.Fields[name]})
+		}
+
+		return n, nil
+	case p.CK.Zone != nil:
+		return &ZoneNotification{
+			NotificationHeader: header,
+			ZoneID:             ZoneID{Name: p.CK.Zone.ZoneName, OwnerName: p.CK.Zone.ZoneOwner},
+		}, nil
+	default:
+		return &DatabaseNotification{NotificationHeader: header}, nil
+	}
+}
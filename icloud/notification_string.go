@@ -0,0 +1,26 @@
+// Code generated by "stringer -type=NotificationReason -linecomment -output=notification_string.go"; DO NOT EDIT.
+
+package icloud
+
+import "strconv"
+
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[NotificationReasonUnknown-0]
+	_ = x[RecordCreated-1]
+	_ = x[RecordUpdated-2]
+	_ = x[RecordDeleted-3]
+}
+
+const _NotificationReason_name = "UNKNOWNRECORD_CREATEDRECORD_UPDATEDRECORD_DELETED"
+
+var _NotificationReason_index = [...]uint8{0, 7, 21, 35, 49}
+
+func (i NotificationReason) String() string {
+	if i >= NotificationReason(len(_NotificationReason_index)-1) {
+		return "NotificationReason(" + strconv.FormatInt(int64(i), 10) + ")"
+	}
+	return _NotificationReason_name[_NotificationReason_index[i]:_NotificationReason_index[i+1]]
+}
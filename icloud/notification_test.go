@@ -0,0 +1,155 @@
+package icloud
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNotification(t *testing.T) {
+	header := NotificationHeader{
+		ContainerID:    "iCloud.com.lukasmalkmus.Example-App",
+		NotificationID: "3F2C5C8E-8C1B-4E2B-9A7D-1B2C3D4E5F60",
+		SubscriptionID: "posts",
+		Database:       Public,
+	}
+
+	tests := []struct {
+		name    string
+		payload string
+		exp     Notification
+	}{
+		{
+			name: "query",
+			payload: `{
+				"aps": {"content-available": 1},
+				"ck": {
+					"ce": 2,
+					"cid": "iCloud.com.lukasmalkmus.Example-App",
+					"nid": "3F2C5C8E-8C1B-4E2B-9A7D-1B2C3D4E5F60",
+					"qry": {
+						"dbs": 1,
+						"fo": 2,
+						"rid": "hello",
+						"zid": "_defaultZone",
+						"zoid": "_defaultOwner",
+						"sid": "posts",
+						"af": {"title": "Hello", "likes": 42}
+					}
+				}
+			}`,
+			exp: &QueryNotification{
+				NotificationHeader: header,
+				Reason:             RecordUpdated,
+				Record: Record{
+					Name:   "hello",
+					ZoneID: &ZoneID{Name: DefaultZoneName, OwnerName: "_defaultOwner"},
+					Fields: Fields{
+						{Name: "likes", Value: float64(42)},
+						{Name: "title", Value: "Hello"},
+					},
+				},
+			},
+		},
+		{
+			name: "query without fields",
+			payload: `{"ck": {"cid": "iCloud.com.lukasmalkmus.Example-App", "nid": "3F2C5C8E-8C1B-4E2B-9A7D-1B2C3D4E5F60",
+				"qry": {"dbs": 1, "fo": 3, "rid": "hello", "zid": "_defaultZone", "sid": "posts"}}}`,
+			exp: &QueryNotification{
+				NotificationHeader: header,
+				Reason:             RecordDeleted,
+				Record: Record{
+					Name:   "hello",
+					ZoneID: &ZoneID{Name: DefaultZoneName},
+				},
+			},
+		},
+		{
+			name: "zone",
+			payload: `{"ck": {"cid": "iCloud.com.lukasmalkmus.Example-App", "nid": "3F2C5C8E-8C1B-4E2B-9A7D-1B2C3D4E5F60",
+				"fet": {"dbs": 2, "zid": "Posts", "zoid": "_abc", "sid": "posts"}}}`,
+			exp: &ZoneNotification{
+				NotificationHeader: NotificationHeader{
+					ContainerID:    header.ContainerID,
+					NotificationID: header.NotificationID,
+					SubscriptionID: header.SubscriptionID,
+					Database:       Private,
+				},
+				ZoneID: ZoneID{Name: "Posts", OwnerName: "_abc"},
+			},
+		},
+		{
+			name: "database",
+			payload: `{"ck": {"cid": "iCloud.com.lukasmalkmus.Example-App", "nid": "3F2C5C8E-8C1B-4E2B-9A7D-1B2C3D4E5F60",
+				"met": {"dbs": 3, "sid": "posts"}}}`,
+			exp: &DatabaseNotification{
+				NotificationHeader: NotificationHeader{
+					ContainerID:    header.ContainerID,
+					NotificationID: header.NotificationID,
+					SubscriptionID: header.SubscriptionID,
+					Database:       Shared,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := ParseNotification([]byte(tt.payload))
+			require.NoError(t, err)
+			assert.Equal(t, tt.exp, n)
+			assert.Equal(t, tt.exp.Header(), n.Header())
+		})
+	}
+}
+
+func TestParseNotification_Unknown(t *testing.T) {
+	n, err := ParseNotification([]byte(`{"ck": {"cid": "iCloud.com.lukasmalkmus.Example-App",
+		"qry": {"dbs": 4, "fo": 257, "rid": "hello", "zid": "_defaultZone", "sid": "posts"}}}`))
+	require.NoError(t, err)
+	require.IsType(t, new(QueryNotification), n)
+
+	q := n.(*QueryNotification)
+	assert.Equal(t, NotificationReasonUnknown, q.Reason)
+	assert.Equal(t, "257", q.RawReason())
+	assert.Zero(t, q.Database)
+	assert.Equal(t, "4", q.RawDatabase())
+	assert.Equal(t, "hello", q.Record.Name)
+
+	n, err = ParseNotification([]byte(`{"ck": {"qry": {"dbs": 1, "fo": 1}}}`))
+	require.NoError(t, err)
+
+	q = n.(*QueryNotification)
+	assert.Equal(t, "RECORD_CREATED", q.RawReason())
+	assert.Equal(t, "public", q.RawDatabase())
+}
+
+func TestParseNotification_Error(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		err     string
+	}{
+		{
+			name:    "invalid json",
+			payload: `{`,
+			err:     "unexpected end of JSON input",
+		},
+		{
+			name:    "not cloudkit",
+			payload: `{"aps": {"alert": "Hello"}}`,
+			err:     "not a CloudKit notification: missing ck dictionary",
+		},
+		{
+			name:    "unknown kind",
+			payload: `{"ck": {"cid": "iCloud.com.lukasmalkmus.Example-App"}}`,
+			err:     "unknown kind of notification",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseNotification([]byte(tt.payload))
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package icloud
 
-import "net/http"
+import (
+	"fmt"
+	"net/http"
+)
 
 // An Option modifies the behaviour of the API client. If not otherwise
 // specified by a specific option, they are safe to use even after API methods
@@ -27,3 +30,51 @@ func SetUserAgent(userAgent string) Option {
 		return nil
 	}
 }
+
+// SetBaseURL sets the base url used by the client. It defaults to
+// https://api.apple-cloudkit.com. The container, environment and API version
+// of the client are preserved. The base url can have a path, e.g. when using a
+// proxy that forwards requests to the CloudKit Web Services API.
+func SetBaseURL(baseURL string) Option {
+	return func(c *Client) error {
+		return c.setBaseURL(baseURL, c.apiVersion)
+	}
+}
+
+// SetAPIVersion sets the version of the CloudKit Web Services API used by the
+// client. It defaults to 1. The base url, container and environment of the
+// client are preserved.
+func SetAPIVersion(version int) Option {
+	return func(c *Client) error {
+		if version < 1 {
+			return fmt.Errorf("invalid API version %d", version)
+		}
+		return c.setBaseURL(c.rootURL.String(), version)
+	}
+}
+
+// AddHooks adds hooks that are invoked for every request the client sends.
+// Hooks are invoked in the order they were added.
+func AddHooks(hooks Hooks) Option {
+	return func(c *Client) error {
+		c.hooks = append(c.hooks, hooks)
+		return nil
+	}
+}
+
+// SetLogger sets a logger that logs every request the client sends, along with
+// the outcome. Request and response bodies are only logged if includeBodies is
+// true. Headers, which carry the request signature, are never logged.
+func SetLogger(logger Logger, includeBodies bool) Option {
+	return AddHooks(logHooks(logger, includeBodies))
+}
+
+// SetSchema sets the schema records and queries are validated against before
+// they are sent. Requests that don't match the schema fail with a
+// *ValidationError without being sent. Passing nil disables validation.
+func SetSchema(schema Schema) Option {
+	return func(c *Client) error {
+		c.schema = schema
+		return nil
+	}
+}
@@ -1,10 +1,15 @@
 package icloud
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestOption_SetHTTPClient(t *testing.T) {
@@ -18,6 +23,43 @@ func TestOption_SetHTTPClient(t *testing.T) {
 	})
 }
 
+func TestOption_SetBaseURL(t *testing.T) {
+	exp := "http://localhost:8080/database/1/iCloud.com.lukasmalkmus.Example-App/development"
+	opt := SetBaseURL("http://localhost:8080/")
+
+	evaluateOption(t, opt, func(client *Client) {
+		assert.Equal(t, exp, client.baseURL.String())
+	})
+}
+
+func TestOption_SetBaseURL_Path(t *testing.T) {
+	exp := "http://localhost:8080/cloudkit/database/1/iCloud.com.lukasmalkmus.Example-App/development"
+	opt := SetBaseURL("http://localhost:8080/cloudkit")
+
+	evaluateOption(t, opt, func(client *Client) {
+		assert.Equal(t, exp, client.baseURL.String())
+		assert.Equal(t, "http://localhost:8080/cloudkit/device/1/iCloud.com.lukasmalkmus.Example-App/development", client.deviceURL.String())
+	})
+}
+
+func TestOption_SetAPIVersion(t *testing.T) {
+	exp := "https://api.apple-cloudkit.com/database/2/iCloud.com.lukasmalkmus.Example-App/development"
+	opt := SetAPIVersion(2)
+
+	evaluateOption(t, opt, func(client *Client) {
+		assert.Equal(t, exp, client.baseURL.String())
+		assert.Equal(t, "https://api.apple-cloudkit.com/device/2/iCloud.com.lukasmalkmus.Example-App/development", client.deviceURL.String())
+		assert.Equal(t, 2, client.apiVersion)
+	})
+}
+
+func TestOption_SetAPIVersion_Invalid(t *testing.T) {
+	client, _ := NewClient(container, keyID, nil, environment)
+
+	err := client.Options(SetAPIVersion(0))
+	assert.Error(t, err)
+}
+
 func TestOption_SetUserAgent(t *testing.T) {
 	exp := "icloud-go/1.0.0"
 	opt := SetUserAgent(exp)
@@ -27,6 +69,95 @@ func TestOption_SetUserAgent(t *testing.T) {
 	})
 }
 
+func TestOption_SetLogger(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprint(w, `{"serverErrorCode":"BAD_REQUEST","reason":"missing record type"}`)
+	}
+
+	client, teardown := setup(t, "/", hf)
+	defer teardown()
+
+	var (
+		logMsg     string
+		logKeyvals = make(map[string]interface{})
+	)
+	logger := LoggerFunc(func(_ context.Context, msg string, keyvals ...interface{}) {
+		logMsg = msg
+		for i := 0; i < len(keyvals); i += 2 {
+			logKeyvals[keyvals[i].(string)] = keyvals[i+1]
+		}
+	})
+
+	err := client.Options(SetLogger(logger, true))
+	require.NoError(t, err)
+
+	_, err = client.Records.Modify(context.Background(), Private, RecordsRequest{
+		Operations: []RecordOperation{
+			{Type: Create, Record: Record{Name: "a"}},
+		},
+	})
+	require.Error(t, err)
+
+	assert.Equal(t, "request failed", logMsg)
+	assert.Equal(t, "private", logKeyvals["database"])
+	assert.Equal(t, 1, logKeyvals["operations"])
+	assert.Equal(t, http.StatusBadRequest, logKeyvals["status"])
+	assert.Equal(t, []string{"BAD_REQUEST"}, logKeyvals["error_codes"])
+	assert.Equal(t, err.Error(), logKeyvals["error"])
+	assert.Contains(t, logKeyvals["request_body"], `"recordName":"a"`)
+	assert.Contains(t, logKeyvals["response_body"], "missing record type")
+
+	for _, v := range logKeyvals {
+		assert.False(t, strings.Contains(fmt.Sprint(v), keyID), "key id must not be logged")
+	}
+}
+
+type testSchema struct{}
+
+func (testSchema) ValidateRecord(record Record) error {
+	if record.Type != "Post" {
+		return errors.New("unknown record type")
+	}
+	return nil
+}
+
+func (testSchema) ValidateQuery(query Query) error {
+	return &ValidationError{RecordType: query.RecordType, Field: "title", Reason: "field is not queryable"}
+}
+
+func TestOption_SetSchema(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		t.Error("invalid request has been sent")
+	}
+
+	client, teardown := setup(t, "/", hf)
+	defer teardown()
+
+	err := client.Options(SetSchema(testSchema{}))
+	require.NoError(t, err)
+
+	_, err = client.Records.Modify(context.Background(), Public, RecordsRequest{
+		Operations: []RecordOperation{
+			{Type: Create, Record: Record{Type: "Post"}},
+			{Type: ForceDelete, Record: Record{Name: "a"}},
+			{Type: Create, Record: Record{Type: "Comment"}},
+		},
+	})
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, 2, validationErr.Operation)
+	assert.EqualError(t, err, `operation 2: record type "Comment": unknown record type`)
+
+	_, err = client.Records.Query(context.Background(), Public, QueryRequest{
+		Query: Query{RecordType: "Post"},
+	})
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, -1, validationErr.Operation)
+	assert.EqualError(t, err, `field "title" of record type "Post": field is not queryable`)
+}
+
 func evaluateOption(t *testing.T, opt Option, f func(client *Client)) {
 	client, _ := NewClient(container, keyID, nil, environment)
 
@@ -0,0 +1,225 @@
+package icloud
+
+import "encoding/json"
+
+//go:generate ../bin/stringer -type=Comparator -linecomment -output=query_string.go
+
+// Comparator is used to compare the value of a field in a query filter.
+type Comparator uint8
+
+// All available comparators.
+const (
+	// ComparatorUnknown is a comparator unknown to the package.
+	ComparatorUnknown Comparator = iota // UNKNOWN
+
+	Equals                  // EQUALS
+	NotEquals               // NOT_EQUALS
+	LessThan                // LESS_THAN
+	LessThanOrEquals        // LESS_THAN_OR_EQUALS
+	GreaterThan             // GREATER_THAN
+	GreaterThanOrEquals     // GREATER_THAN_OR_EQUALS
+	Near                    // NEAR
+	ContainsAllTokens       // CONTAINS_ALL_TOKENS
+	In                      // IN
+	NotIn                   // NOT_IN
+	ContainsAnyTokens       // CONTAINS_ANY_TOKENS
+	ListContains            // LIST_CONTAINS
+	NotListContains         // NOT_LIST_CONTAINS
+	NotListContainsAny      // NOT_LIST_CONTAINS_ANY
+	BeginsWith              // BEGINS_WITH
+	NotBeginsWith           // NOT_BEGINS_WITH
+	ListMemberBeginsWith    // LIST_MEMBER_BEGINS_WITH
+	NotListMemberBeginsWith // NOT_LIST_MEMBER_BEGINS_WITH
+	ListContainsAll         // LIST_CONTAINS_ALL
+	NotListContainsAll      // NOT_LIST_CONTAINS_ALL
+)
+
+// MarshalJSON implements json.Marshaler. It is in place to marshal the
+// Comparator to its string representation because that's what the server
+// expects.
+func (c Comparator) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It is in place to unmarshal the
+// Comparator from the string representation the server returns. Comparators
+// unknown to the package are unmarshalled as ComparatorUnknown, so responses
+// stay decodable when the server introduces new comparators.
+func (c *Comparator) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	*c, _ = parseComparator(s)
+
+	return nil
+}
+
+// parseComparator returns the Comparator with the given string representation.
+// Comparators unknown to the package are returned as ComparatorUnknown, along
+// with their string representation. For known comparators, the returned string
+// is empty.
+func parseComparator(s string) (Comparator, string) {
+	switch s {
+	case Equals.String():
+		return Equals, ""
+	case NotEquals.String():
+		return NotEquals, ""
+	case LessThan.String():
+		return LessThan, ""
+	case LessThanOrEquals.String():
+		return LessThanOrEquals, ""
+	case GreaterThan.String():
+		return GreaterThan, ""
+	case GreaterThanOrEquals.String():
+		return GreaterThanOrEquals, ""
+	case Near.String():
+		return Near, ""
+	case ContainsAllTokens.String():
+		return ContainsAllTokens, ""
+	case In.String():
+		return In, ""
+	case NotIn.String():
+		return NotIn, ""
+	case ContainsAnyTokens.String():
+		return ContainsAnyTokens, ""
+	case ListContains.String():
+		return ListContains, ""
+	case NotListContains.String():
+		return NotListContains, ""
+	case NotListContainsAny.String():
+		return NotListContainsAny, ""
+	case BeginsWith.String():
+		return BeginsWith, ""
+	case NotBeginsWith.String():
+		return NotBeginsWith, ""
+	case ListMemberBeginsWith.String():
+		return ListMemberBeginsWith, ""
+	case NotListMemberBeginsWith.String():
+		return NotListMemberBeginsWith, ""
+	case ListContainsAll.String():
+		return ListContainsAll, ""
+	case NotListContainsAll.String():
+		return NotListContainsAll, ""
+	}
+	return ComparatorUnknown, s
+}
+
+// QueryRequest is the request to the query operation of the RecordsService.
+type QueryRequest struct {
+	// ZoneID of the zone to query. If not set, the default zone is used.
+	ZoneID *ZoneID `json:"zoneID,omitempty"`
+	// ResultsLimit is the maximum number of records to return.
+	ResultsLimit int `json:"resultsLimit,omitempty"`
+	// Query to apply.
+	Query Query `json:"query"`
+	// ContinuationMarker returned by a previous request. Used to fetch the
+	// next batch of records.
+	ContinuationMarker string `json:"continuationMarker,omitempty"`
+	// DesiredKeys limits the fields returned for each record. If not set, all
+	// fields are returned.
+	DesiredKeys []string `json:"desiredKeys,omitempty"`
+	// NumbersAsStrings makes the server represent numbers in the returned
+	// fields as strings. Decoding them yields the same values, without
+	// relying on the server's JSON numbers being exact.
+	NumbersAsStrings bool `json:"numbersAsStrings,omitempty"`
+}
+
+// Query selects records of a specific type.
+type Query struct {
+	// RecordType of the records to query.
+	RecordType string `json:"recordType"`
+	// FilterBy restricts the returned records to the ones matching all
+	// filters.
+	FilterBy []Filter `json:"filterBy,omitempty"`
+	// SortBy specifies the order of the returned records.
+	SortBy []Sort `json:"sortBy,omitempty"`
+}
+
+// Filter restricts the records returned by a query.
+type Filter struct {
+	// Comparator used to compare the field value. Comparators unknown to the
+	// package are ComparatorUnknown, use RawComparator to get them.
+	Comparator Comparator `json:"comparator"`
+	// FieldName of the field to compare.
+	FieldName string `json:"fieldName"`
+	// FieldValue to compare the field to.
+	FieldValue FieldValue `json:"fieldValue"`
+
+	rawComparator string
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It is in place to retain
+// comparators unknown to the package.
+func (f *Filter) UnmarshalJSON(b []byte) error {
+	type LocalFilter Filter
+	localFilter := struct {
+		*LocalFilter
+
+		Comparator string `json:"comparator"`
+	}{
+		LocalFilter: (*LocalFilter)(f),
+	}
+
+	if err := json.Unmarshal(b, &localFilter); err != nil {
+		return err
+	}
+
+	f.Comparator, f.rawComparator = parseComparator(localFilter.Comparator)
+
+	return nil
+}
+
+// RawComparator returns the comparator as it was unmarshalled. It is the
+// string representation of Comparator, unless the comparator is unknown to the
+// package.
+func (f Filter) RawComparator() string {
+	return rawString(f.rawComparator, f.Comparator)
+}
+
+// FieldValue is the value of a field used in a query filter.
+type FieldValue struct {
+	// Type of the value.
+	Type string `json:"type,omitempty"`
+	// Value to compare the field to.
+	Value interface{} `json:"value"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It is in place to unmarshal
+// values of type INT64 as int64 instead of float64, just like field values.
+func (v *FieldValue) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Type  string          `json:"type"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	value, err := unmarshalValue(raw.Type, raw.Value)
+	if err != nil {
+		return err
+	}
+	v.Type, v.Value = raw.Type, value
+
+	return nil
+}
+
+// Sort specifies the order of records returned by a query.
+type Sort struct {
+	// FieldName of the field to sort by.
+	FieldName string `json:"fieldName"`
+	// Ascending sorts the records in ascending order, if true.
+	Ascending bool `json:"ascending"`
+}
+
+// QueryResponse is the response recevied from the query operation of the
+// RecordsService.
+type QueryResponse struct {
+	// Records matching the query.
+	Records []Record `json:"records,omitempty"`
+	// ContinuationMarker to pass to the next request to fetch more records.
+	// Empty, if there are no more records.
+	ContinuationMarker string `json:"continuationMarker,omitempty"`
+}
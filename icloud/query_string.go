@@ -0,0 +1,43 @@
+// Code generated by "stringer -type=Comparator -linecomment -output=query_string.go"; DO NOT EDIT.
+
+package icloud
+
+import "strconv"
+
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[ComparatorUnknown-0]
+	_ = x[Equals-1]
+	_ = x[NotEquals-2]
+	_ = x[LessThan-3]
+	_ = x[LessThanOrEquals-4]
+	_ = x[GreaterThan-5]
+	_ = x[GreaterThanOrEquals-6]
+	_ = x[Near-7]
+	_ = x[ContainsAllTokens-8]
+	_ = x[In-9]
+	_ = x[NotIn-10]
+	_ = x[ContainsAnyTokens-11]
+	_ = x[ListContains-12]
+	_ = x[NotListContains-13]
+	_ = x[NotListContainsAny-14]
+	_ = x[BeginsWith-15]
+	_ = x[NotBeginsWith-16]
+	_ = x[ListMemberBeginsWith-17]
+	_ = x[NotListMemberBeginsWith-18]
+	_ = x[ListContainsAll-19]
+	_ = x[NotListContainsAll-20]
+}
+
+const _Comparator_name = "UNKNOWNEQUALSNOT_EQUALSLESS_THANLESS_THAN_OR_EQUALSGREATER_THANGREATER_THAN_OR_EQUALSNEARCONTAINS_ALL_TOKENSINNOT_INCONTAINS_ANY_TOKENSLIST_CONTAINSNOT_LIST_CONTAINSNOT_LIST_CONTAINS_ANYBEGINS_WITHNOT_BEGINS_WITHLIST_MEMBER_BEGINS_WITHNOT_LIST_MEMBER_BEGINS_WITHLIST_CONTAINS_ALLNOT_LIST_CONTAINS_ALL"
+
+var _Comparator_index = [...]uint16{0, 7, 13, 23, 32, 51, 63, 85, 89, 108, 110, 116, 135, 148, 165, 186, 197, 212, 235, 262, 279, 300}
+
+func (i Comparator) String() string {
+	if i >= Comparator(len(_Comparator_index)-1) {
+		return "Comparator(" + strconv.FormatInt(int64(i), 10) + ")"
+	}
+	return _Comparator_name[_Comparator_index[i]:_Comparator_index[i+1]]
+}
@@ -0,0 +1,31 @@
+package icloud
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter_JSON(t *testing.T) {
+	filter := Filter{
+		Comparator: BeginsWith,
+		FieldName:  "title",
+		FieldValue: FieldValue{Value: "Hello"},
+	}
+
+	b, err := json.Marshal(filter)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"comparator":"BEGINS_WITH","fieldName":"title","fieldValue":{"value":"Hello"}}`, string(b))
+
+	var got Filter
+	require.NoError(t, json.Unmarshal(b, &got))
+	assert.Equal(t, filter, got)
+	assert.Equal(t, "BEGINS_WITH", got.RawComparator())
+
+	var unknown Filter
+	require.NoError(t, json.Unmarshal([]byte(`{"comparator":"ENDS_WITH","fieldName":"title"}`), &unknown))
+	assert.Equal(t, ComparatorUnknown, unknown.Comparator)
+	assert.Equal(t, "ENDS_WITH", unknown.RawComparator())
+}
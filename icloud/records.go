@@ -1,10 +1,15 @@
 package icloud
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"time"
 )
 
 //go:generate ../bin/stringer -type=OperationType -linecomment -output=records_string.go
@@ -13,9 +18,11 @@ import (
 type OperationType uint8
 
 const (
+	// OperationUnknown is an operation type unknown to the package.
+	OperationUnknown OperationType = iota // unknown
 	// Create a new record. This operation fails if a record with the same
 	// record name already exists.
-	Create OperationType = iota + 1 // create
+	Create // create
 	// Update an existing record. Only the fields specified are changed.
 	Update // update
 	// ForceUpdate updates an existing record regardless of conflicts. Creates a
@@ -41,48 +48,98 @@ func (ot OperationType) MarshalJSON() ([]byte, error) {
 }
 
 // UnmarshalJSON implements json.Unmarshaler. It is in place to unmarshal the
-// OperationType from the string representation the server returns.
+// OperationType from the string representation the server returns. Operation
+// types unknown to the package are unmarshalled as OperationUnknown, so
+// responses stay decodable when the server introduces new operation types.
 func (ot *OperationType) UnmarshalJSON(b []byte) error {
 	var s string
 	if err := json.Unmarshal(b, &s); err != nil {
 		return err
 	}
 
+	*ot, _ = parseOperationType(s)
+
+	return nil
+}
+
+// parseOperationType returns the OperationType with the given string
+// representation. Operation types unknown to the package are returned as
+// OperationUnknown, along with their string representation. For known
+// operation types, the returned string is empty.
+func parseOperationType(s string) (OperationType, string) {
 	switch s {
 	case Create.String():
-		*ot = Create
+		return Create, ""
 	case Update.String():
-		*ot = Update
+		return Update, ""
 	case ForceUpdate.String():
-		*ot = ForceUpdate
+		return ForceUpdate, ""
 	case Replace.String():
-		*ot = Replace
+		return Replace, ""
 	case ForceReplace.String():
-		*ot = ForceReplace
+		return ForceReplace, ""
 	case Delete.String():
-		*ot = Delete
+		return Delete, ""
 	case ForceDelete.String():
-		*ot = ForceDelete
-	default:
-		return fmt.Errorf("unknown operation type %q", s)
+		return ForceDelete, ""
 	}
-
-	return nil
+	return OperationUnknown, s
 }
 
-// RecordsRequest is the request to every operation of the RecordsService.
+// RecordsRequest is the request to the modify operation of the
+// RecordsService.
 type RecordsRequest struct {
+	// ZoneID of the zone the records are in. If not set, the default zone is
+	// used.
+	ZoneID *ZoneID `json:"zoneID,omitempty"`
 	// Operations to apply to records in the database. Limited to
 	// MaxOperationPerRequest.
 	Operations []RecordOperation `json:"operations,omitempty"`
+	// Atomic specifies if the entire request fails when one or more operations
+	// fail. Not supported in the default zone.
+	Atomic bool `json:"atomic,omitempty"`
+	// NumbersAsStrings makes the server represent numbers in the returned
+	// fields as strings. Decoding them yields the same values, without
+	// relying on the server's JSON numbers being exact.
+	NumbersAsStrings bool `json:"numbersAsStrings,omitempty"`
 }
 
 // RecordOperation is an operation on a single record.
 type RecordOperation struct {
-	// Type of the operation.
+	// Type of the operation. Types unknown to the package are
+	// OperationUnknown, use RawType to get them.
 	Type OperationType `json:"operationType,omitempty"`
 	// Record to create, update, replace or delete.
 	Record Record `json:"record,omitempty"`
+
+	rawType string
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It is in place to retain
+// operation types unknown to the package.
+func (op *RecordOperation) UnmarshalJSON(b []byte) error {
+	type LocalRecordOperation RecordOperation
+	localRecordOperation := struct {
+		*LocalRecordOperation
+
+		Type string `json:"operationType"`
+	}{
+		LocalRecordOperation: (*LocalRecordOperation)(op),
+	}
+
+	if err := json.Unmarshal(b, &localRecordOperation); err != nil {
+		return err
+	}
+
+	op.Type, op.rawType = parseOperationType(localRecordOperation.Type)
+
+	return nil
+}
+
+// RawType returns the operation type as it was unmarshalled. It is the string
+// representation of Type, unless the operation type is unknown to the package.
+func (op RecordOperation) RawType() string {
+	return rawString(op.rawType, op.Type)
 }
 
 // Record is a record in the database.
@@ -91,11 +148,107 @@ type Record struct {
 	Name string `json:"recordName,omitempty"`
 	// Type of the record.
 	Type string `json:"recordType,omitempty"`
+	// ChangeTag identifies a specific version of the record. It must be set
+	// when updating, replacing or deleting a record without force.
+	ChangeTag string `json:"recordChangeTag,omitempty"`
+	// ZoneID of the zone the record is in.
+	ZoneID *ZoneID `json:"zoneID,omitempty"`
 	// Fields of the record.
 	Fields Fields `json:"fields,omitempty"`
+	// Parent of the record. Records are shared along with their parent.
+	Parent *Reference `json:"parent,omitempty"`
+	// Share references the share record of a shared root record.
+	Share *Reference `json:"share,omitempty"`
+	// ShortGUID identifies a share record. Set by the server.
+	ShortGUID string `json:"shortGUID,omitempty"`
+	// PublicPermission is the permission of users that aren't participants
+	// of a share record. Only set for share records. Permissions unknown to
+	// the package are PermissionUnknown, use RawPublicPermission to get them.
+	PublicPermission Permission `json:"publicPermission,omitempty"`
+	// Participants of a share record. Only set for share records.
+	Participants []Participant `json:"participants,omitempty"`
+	// Owner of a share record. Set by the server.
+	Owner *Participant `json:"owner,omitempty"`
+	// CurrentUserParticipant is the current user as a participant of a share
+	// record. Set by the server.
+	CurrentUserParticipant *Participant `json:"currentUserParticipant,omitempty"`
+	// Deleted is true, if the record has been deleted.
+	Deleted bool `json:"deleted,omitempty"`
+	// Reason the operation on the record failed.
+	Reason string `json:"reason,omitempty"`
+	// ServerErrorCode is the error code of the failed operation on the record.
+	// Codes unknown to the package are Unknown, the error returned by Err
+	// retains them.
+	ServerErrorCode ErrorCode `json:"serverErrorCode,omitempty"`
+
+	rawPublicPermission string
+	rawErrorCode        string
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It is in place to retain public
+// permissions and server error codes unknown to the package.
+func (r *Record) UnmarshalJSON(b []byte) error {
+	type LocalRecord Record
+	localRecord := struct {
+		*LocalRecord
+
+		PublicPermission string `json:"publicPermission"`
+		ServerErrorCode  string `json:"serverErrorCode"`
+	}{
+		LocalRecord: (*LocalRecord)(r),
+	}
+
+	if err := json.Unmarshal(b, &localRecord); err != nil {
+		return err
+	}
+
+	r.PublicPermission, r.rawPublicPermission = parsePermission(localRecord.PublicPermission)
+	r.ServerErrorCode, r.rawErrorCode = parseErrorCode(localRecord.ServerErrorCode)
+
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. It is in place to marshal public
+// permissions and server error codes unknown to the package as they were
+// unmarshalled.
+func (r Record) MarshalJSON() ([]byte, error) {
+	type LocalRecord Record
+	localRecord := struct {
+		LocalRecord
+
+		PublicPermission string `json:"publicPermission,omitempty"`
+		ServerErrorCode  string `json:"serverErrorCode,omitempty"`
+	}{
+		LocalRecord: LocalRecord(r),
+
+		PublicPermission: marshalString(r.rawPublicPermission, r.PublicPermission, r.PublicPermission == PermissionUnknown),
+		ServerErrorCode:  marshalString(r.rawErrorCode, r.ServerErrorCode, r.ServerErrorCode == Unknown),
+	}
+
+	return json.Marshal(localRecord)
+}
+
+// RawPublicPermission returns the public permission as sent by the server. It
+// is the string representation of PublicPermission, unless the server sent a
+// permission unknown to the package.
+func (r Record) RawPublicPermission() string {
+	return rawString(r.rawPublicPermission, r.PublicPermission)
 }
 
-// Fields is a list of fields.
+// Err returns the error that occurred while operating on the record, if any.
+func (r Record) Err() error {
+	if r.ServerErrorCode == Unknown && r.rawErrorCode == "" && r.Reason == "" {
+		return nil
+	}
+	return Error{
+		Reason:  r.Reason,
+		Code:    r.ServerErrorCode,
+		rawCode: r.rawErrorCode,
+	}
+}
+
+// Fields is a list of fields. Fields unmarshalled from JSON are sorted by
+// name, so their order is deterministic.
 type Fields []Field
 
 // MarshalJSON implements json.Marshaler. It is in place to marshal the
@@ -119,7 +272,19 @@ func (f *Fields) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
-	for fieldName, field := range fields {
+	// Sort the fields by name, as the order of the keys of a JSON object
+	// isn't preserved.
+	names := make([]string, 0, len(fields))
+	for fieldName := range fields {
+		names = append(names, fieldName)
+	}
+	sort.Strings(names)
+
+	// Replace existing fields instead of appending to them. A new slice is
+	// used, so fields sharing the backing array aren't overwritten.
+	*f = make(Fields, 0, len(names))
+	for _, fieldName := range names {
+		field := fields[fieldName]
 		field.Name = fieldName
 		*f = append(*f, *field)
 	}
@@ -127,20 +292,306 @@ func (f *Fields) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// Get returns the field with the given name.
+func (f Fields) Get(name string) (*Field, bool) {
+	for i := range f {
+		if f[i].Name == name {
+			return &f[i], true
+		}
+	}
+	return nil, false
+}
+
+// Has returns true if a field with the given name exists.
+func (f Fields) Has(name string) bool {
+	_, ok := f.Get(name)
+	return ok
+}
+
+// Names returns the names of the fields, in order.
+func (f Fields) Names() []string {
+	names := make([]string, len(f))
+	for i, field := range f {
+		names[i] = field.Name
+	}
+	return names
+}
+
+// Set replaces the field with the same name or appends it, if no such field
+// exists.
+func (f *Fields) Set(field Field) {
+	if existing, ok := f.Get(field.Name); ok {
+		*existing = field
+		return
+	}
+	*f = append(*f, field)
+}
+
+// Delete removes the field with the given name, if it exists. The order of the
+// remaining fields is preserved.
+func (f *Fields) Delete(name string) {
+	for i := range *f {
+		if (*f)[i].Name == name {
+			*f = append((*f)[:i], (*f)[i+1:]...)
+			return
+		}
+	}
+}
+
+// GetString returns the value of the STRING field with the given name.
+func (f Fields) GetString(name string) (string, error) {
+	field, err := f.typedField(name, TypeString)
+	if err != nil {
+		return "", err
+	}
+
+	if s, ok := field.Value.(string); ok {
+		return s, nil
+	}
+	return "", field.mismatch(TypeString)
+}
+
+// GetInt64 returns the value of the INT64 field with the given name. Values
+// of other integer types and whole float64 values, like those of fields that
+// are not yet saved, are converted.
+func (f Fields) GetInt64(name string) (int64, error) {
+	field, err := f.typedField(name, TypeInt64)
+	if err != nil {
+		return 0, err
+	}
+
+	if i, ok := toInt64(field.Value); ok {
+		return i, nil
+	}
+	return 0, field.mismatch(TypeInt64)
+}
+
+// GetTime returns the value of the TIMESTAMP field with the given name. The
+// value is expected to be a time.Time or the number of milliseconds since the
+// Unix epoch, which is how the server represents timestamps.
+func (f Fields) GetTime(name string) (time.Time, error) {
+	field, err := f.typedField(name, TypeTimestamp)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if t, ok := field.Value.(time.Time); ok {
+		return t, nil
+	} else if ms, ok := toInt64(field.Value); ok {
+		return time.Unix(0, ms*int64(time.Millisecond)).UTC(), nil
+	}
+	return time.Time{}, field.mismatch(TypeTimestamp)
+}
+
+// typedField returns the field with the given name. An error is returned if it
+// doesn't exist or has a type other than the given one.
+func (f Fields) typedField(name string, typ FieldType) (*Field, error) {
+	field, ok := f.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("field %q: %w", name, ErrFieldNotFound)
+	} else if field.Type != "" && field.Type != typ.String() {
+		return nil, field.mismatch(typ)
+	}
+	return field, nil
+}
+
+// mismatch returns a FieldTypeError for the field and the requested type.
+func (f *Field) mismatch(typ FieldType) error {
+	return &FieldTypeError{
+		Name:     f.Name,
+		Type:     f.Type,
+		Value:    f.Value,
+		Expected: typ,
+	}
+}
+
+// toInt64 converts integers and whole numbers of other types into an int64.
+func toInt64(v interface{}) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case json.Number:
+		i, err := v.Int64()
+		return i, err == nil
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64 {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
 // A Field is part of a record.
 type Field struct {
 	// Name of the field.
 	Name string `json:"-"`
 	// Type of the field.
 	Type string `json:"type,omitempty"`
-	// Value of the field.
+	// Value of the field. Values of INT64 fields returned by the server are
+	// int64, those of INT64_LIST fields are lists of int64.
 	Value interface{} `json:"value,omitempty"`
 }
 
-// RecordsResponse is the response recevied from every operation of the
-// RecordsService.
+// UnmarshalJSON implements json.Unmarshaler. It is in place to unmarshal
+// values of INT64 fields as int64 instead of float64, which can't represent
+// all of them. Numbers represented as strings, as returned for requests with
+// NumbersAsStrings set, are unmarshalled as numbers.
+func (f *Field) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Type  string          `json:"type"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	value, err := unmarshalValue(raw.Type, raw.Value)
+	if err != nil {
+		return err
+	}
+	f.Type, f.Value = raw.Type, value
+
+	return nil
+}
+
+// unmarshalValue unmarshals the raw value of a field of the given type.
+func unmarshalValue(typ string, b json.RawMessage) (interface{}, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+
+	var value interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	// Values of unknown types are left as decoded.
+	fieldType, _ := ParseFieldType(typ)
+
+	return convertNumbers(fieldType, value), nil
+}
+
+// convertNumbers converts the json.Number and string representations of the
+// numbers in a value of the given field type into int64 for INT64 fields and
+// float64 otherwise. Values that don't hold a valid number are left as is.
+func convertNumbers(typ FieldType, v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if typ == TypeInt64 || typ == TypeInt64List {
+			if i, err := v.Int64(); err == nil {
+				return i
+			}
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	case string:
+		switch typ {
+		case TypeInt64, TypeInt64List:
+			if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return i
+			}
+		case TypeDouble, TypeDoubleList, TypeTimestamp, TypeTimestampList:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = convertNumbers(typ, v[i])
+		}
+	case map[string]interface{}:
+		// Numbers nested in locations, references and assets are never
+		// represented as strings.
+		for key := range v {
+			v[key] = convertNumbers(0, v[key])
+		}
+	}
+	return v
+}
+
+// RecordsResponse is the response recevied from the modify and lookup
+// operations of the RecordsService.
 type RecordsResponse struct {
 	Records []Record `json:"records,omitempty"`
+
+	rc requestContext
+}
+
+// Err returns the error of the operation at index i, if any. It is a
+// *RequestError that wraps the error returned by Err of the record with the
+// context of the request.
+func (r *RecordsResponse) Err(i int) error {
+	err := r.Records[i].Err()
+	if err == nil {
+		return nil
+	}
+	return r.rc.wrap(err, i, r.Records[i].Name)
+}
+
+func (r *RecordsResponse) setRequestContext(rc requestContext) {
+	r.rc = rc
+}
+
+func (r *RecordsResponse) errorCodes() []ErrorCode {
+	var codes []ErrorCode
+	for _, record := range r.Records {
+		if record.Err() != nil {
+			codes = append(codes, record.ServerErrorCode)
+		}
+	}
+	return codes
+}
+
+// LookupRequest is the request to the lookup operation of the RecordsService.
+type LookupRequest struct {
+	// ZoneID of the zone the records are in. If not set, the default zone is
+	// used.
+	ZoneID *ZoneID `json:"zoneID,omitempty"`
+	// Records to fetch. Only their names are required.
+	Records []Record `json:"records,omitempty"`
+	// DesiredKeys limits the fields returned for each record. If not set, all
+	// fields are returned.
+	DesiredKeys []string `json:"desiredKeys,omitempty"`
+	// NumbersAsStrings makes the server represent numbers in the returned
+	// fields as strings. Decoding them yields the same values, without
+	// relying on the server's JSON numbers being exact.
+	NumbersAsStrings bool `json:"numbersAsStrings,omitempty"`
+}
+
+// ChangesRequest is the request to the changes operation of the
+// RecordsService.
+type ChangesRequest struct {
+	// ZoneID of the zone to fetch the changes of. The default zone doesn't
+	// support fetching changes.
+	ZoneID ZoneID `json:"zoneID"`
+	// SyncToken returned by a previous request. If not set, all records in the
+	// zone are returned.
+	SyncToken string `json:"syncToken,omitempty"`
+	// ResultsLimit is the maximum number of changed records to return.
+	ResultsLimit int `json:"resultsLimit,omitempty"`
+	// DesiredKeys limits the fields returned for each record. If not set, all
+	// fields are returned.
+	DesiredKeys []string `json:"desiredKeys,omitempty"`
+}
+
+// ChangesResponse is the response recevied from the changes operation of the
+// RecordsService.
+type ChangesResponse struct {
+	// ZoneID of the zone the changes belong to.
+	ZoneID ZoneID `json:"zoneID"`
+	// Records that changed. Deleted records are marked as such.
+	Records []Record `json:"records,omitempty"`
+	// SyncToken to pass to the next request.
+	SyncToken string `json:"syncToken"`
+	// MoreComing is true, if there are more changes to fetch.
+	MoreComing bool `json:"moreComing"`
 }
 
 // RecordsService handles communication with the record related operations of
@@ -149,9 +600,26 @@ type RecordsResponse struct {
 // CloudKit Web Services Reference: https://developer.apple.com/library/archive/documentation/DataManagement/Conceptual/CloudKitWebServicesReference/ModifyRecords.html
 type RecordsService service
 
-// Modify records in a database.
+// Modify records in a database. If the client has a Schema, the records are
+// validated before the request is sent.
 func (s *RecordsService) Modify(ctx context.Context, database Database, req RecordsRequest) (*RecordsResponse, error) {
-	path := "/" + database.String() + s.basePath
+	if err := s.client.validateOperations(req.Operations); err != nil {
+		return nil, err
+	}
+
+	path := "/" + database.String() + s.basePath + "/modify"
+
+	var res RecordsResponse
+	if err := s.client.call(ctx, http.MethodPost, path, req, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// Lookup records in a database by their name.
+func (s *RecordsService) Lookup(ctx context.Context, database Database, req LookupRequest) (*RecordsResponse, error) {
+	path := "/" + database.String() + s.basePath + "/lookup"
 
 	var res RecordsResponse
 	if err := s.client.call(ctx, http.MethodPost, path, req, &res); err != nil {
@@ -160,3 +628,33 @@ func (s *RecordsService) Modify(ctx context.Context, database Database, req Reco
 
 	return &res, nil
 }
+
+// Query records in a database. If the client has a Schema, the query is
+// validated before the request is sent.
+func (s *RecordsService) Query(ctx context.Context, database Database, req QueryRequest) (*QueryResponse, error) {
+	if err := s.client.validateQuery(req.Query); err != nil {
+		return nil, err
+	}
+
+	path := "/" + database.String() + s.basePath + "/query"
+
+	var res QueryResponse
+	if err := s.client.call(ctx, http.MethodPost, path, req, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// Changes fetches the records of a zone that changed since the sync token of
+// the request was issued.
+func (s *RecordsService) Changes(ctx context.Context, database Database, req ChangesRequest) (*ChangesResponse, error) {
+	path := "/" + database.String() + s.basePath + "/changes"
+
+	var res ChangesResponse
+	if err := s.client.call(ctx, http.MethodPost, path, req, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
@@ -8,6 +8,7 @@ func _() {
 	// An "invalid array index" compiler error signifies that the constant values have changed.
 	// Re-run the stringer command to generate them again.
 	var x [1]struct{}
+	_ = x[OperationUnknown-0]
 	_ = x[Create-1]
 	_ = x[Update-2]
 	_ = x[ForceUpdate-3]
@@ -17,14 +18,13 @@ func _() {
 	_ = x[ForceDelete-7]
 }
 
-const _OperationType_name = "createupdateforceUpdatereplaceforceReplacedeleteforceDelete"
+const _OperationType_name = "unknowncreateupdateforceUpdatereplaceforceReplacedeleteforceDelete"
 
-var _OperationType_index = [...]uint8{0, 6, 12, 23, 30, 42, 48, 59}
+var _OperationType_index = [...]uint8{0, 7, 13, 19, 30, 37, 49, 55, 66}
 
 func (i OperationType) String() string {
-	i -= 1
 	if i >= OperationType(len(_OperationType_index)-1) {
-		return "OperationType(" + strconv.FormatInt(int64(i+1), 10) + ")"
+		return "OperationType(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
 	return _OperationType_name[_OperationType_index[i]:_OperationType_index[i+1]]
 }
@@ -0,0 +1,202 @@
+package icloud
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFields_UnmarshalJSON(t *testing.T) {
+	// 2^53 + 1 can't be represented as float64.
+	tests := []struct {
+		name  string
+		input string
+		exp   Field
+	}{
+		{
+			name:  "int64",
+			input: `{"type": "INT64", "value": 9007199254740993}`,
+			exp:   Field{Type: "INT64", Value: int64(9007199254740993)},
+		},
+		{
+			name:  "int64 as string",
+			input: `{"type": "INT64", "value": "9007199254740993"}`,
+			exp:   Field{Type: "INT64", Value: int64(9007199254740993)},
+		},
+		{
+			name:  "int64 list",
+			input: `{"type": "INT64_LIST", "value": [1, "9007199254740993"]}`,
+			exp:   Field{Type: "INT64_LIST", Value: []interface{}{int64(1), int64(9007199254740993)}},
+		},
+		{
+			name:  "double",
+			input: `{"type": "DOUBLE", "value": 1.5}`,
+			exp:   Field{Type: "DOUBLE", Value: 1.5},
+		},
+		{
+			name:  "double as string",
+			input: `{"type": "DOUBLE", "value": "1.5"}`,
+			exp:   Field{Type: "DOUBLE", Value: 1.5},
+		},
+		{
+			name:  "string",
+			input: `{"type": "STRING", "value": "42"}`,
+			exp:   Field{Type: "STRING", Value: "42"},
+		},
+		{
+			name:  "timestamp",
+			input: `{"type": "TIMESTAMP", "value": 1609459200000}`,
+			exp:   Field{Type: "TIMESTAMP", Value: float64(1609459200000)},
+		},
+		{
+			name:  "timestamp as string",
+			input: `{"type": "TIMESTAMP", "value": "1609459200000"}`,
+			exp:   Field{Type: "TIMESTAMP", Value: float64(1609459200000)},
+		},
+		{
+			name:  "timestamp list as strings",
+			input: `{"type": "TIMESTAMP_LIST", "value": ["1609459200000"]}`,
+			exp:   Field{Type: "TIMESTAMP_LIST", Value: []interface{}{float64(1609459200000)}},
+		},
+		{
+			name:  "location",
+			input: `{"type": "LOCATION", "value": {"latitude": 52.5, "longitude": 13.4}}`,
+			exp:   Field{Type: "LOCATION", Value: map[string]interface{}{"latitude": 52.5, "longitude": 13.4}},
+		},
+		{
+			name:  "untyped",
+			input: `{"value": 42}`,
+			exp:   Field{Value: float64(42)},
+		},
+		{
+			name:  "null",
+			input: `{"type": "INT64", "value": null}`,
+			exp:   Field{Type: "INT64"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields Fields
+			require.NoError(t, json.Unmarshal([]byte(`{"f": `+tt.input+`}`), &fields))
+			require.Len(t, fields, 1)
+
+			tt.exp.Name = "f"
+			assert.Equal(t, tt.exp, fields[0])
+		})
+	}
+}
+
+func TestFields_UnmarshalJSON_Sorted(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		var fields Fields
+		require.NoError(t, json.Unmarshal([]byte(`{
+			"title": {"value": "Hello"},
+			"author": {"value": "Bob"},
+			"likes": {"type": "INT64", "value": 5},
+			"body": {"value": "World"}
+		}`), &fields))
+		assert.Equal(t, []string{"author", "body", "likes", "title"}, fields.Names())
+	}
+}
+
+func TestFields_UnmarshalJSON_NonEmpty(t *testing.T) {
+	fields := Fields{
+		{Name: "title", Value: "Hello"},
+		{Name: "body", Value: "World"},
+	}
+	existing := fields
+
+	require.NoError(t, json.Unmarshal([]byte(`{"likes": {"type": "INT64", "value": 5}}`), &fields))
+	assert.Equal(t, Fields{{Name: "likes", Type: "INT64", Value: int64(5)}}, fields)
+	assert.Equal(t, Field{Name: "title", Value: "Hello"}, existing[0])
+
+	var record Record
+	require.NoError(t, json.Unmarshal([]byte(`{"fields": {"title": {"value": "Hello"}}}`), &record))
+	require.NoError(t, json.Unmarshal([]byte(`{"fields": {"body": {"value": "World"}}}`), &record))
+	assert.Equal(t, []string{"body"}, record.Fields.Names())
+}
+
+func TestFields(t *testing.T) {
+	var fields Fields
+	assert.False(t, fields.Has("title"))
+	assert.Empty(t, fields.Names())
+
+	fields.Set(Field{Name: "title", Value: "Hello"})
+	fields.Set(Field{Name: "likes", Type: "INT64", Value: 5})
+	fields.Set(Field{Name: "body", Value: "World"})
+	assert.Equal(t, []string{"title", "likes", "body"}, fields.Names())
+
+	fields.Set(Field{Name: "title", Value: "Hi"})
+	assert.Equal(t, []string{"title", "likes", "body"}, fields.Names())
+
+	field, ok := fields.Get("title")
+	require.True(t, ok)
+	assert.Equal(t, Field{Name: "title", Value: "Hi"}, *field)
+	assert.True(t, fields.Has("likes"))
+
+	fields.Delete("likes")
+	fields.Delete("unknown")
+	assert.Equal(t, []string{"title", "body"}, fields.Names())
+	assert.False(t, fields.Has("likes"))
+
+	_, ok = fields.Get("likes")
+	assert.False(t, ok)
+}
+
+func TestFields_Getters(t *testing.T) {
+	published := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var fields Fields
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"title": {"type": "STRING", "value": "Hello"},
+		"likes": {"type": "INT64", "value": 9007199254740993},
+		"published": {"type": "TIMESTAMP", "value": 1609502400000}
+	}`), &fields))
+	fields.Set(Field{Name: "count", Value: 42})
+	fields.Set(Field{Name: "updated", Value: published})
+	fields.Set(Field{Name: "ratio", Value: 0.5})
+
+	s, err := fields.GetString("title")
+	require.NoError(t, err)
+	assert.Equal(t, "Hello", s)
+
+	i, err := fields.GetInt64("likes")
+	require.NoError(t, err)
+	assert.Equal(t, int64(9007199254740993), i)
+
+	i, err = fields.GetInt64("count")
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), i)
+
+	ts, err := fields.GetTime("published")
+	require.NoError(t, err)
+	assert.Equal(t, published, ts)
+
+	ts, err = fields.GetTime("updated")
+	require.NoError(t, err)
+	assert.Equal(t, published, ts)
+
+	_, err = fields.GetString("subtitle")
+	assert.True(t, errors.Is(err, ErrFieldNotFound))
+	assert.EqualError(t, err, `field "subtitle": field not found`)
+
+	_, err = fields.GetString("likes")
+	assert.EqualError(t, err, `field "likes": expected STRING, got INT64`)
+
+	_, err = fields.GetInt64("ratio")
+	assert.EqualError(t, err, `field "ratio": expected INT64, got value of type float64`)
+
+	_, err = fields.GetTime("title")
+	var typeErr *FieldTypeError
+	require.True(t, errors.As(err, &typeErr))
+	assert.Equal(t, &FieldTypeError{
+		Name:     "title",
+		Type:     "STRING",
+		Value:    "Hello",
+		Expected: TypeTimestamp,
+	}, typeErr)
+}
@@ -0,0 +1,142 @@
+package schema
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const indent = "    "
+
+// String returns the canonical .ckdb representation of the schema. Roles and
+// record types are sorted by name. Fields are sorted by name as well, with
+// system fields first, followed by the grants sorted by role.
+func (s *Schema) String() string {
+	var sb strings.Builder
+
+	sb.WriteString("DEFINE SCHEMA\n")
+
+	roles := append([]string(nil), s.Roles...)
+	sort.Strings(roles)
+	if len(roles) > 0 {
+		sb.WriteString("\n")
+	}
+	for _, role := range roles {
+		fmt.Fprintf(&sb, "%sCREATE ROLE %s;\n", indent, quoteName(role))
+	}
+
+	recordTypes := append([]RecordType(nil), s.RecordTypes...)
+	sort.Slice(recordTypes, func(i, j int) bool {
+		return recordTypes[i].Name < recordTypes[j].Name
+	})
+	for _, rt := range recordTypes {
+		sb.WriteString("\n")
+		writeRecordType(&sb, rt)
+	}
+
+	return sb.String()
+}
+
+// WriteTo writes the canonical .ckdb representation of the schema to w. It
+// implements io.WriterTo.
+func (s *Schema) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, s.String())
+	return int64(n), err
+}
+
+// writeRecordType writes the definition of a record type. The types of the
+// fields are aligned.
+func writeRecordType(sb *strings.Builder, rt RecordType) {
+	fields := append([]Field(nil), rt.Fields...)
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].IsSystem() != fields[j].IsSystem() {
+			return fields[i].IsSystem()
+		}
+		return fields[i].Name < fields[j].Name
+	})
+
+	grants := make([]Grant, len(rt.Grants))
+	for i, grant := range rt.Grants {
+		grant.Permissions = append([]Permission(nil), grant.Permissions...)
+		sort.Slice(grant.Permissions, func(i, j int) bool {
+			return grant.Permissions[i] < grant.Permissions[j]
+		})
+		grants[i] = grant
+	}
+	sort.SliceStable(grants, func(i, j int) bool {
+		return grants[i].Role < grants[j].Role
+	})
+
+	var width int
+	for _, field := range fields {
+		if n := len(quoteName(field.Name)); n > width {
+			width = n
+		}
+	}
+
+	lines := make([]string, 0, len(fields)+len(grants))
+	for _, field := range fields {
+		line := fmt.Sprintf("%-*s %s", width, quoteName(field.Name), typeName(field))
+		if field.Queryable {
+			line += " QUERYABLE"
+		}
+		if field.Searchable {
+			line += " SEARCHABLE"
+		}
+		if field.Sortable {
+			line += " SORTABLE"
+		}
+		lines = append(lines, line)
+	}
+	for _, grant := range grants {
+		perms := make([]string, len(grant.Permissions))
+		for i, perm := range grant.Permissions {
+			perms[i] = perm.String()
+		}
+		lines = append(lines, fmt.Sprintf("GRANT %s TO %q", strings.Join(perms, ", "), grant.Role))
+	}
+
+	fmt.Fprintf(sb, "%sRECORD TYPE %s (\n", indent, quoteName(rt.Name))
+	for i, line := range lines {
+		sb.WriteString(indent + indent + line)
+		if i < len(lines)-1 {
+			sb.WriteString(",")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(indent + ");\n")
+}
+
+// typeName returns the .ckdb name of the type of the field.
+func typeName(field Field) string {
+	var name string
+	if field.Type.IsList() {
+		name = "LIST<" + field.Type.Elem().String() + ">"
+	} else {
+		name = field.Type.String()
+	}
+
+	if field.Encrypted {
+		return "ENCRYPTED " + name
+	}
+	return name
+}
+
+// quoteName quotes a name if it doesn't start with a letter, contains
+// characters not valid in unquoted names or could be mistaken for a keyword.
+func quoteName(name string) string {
+	quote := strings.EqualFold(name, "GRANT")
+	for i, c := range name {
+		if (i == 0 && !unicode.IsLetter(c)) || !isIdentRune(c) {
+			quote = true
+			break
+		}
+	}
+
+	if quote {
+		return `"` + name + `"`
+	}
+	return name
+}
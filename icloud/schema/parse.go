@@ -0,0 +1,457 @@
+package schema
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+// SyntaxError is returned by Parse if the schema is malformed.
+type SyntaxError struct {
+	// Line and Column of the offending token, starting at 1.
+	Line, Column int
+	// Msg describes the error.
+	Msg string
+}
+
+// Error implements error.
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// Parse reads a schema in the .ckdb format from r. Keywords and field types
+// are case insensitive, names are case sensitive and may be quoted. Comments
+// start with "//" or "--" and end at the end of the line.
+func Parse(r io.Reader) (*Schema, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{lex: lexer{src: []rune(string(b)), line: 1, col: 1}}
+	if err = p.next(); err != nil {
+		return nil, err
+	}
+	return p.parseSchema()
+}
+
+// tokenKind is the kind of a token.
+type tokenKind uint8
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenQuoted
+	tokenPunct
+)
+
+// token is a lexical token of a schema.
+type token struct {
+	kind      tokenKind
+	text      string
+	line, col int
+}
+
+// String returns a description of the token for use in error messages.
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of input"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lexer splits a schema into tokens.
+type lexer struct {
+	src       []rune
+	pos       int
+	line, col int
+}
+
+// peek returns the rune at the given offset from the current position or zero
+// at the end of the input.
+func (l *lexer) peek(offset int) rune {
+	if l.pos+offset >= len(l.src) {
+		return 0
+	}
+	return l.src[l.pos+offset]
+}
+
+// advance moves past the current rune.
+func (l *lexer) advance() {
+	if l.src[l.pos] == '\n' {
+		l.line++
+		l.col = 1
+	} else {
+		l.col++
+	}
+	l.pos++
+}
+
+// next returns the next token.
+func (l *lexer) next() (token, error) {
+	l.skipSpaceAndComments()
+
+	tok := token{line: l.line, col: l.col}
+	if l.pos >= len(l.src) {
+		return tok, nil
+	}
+
+	switch c := l.peek(0); {
+	case c == '"':
+		l.advance()
+		var sb strings.Builder
+		for {
+			switch c := l.peek(0); c {
+			case 0, '\n':
+				return tok, &SyntaxError{Line: tok.line, Column: tok.col, Msg: "unterminated quoted name"}
+			case '"':
+				l.advance()
+				tok.kind, tok.text = tokenQuoted, sb.String()
+				return tok, nil
+			default:
+				sb.WriteRune(c)
+				l.advance()
+			}
+		}
+	case isIdentRune(c):
+		start := l.pos
+		for isIdentRune(l.peek(0)) {
+			l.advance()
+		}
+		tok.kind, tok.text = tokenIdent, string(l.src[start:l.pos])
+		return tok, nil
+	case strings.ContainsRune("(),;<>", c):
+		l.advance()
+		tok.kind, tok.text = tokenPunct, string(c)
+		return tok, nil
+	default:
+		return tok, &SyntaxError{Line: tok.line, Column: tok.col, Msg: fmt.Sprintf("unexpected character %q", c)}
+	}
+}
+
+// skipSpaceAndComments moves past whitespace and comments.
+func (l *lexer) skipSpaceAndComments() {
+	for l.pos < len(l.src) {
+		switch c := l.peek(0); {
+		case unicode.IsSpace(c):
+			l.advance()
+		case c == '/' && l.peek(1) == '/', c == '-' && l.peek(1) == '-':
+			for l.pos < len(l.src) && l.peek(0) != '\n' {
+				l.advance()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// isIdentRune returns true, if the rune is valid in an unquoted name.
+func isIdentRune(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// parser parses a schema from the tokens of its lexer.
+type parser struct {
+	lex lexer
+	tok token
+}
+
+// next advances to the next token.
+func (p *parser) next() (err error) {
+	p.tok, err = p.lex.next()
+	return err
+}
+
+// errorf returns a SyntaxError at the position of the current token.
+func (p *parser) errorf(format string, a ...interface{}) error {
+	return &SyntaxError{Line: p.tok.line, Column: p.tok.col, Msg: fmt.Sprintf(format, a...)}
+}
+
+// isKeyword returns true, if the current token is the given keyword.
+func (p *parser) isKeyword(keyword string) bool {
+	return p.tok.kind == tokenIdent && strings.EqualFold(p.tok.text, keyword)
+}
+
+// isPunct returns true, if the current token is the given punctuation.
+func (p *parser) isPunct(punct string) bool {
+	return p.tok.kind == tokenPunct && p.tok.text == punct
+}
+
+// expectKeyword consumes the given keyword.
+func (p *parser) expectKeyword(keyword string) error {
+	if !p.isKeyword(keyword) {
+		return p.errorf("expected %s, got %s", keyword, p.tok)
+	}
+	return p.next()
+}
+
+// expectPunct consumes the given punctuation.
+func (p *parser) expectPunct(punct string) error {
+	if !p.isPunct(punct) {
+		return p.errorf("expected %q, got %s", punct, p.tok)
+	}
+	return p.next()
+}
+
+// parseName consumes a quoted or unquoted name.
+func (p *parser) parseName(what string) (string, error) {
+	if p.tok.kind != tokenIdent && p.tok.kind != tokenQuoted {
+		return "", p.errorf("expected %s, got %s", what, p.tok)
+	} else if p.tok.text == "" {
+		return "", p.errorf("empty %s", what)
+	}
+	name := p.tok.text
+	return name, p.next()
+}
+
+// parseSchema parses a complete schema.
+func (p *parser) parseSchema() (*Schema, error) {
+	if err := p.expectKeyword("DEFINE"); err != nil {
+		return nil, err
+	} else if err = p.expectKeyword("SCHEMA"); err != nil {
+		return nil, err
+	}
+
+	var (
+		s     = new(Schema)
+		roles = make(map[string]bool)
+	)
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.isKeyword("CREATE"):
+			tok := p.tok
+			role, err := p.parseRole()
+			if err != nil {
+				return nil, err
+			} else if roles[role] {
+				return nil, &SyntaxError{Line: tok.line, Column: tok.col, Msg: fmt.Sprintf("duplicate role %q", role)}
+			}
+			roles[role] = true
+			s.Roles = append(s.Roles, role)
+		case p.isKeyword("RECORD"):
+			tok := p.tok
+			rt, err := p.parseRecordType()
+			if err != nil {
+				return nil, err
+			} else if _, ok := s.RecordType(rt.Name); ok {
+				return nil, &SyntaxError{Line: tok.line, Column: tok.col, Msg: fmt.Sprintf("duplicate record type %q", rt.Name)}
+			}
+			s.RecordTypes = append(s.RecordTypes, rt)
+		default:
+			return nil, p.errorf("expected CREATE ROLE or RECORD TYPE, got %s", p.tok)
+		}
+	}
+
+	return s, nil
+}
+
+// parseRole parses a role definition:
+//
+//	CREATE ROLE name;
+func (p *parser) parseRole() (string, error) {
+	if err := p.expectKeyword("CREATE"); err != nil {
+		return "", err
+	} else if err = p.expectKeyword("ROLE"); err != nil {
+		return "", err
+	}
+
+	name, err := p.parseName("role name")
+	if err != nil {
+		return "", err
+	}
+
+	return name, p.expectPunct(";")
+}
+
+// parseRecordType parses a record type definition:
+//
+//	RECORD TYPE name ( field | grant, ... );
+func (p *parser) parseRecordType() (RecordType, error) {
+	var rt RecordType
+	if err := p.expectKeyword("RECORD"); err != nil {
+		return rt, err
+	} else if err = p.expectKeyword("TYPE"); err != nil {
+		return rt, err
+	}
+
+	var err error
+	if rt.Name, err = p.parseName("record type name"); err != nil {
+		return rt, err
+	} else if err = p.expectPunct("("); err != nil {
+		return rt, err
+	}
+
+	for !p.isPunct(")") {
+		if len(rt.Fields)+len(rt.Grants) > 0 {
+			if err = p.expectPunct(","); err != nil {
+				return rt, err
+			}
+		}
+
+		if p.isKeyword("GRANT") {
+			grant, err := p.parseGrant()
+			if err != nil {
+				return rt, err
+			}
+			rt.Grants = append(rt.Grants, grant)
+			continue
+		}
+
+		tok := p.tok
+		field, err := p.parseField()
+		if err != nil {
+			return rt, err
+		} else if _, ok := rt.Field(field.Name); ok {
+			return rt, &SyntaxError{Line: tok.line, Column: tok.col, Msg: fmt.Sprintf("duplicate field %q of record type %q", field.Name, rt.Name)}
+		}
+		rt.Fields = append(rt.Fields, field)
+	}
+
+	if err = p.expectPunct(")"); err != nil {
+		return rt, err
+	}
+	return rt, p.expectPunct(";")
+}
+
+// parseField parses a field definition:
+//
+//	name [ENCRYPTED] type [QUERYABLE] [SEARCHABLE] [SORTABLE]
+func (p *parser) parseField() (Field, error) {
+	var (
+		field Field
+		err   error
+	)
+	if field.Name, err = p.parseName("field name"); err != nil {
+		return field, err
+	}
+
+	if p.isKeyword("ENCRYPTED") {
+		field.Encrypted = true
+		if err = p.next(); err != nil {
+			return field, err
+		}
+	}
+
+	if field.Type, err = p.parseType(); err != nil {
+		return field, err
+	}
+
+	for p.tok.kind == tokenIdent {
+		var index *bool
+		switch strings.ToUpper(p.tok.text) {
+		case "QUERYABLE":
+			index = &field.Queryable
+		case "SEARCHABLE":
+			index = &field.Searchable
+		case "SORTABLE":
+			index = &field.Sortable
+		default:
+			return field, p.errorf("unknown index %s of field %q", p.tok, field.Name)
+		}
+		if *index {
+			return field, p.errorf("duplicate index %s of field %q", p.tok, field.Name)
+		}
+		*index = true
+
+		if err = p.next(); err != nil {
+			return field, err
+		}
+	}
+
+	return field, nil
+}
+
+// parseType parses a field type:
+//
+//	ASSET | BYTES | DOUBLE | INT64 | LOCATION | REFERENCE | STRING | TIMESTAMP | LIST<type>
+func (p *parser) parseType() (icloud.FieldType, error) {
+	if p.isKeyword("LIST") {
+		if err := p.next(); err != nil {
+			return 0, err
+		} else if err = p.expectPunct("<"); err != nil {
+			return 0, err
+		}
+
+		tok := p.tok
+		elem, err := p.parseType()
+		if err != nil {
+			return 0, err
+		} else if elem.IsList() {
+			return 0, &SyntaxError{Line: tok.line, Column: tok.col, Msg: "nested lists are not supported"}
+		}
+
+		return elem.List(), p.expectPunct(">")
+	}
+
+	if p.tok.kind != tokenIdent {
+		return 0, p.errorf("expected field type, got %s", p.tok)
+	}
+
+	var typ icloud.FieldType
+	switch strings.ToUpper(p.tok.text) {
+	case "ASSET":
+		typ = icloud.TypeAsset
+	case "BYTES":
+		typ = icloud.TypeBytes
+	case "DOUBLE":
+		typ = icloud.TypeDouble
+	case "INT64":
+		typ = icloud.TypeInt64
+	case "LOCATION":
+		typ = icloud.TypeLocation
+	case "REFERENCE":
+		typ = icloud.TypeReference
+	case "STRING":
+		typ = icloud.TypeString
+	case "TIMESTAMP":
+		typ = icloud.TypeTimestamp
+	default:
+		return 0, p.errorf("unknown field type %s", p.tok)
+	}
+
+	return typ, p.next()
+}
+
+// parseGrant parses a grant:
+//
+//	GRANT permission, ... TO role
+func (p *parser) parseGrant() (Grant, error) {
+	var grant Grant
+	if err := p.expectKeyword("GRANT"); err != nil {
+		return grant, err
+	}
+
+	for {
+		var perm Permission
+		switch {
+		case p.isKeyword(Read.String()):
+			perm = Read
+		case p.isKeyword(Create.String()):
+			perm = Create
+		case p.isKeyword(Write.String()):
+			perm = Write
+		default:
+			return grant, p.errorf("expected permission, got %s", p.tok)
+		}
+		grant.Permissions = append(grant.Permissions, perm)
+
+		if err := p.next(); err != nil {
+			return grant, err
+		} else if !p.isPunct(",") {
+			break
+		} else if err = p.next(); err != nil {
+			return grant, err
+		}
+	}
+
+	if err := p.expectKeyword("TO"); err != nil {
+		return grant, err
+	}
+
+	var err error
+	grant.Role, err = p.parseName("role name")
+	return grant, err
+}
@@ -0,0 +1,119 @@
+// Package schema implements a parser and a printer for CloudKit schemas in the
+// .ckdb format exported by the CloudKit Console and cktool:
+//
+//	DEFINE SCHEMA
+//
+//	    CREATE ROLE Editor;
+//
+//	    RECORD TYPE Post (
+//	        "___recordID" REFERENCE QUERYABLE,
+//	        title         STRING QUERYABLE SEARCHABLE SORTABLE,
+//	        tags          LIST<STRING>,
+//	        GRANT WRITE TO "_creator",
+//	        GRANT READ TO "_world"
+//	    );
+//
+// Parse reads a schema into a Schema. Its String method prints it back in a
+// canonical form, with roles, record types and fields sorted by name, which
+// makes schemas easy to diff and keep under version control.
+package schema
+
+import (
+	"strings"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+//go:generate ../../bin/stringer -type=Permission -linecomment -output=schema_string.go
+
+// SystemFieldPrefix is the prefix of the names of the fields CloudKit adds to
+// every record type, like "___createTime" or "___recordID".
+const SystemFieldPrefix = "___"
+
+// Built-in security roles.
+const (
+	// RoleWorld is the role of every user, even unauthenticated ones.
+	RoleWorld = "_world"
+	// RoleICloud is the role of every authenticated user.
+	RoleICloud = "_icloud"
+	// RoleCreator is the role of the user that created a record.
+	RoleCreator = "_creator"
+)
+
+// Schema is the schema of a container.
+type Schema struct {
+	// Roles are the custom security roles defined by the schema.
+	Roles []string
+	// RecordTypes defined by the schema.
+	RecordTypes []RecordType
+}
+
+// RecordType returns the record type with the given name.
+func (s *Schema) RecordType(name string) (*RecordType, bool) {
+	for i := range s.RecordTypes {
+		if s.RecordTypes[i].Name == name {
+			return &s.RecordTypes[i], true
+		}
+	}
+	return nil, false
+}
+
+// RecordType is a record type of a schema.
+type RecordType struct {
+	// Name of the record type.
+	Name string
+	// Fields of the record type, including system fields.
+	Fields []Field
+	// Grants of permissions on records of the type to security roles.
+	Grants []Grant
+}
+
+// Field returns the field with the given name.
+func (rt *RecordType) Field(name string) (*Field, bool) {
+	for i := range rt.Fields {
+		if rt.Fields[i].Name == name {
+			return &rt.Fields[i], true
+		}
+	}
+	return nil, false
+}
+
+// Field is a field of a record type.
+type Field struct {
+	// Name of the field.
+	Name string
+	// Type of the field. Asset fields are of type icloud.TypeAsset or
+	// icloud.TypeAssetList.
+	Type icloud.FieldType
+	// Encrypted is true, if the values of the field are encrypted.
+	Encrypted bool
+	// Queryable is true, if the field is indexed for filtering.
+	Queryable bool
+	// Searchable is true, if the field is indexed for full text search.
+	Searchable bool
+	// Sortable is true, if the field is indexed for sorting.
+	Sortable bool
+}
+
+// IsSystem returns true, if the field is a system field.
+func (f Field) IsSystem() bool {
+	return strings.HasPrefix(f.Name, SystemFieldPrefix)
+}
+
+// Grant grants permissions on records of a type to a security role.
+type Grant struct {
+	// Permissions granted.
+	Permissions []Permission
+	// Role the permissions are granted to.
+	Role string
+}
+
+// Permission is a permission on records granted to a security role.
+type Permission uint8
+
+// All available permissions.
+const (
+	Read   Permission = iota + 1 // READ
+	Create                       // CREATE
+	Write                        // WRITE
+)
@@ -0,0 +1,194 @@
+package schema_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+	"github.com/lukasmalkmus/icloud-go/icloud/schema"
+)
+
+const input = `define schema
+    -- Roles.
+    create role Moderator;
+    CREATE ROLE Editor;
+
+    RECORD TYPE Post (
+        title STRING QUERYABLE SORTABLE SEARCHABLE,
+        "___recordID" REFERENCE QUERYABLE,
+        body ENCRYPTED string,
+        tags LIST<STRING> queryable,
+        cover Asset,
+        "grant" INT64, // Quoted to not be mistaken for a grant.
+        GRANT WRITE, READ TO "_creator",
+        GRANT READ TO Editor,
+        GRANT CREATE TO "_icloud"
+    );
+
+    RECORD TYPE Author (
+        name STRING,
+        GRANT READ TO "_world"
+    );
+`
+
+const canonical = `DEFINE SCHEMA
+
+    CREATE ROLE Editor;
+    CREATE ROLE Moderator;
+
+    RECORD TYPE Author (
+        name STRING,
+        GRANT READ TO "_world"
+    );
+
+    RECORD TYPE Post (
+        "___recordID" REFERENCE QUERYABLE,
+        body          ENCRYPTED STRING,
+        cover         ASSET,
+        "grant"       INT64,
+        tags          LIST<STRING> QUERYABLE,
+        title         STRING QUERYABLE SEARCHABLE SORTABLE,
+        GRANT READ TO "Editor",
+        GRANT READ, WRITE TO "_creator",
+        GRANT CREATE TO "_icloud"
+    );
+`
+
+func TestParse(t *testing.T) {
+	s, err := schema.Parse(strings.NewReader(input))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Moderator", "Editor"}, s.Roles)
+	require.Len(t, s.RecordTypes, 2)
+
+	post, ok := s.RecordType("Post")
+	require.True(t, ok)
+	assert.Len(t, post.Fields, 6)
+	assert.Equal(t, []schema.Grant{
+		{Permissions: []schema.Permission{schema.Write, schema.Read}, Role: schema.RoleCreator},
+		{Permissions: []schema.Permission{schema.Read}, Role: "Editor"},
+		{Permissions: []schema.Permission{schema.Create}, Role: schema.RoleICloud},
+	}, post.Grants)
+
+	title, ok := post.Field("title")
+	require.True(t, ok)
+	assert.Equal(t, schema.Field{
+		Name:       "title",
+		Type:       icloud.TypeString,
+		Queryable:  true,
+		Searchable: true,
+		Sortable:   true,
+	}, *title)
+
+	recordID, ok := post.Field("___recordID")
+	require.True(t, ok)
+	assert.True(t, recordID.IsSystem())
+
+	body, _ := post.Field("body")
+	assert.True(t, body.Encrypted)
+
+	tags, _ := post.Field("tags")
+	assert.Equal(t, icloud.TypeStringList, tags.Type)
+
+	cover, _ := post.Field("cover")
+	assert.Equal(t, icloud.TypeAsset, cover.Type)
+
+	_, ok = post.Field("author")
+	assert.False(t, ok)
+	_, ok = s.RecordType("Comment")
+	assert.False(t, ok)
+}
+
+func TestSchema_String(t *testing.T) {
+	s, err := schema.Parse(strings.NewReader(input))
+	require.NoError(t, err)
+	assert.Equal(t, canonical, s.String())
+
+	// The canonical form is stable.
+	s, err = schema.Parse(strings.NewReader(canonical))
+	require.NoError(t, err)
+	assert.Equal(t, canonical, s.String())
+
+	var sb strings.Builder
+	n, err := s.WriteTo(&sb)
+	require.NoError(t, err)
+	assert.EqualValues(t, len(canonical), n)
+	assert.Equal(t, canonical, sb.String())
+
+	assert.Equal(t, "DEFINE SCHEMA\n", new(schema.Schema).String())
+}
+
+func TestParse_Error(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{
+			name:  "missing header",
+			input: "RECORD TYPE Post ();",
+			err:   `line 1, column 1: expected DEFINE, got "RECORD"`,
+		},
+		{
+			name:  "unknown statement",
+			input: "DEFINE SCHEMA\nDROP TYPE Post;",
+			err:   `line 2, column 1: expected CREATE ROLE or RECORD TYPE, got "DROP"`,
+		},
+		{
+			name:  "unknown field type",
+			input: "DEFINE SCHEMA\nRECORD TYPE Post (\n  title TEXT\n);",
+			err:   `line 3, column 9: unknown field type "TEXT"`,
+		},
+		{
+			name:  "nested list",
+			input: "DEFINE SCHEMA RECORD TYPE Post (tags LIST<LIST<STRING>>);",
+			err:   "line 1, column 43: nested lists are not supported",
+		},
+		{
+			name:  "unknown index",
+			input: "DEFINE SCHEMA RECORD TYPE Post (title STRING UNIQUE);",
+			err:   `line 1, column 46: unknown index "UNIQUE" of field "title"`,
+		},
+		{
+			name:  "duplicate field",
+			input: "DEFINE SCHEMA RECORD TYPE Post (title STRING, title INT64);",
+			err:   `line 1, column 47: duplicate field "title" of record type "Post"`,
+		},
+		{
+			name:  "duplicate record type",
+			input: "DEFINE SCHEMA RECORD TYPE Post (); RECORD TYPE Post ();",
+			err:   `line 1, column 36: duplicate record type "Post"`,
+		},
+		{
+			name:  "invalid permission",
+			input: `DEFINE SCHEMA RECORD TYPE Post (GRANT DELETE TO "_world");`,
+			err:   `line 1, column 39: expected permission, got "DELETE"`,
+		},
+		{
+			name:  "missing semicolon",
+			input: "DEFINE SCHEMA RECORD TYPE Post ()",
+			err:   `line 1, column 34: expected ";", got end of input`,
+		},
+		{
+			name:  "unterminated name",
+			input: `DEFINE SCHEMA RECORD TYPE "Post`,
+			err:   "line 1, column 27: unterminated quoted name",
+		},
+		{
+			name:  "unexpected character",
+			input: "DEFINE SCHEMA RECORD TYPE Post (title STRING = 1);",
+			err:   `line 1, column 46: unexpected character '='`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := schema.Parse(strings.NewReader(tt.input))
+			require.Error(t, err)
+			assert.EqualError(t, err, tt.err)
+			assert.IsType(t, new(schema.SyntaxError), err)
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+)
+
+var _ icloud.Schema = (*Schema)(nil)
+
+// ValidateRecord implements icloud.Schema. It reports unknown record types and
+// fields, writes to system fields and values that don't match the type of
+// their field. Fields without an explicit type are checked by the Go type of
+// their value. Records without a type, as sent for partial updates, are not
+// validated. Neither are share records, unless the schema defines their type.
+func (s *Schema) ValidateRecord(record icloud.Record) error {
+	if record.Type == "" {
+		return nil
+	}
+
+	rt, ok := s.RecordType(record.Type)
+	if !ok && record.Type == icloud.ShareRecordType {
+		return nil
+	} else if !ok {
+		return validationError(record.Type, "", "unknown record type")
+	}
+
+	for _, field := range record.Fields {
+		f, ok := rt.Field(field.Name)
+		if !ok {
+			return fieldError(rt, field.Name, "unknown field")
+		} else if f.IsSystem() {
+			return fieldError(rt, field.Name, "system fields can't be written")
+		}
+
+		if field.Type != "" && !matchesType(f.Type, field.Type) {
+			return fieldError(rt, field.Name, fmt.Sprintf("type %s doesn't match %s", field.Type, f.Type))
+		} else if field.Type == "" && !matchesValue(f.Type, field.Value) {
+			return fieldError(rt, field.Name, fmt.Sprintf("value of type %T doesn't match %s", field.Value, f.Type))
+		}
+	}
+
+	return nil
+}
+
+// ValidateQuery implements icloud.Schema. It reports unknown record types and
+// fields as well as filters and sorts on fields that aren't indexed
+// accordingly: Token comparators require a SEARCHABLE field, all other
+// comparators a QUERYABLE one. Sorting requires a SORTABLE field.
+func (s *Schema) ValidateQuery(query icloud.Query) error {
+	rt, ok := s.RecordType(query.RecordType)
+	if !ok {
+		return validationError(query.RecordType, "", "unknown record type")
+	}
+
+	for _, filter := range query.FilterBy {
+		f, ok := rt.Field(filter.FieldName)
+		if !ok {
+			return fieldError(rt, filter.FieldName, "unknown field")
+		}
+
+		switch filter.Comparator {
+		case icloud.ContainsAllTokens, icloud.ContainsAnyTokens:
+			if !f.Searchable {
+				return fieldError(rt, f.Name, fmt.Sprintf("comparator %s requires a SEARCHABLE index", filter.Comparator))
+			}
+		default:
+			if !f.Queryable {
+				return fieldError(rt, f.Name, fmt.Sprintf("comparator %s requires a QUERYABLE index", filter.Comparator))
+			}
+		}
+	}
+
+	for _, sort := range query.SortBy {
+		f, ok := rt.Field(sort.FieldName)
+		if !ok {
+			return fieldError(rt, sort.FieldName, "unknown field")
+		} else if !f.Sortable {
+			return fieldError(rt, f.Name, "sorting requires a SORTABLE index")
+		}
+	}
+
+	return nil
+}
+
+// fieldError returns a validation error for a field of the record type.
+func fieldError(rt *RecordType, field, reason string) *icloud.ValidationError {
+	return validationError(rt.Name, field, reason)
+}
+
+// validationError returns a validation error that isn't related to an
+// operation. The client sets the operation when validating a request.
+func validationError(recordType, field, reason string) *icloud.ValidationError {
+	return &icloud.ValidationError{
+		Operation:  -1,
+		RecordType: recordType,
+		Field:      field,
+		Reason:     reason,
+	}
+}
+
+// matchesType returns true, if a value of the given type can be written to a
+// field of the schema type. Assets are written as asset ids and empty lists
+// have no element type.
+func matchesType(schemaType icloud.FieldType, s string) bool {
+	typ, err := icloud.ParseFieldType(s)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case schemaType == typ:
+		return true
+	case schemaType == icloud.TypeAsset:
+		return typ == icloud.TypeAssetID
+	case schemaType == icloud.TypeAssetList:
+		return typ == icloud.TypeAssetIDList || typ == icloud.TypeUnknownList
+	}
+	return schemaType.IsList() && typ == icloud.TypeUnknownList
+}
+
+// matchesValue returns true, if the Go value can be written to a field of the
+// schema type.
+func matchesValue(schemaType icloud.FieldType, v interface{}) bool {
+	switch v.(type) {
+	case nil:
+		return true
+	case icloud.Location, *icloud.Location:
+		return schemaType == icloud.TypeLocation
+	case icloud.Asset, *icloud.Asset:
+		return schemaType == icloud.TypeAsset
+	case []byte:
+		return schemaType == icloud.TypeBytes
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return schemaType == icloud.TypeString || schemaType == icloud.TypeBytes
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return schemaType == icloud.TypeInt64 || schemaType == icloud.TypeDouble || schemaType == icloud.TypeTimestamp
+	case reflect.Slice, reflect.Array:
+		if !schemaType.IsList() {
+			return false
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if !matchesValue(schemaType.Elem(), rv.Index(i).Interface()) {
+				return false
+			}
+		}
+		return true
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return true
+		}
+		return matchesValue(schemaType, rv.Elem().Interface())
+	case reflect.Map, reflect.Struct:
+		return schemaType == icloud.TypeLocation || schemaType == icloud.TypeReference || schemaType == icloud.TypeAsset
+	}
+
+	return false
+}
@@ -0,0 +1,183 @@
+package schema_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lukasmalkmus/icloud-go/icloud"
+	"github.com/lukasmalkmus/icloud-go/icloud/icloudtest"
+	"github.com/lukasmalkmus/icloud-go/icloud/schema"
+)
+
+func TestSchema_ValidateRecord(t *testing.T) {
+	s, err := schema.Parse(strings.NewReader(canonical))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		record icloud.Record
+		err    string
+	}{
+		{
+			name: "valid",
+			record: icloud.Record{Type: "Post", Fields: icloud.Fields{
+				{Name: "title", Value: "Hello"},
+				{Name: "tags", Value: []string{"go", "cloudkit"}},
+				{Name: "grant", Value: 3},
+				{Name: "cover", Type: "ASSETID", Value: icloud.Asset{Receipt: "abc"}},
+				{Name: "body", Value: nil},
+			}},
+		},
+		{
+			name:   "without type",
+			record: icloud.Record{Fields: icloud.Fields{{Name: "unknown", Value: 1}}},
+		},
+		{
+			name:   "unknown record type",
+			record: icloud.Record{Type: "Comment"},
+			err:    `record type "Comment": unknown record type`,
+		},
+		{
+			name:   "share",
+			record: icloud.Record{Type: icloud.ShareRecordType, PublicPermission: icloud.PermissionReadOnly},
+		},
+		{
+			name:   "unknown field",
+			record: icloud.Record{Type: "Post", Fields: icloud.Fields{{Name: "titel", Value: "Hello"}}},
+			err:    `field "titel" of record type "Post": unknown field`,
+		},
+		{
+			name:   "system field",
+			record: icloud.Record{Type: "Post", Fields: icloud.Fields{{Name: "___recordID", Value: "a"}}},
+			err:    `field "___recordID" of record type "Post": system fields can't be written`,
+		},
+		{
+			name:   "type mismatch",
+			record: icloud.Record{Type: "Post", Fields: icloud.Fields{{Name: "title", Type: "INT64", Value: 1}}},
+			err:    `field "title" of record type "Post": type INT64 doesn't match STRING`,
+		},
+		{
+			name:   "value mismatch",
+			record: icloud.Record{Type: "Post", Fields: icloud.Fields{{Name: "grant", Value: "many"}}},
+			err:    `field "grant" of record type "Post": value of type string doesn't match INT64`,
+		},
+		{
+			name:   "list element mismatch",
+			record: icloud.Record{Type: "Post", Fields: icloud.Fields{{Name: "tags", Value: []interface{}{"go", 1}}}},
+			err:    `field "tags" of record type "Post": value of type []interface {} doesn't match STRING_LIST`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.ValidateRecord(tt.record)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSchema_ValidateQuery(t *testing.T) {
+	s, err := schema.Parse(strings.NewReader(canonical))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		query icloud.Query
+		err   string
+	}{
+		{
+			name: "valid",
+			query: icloud.Query{
+				RecordType: "Post",
+				FilterBy: []icloud.Filter{
+					{Comparator: icloud.BeginsWith, FieldName: "title"},
+					{Comparator: icloud.ContainsAnyTokens, FieldName: "title"},
+					{Comparator: icloud.ListContains, FieldName: "tags"},
+				},
+				SortBy: []icloud.Sort{{FieldName: "title"}},
+			},
+		},
+		{
+			name:  "unknown record type",
+			query: icloud.Query{RecordType: "Comment"},
+			err:   `record type "Comment": unknown record type`,
+		},
+		{
+			name: "unknown field",
+			query: icloud.Query{RecordType: "Post", FilterBy: []icloud.Filter{
+				{Comparator: icloud.Equals, FieldName: "titel"},
+			}},
+			err: `field "titel" of record type "Post": unknown field`,
+		},
+		{
+			name: "not queryable",
+			query: icloud.Query{RecordType: "Post", FilterBy: []icloud.Filter{
+				{Comparator: icloud.Equals, FieldName: "body"},
+			}},
+			err: `field "body" of record type "Post": comparator EQUALS requires a QUERYABLE index`,
+		},
+		{
+			name: "not searchable",
+			query: icloud.Query{RecordType: "Post", FilterBy: []icloud.Filter{
+				{Comparator: icloud.ContainsAllTokens, FieldName: "tags"},
+			}},
+			err: `field "tags" of record type "Post": comparator CONTAINS_ALL_TOKENS requires a SEARCHABLE index`,
+		},
+		{
+			name:  "not sortable",
+			query: icloud.Query{RecordType: "Post", SortBy: []icloud.Sort{{FieldName: "tags"}}},
+			err:   `field "tags" of record type "Post": sorting requires a SORTABLE index`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.ValidateQuery(tt.query)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSchema_Client(t *testing.T) {
+	s, err := schema.Parse(strings.NewReader(canonical))
+	require.NoError(t, err)
+
+	srv := icloudtest.NewServer()
+	defer srv.Close()
+
+	client, err := srv.NewClient("iCloud.com.lukasmalkmus.Example-App", icloud.Development, icloud.SetSchema(s))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	_, err = client.Records.Modify(ctx, icloud.Public, icloud.RecordsRequest{
+		Operations: []icloud.RecordOperation{
+			{Type: icloud.Create, Record: icloud.Record{Name: "a", Type: "Post", Fields: icloud.Fields{{Name: "title", Value: "Hello"}}}},
+			{Type: icloud.Create, Record: icloud.Record{Name: "b", Type: "Post", Fields: icloud.Fields{{Name: "title", Value: 42}}}},
+		},
+	})
+
+	var validationErr *icloud.ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, 1, validationErr.Operation)
+	assert.Equal(t, "title", validationErr.Field)
+	assert.EqualError(t, err, `operation 1: field "title" of record type "Post": value of type int doesn't match STRING`)
+
+	// Nothing has been written.
+	res, err := client.Records.Lookup(ctx, icloud.Public, icloud.LookupRequest{
+		Records: []icloud.Record{{Name: "a"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, icloud.NotFound, res.Records[0].ServerErrorCode)
+}
@@ -0,0 +1,552 @@
+package icloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+//go:generate ../bin/stringer -type=Permission,ParticipantType,AcceptanceStatus -linecomment -output=sharing_string.go
+
+// ShareRecordType is the record type of share records. A share record shares
+// the record hierarchy below the root record that references it with the
+// participants of the share.
+const ShareRecordType = "cloudkit.share"
+
+// Permission is the permission a participant of a share or the public has on
+// the shared records.
+type Permission uint8
+
+// All available permissions.
+const (
+	// PermissionUnknown is a permission unknown to the package.
+	PermissionUnknown Permission = iota // UNKNOWN
+	// PermissionNone grants no access to the shared records.
+	PermissionNone // NONE
+	// PermissionReadOnly grants read access to the shared records.
+	PermissionReadOnly // READ_ONLY
+	// PermissionReadWrite grants read and write access to the shared records.
+	PermissionReadWrite // READ_WRITE
+)
+
+// MarshalJSON implements json.Marshaler. It is in place to marshal the
+// Permission to its string representation because that's what the server
+// expects.
+func (p Permission) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It is in place to unmarshal the
+// Permission from the string representation the server returns. Permissions
+// unknown to the package are unmarshalled as PermissionUnknown, so responses
+// stay decodable when the server introduces new permissions.
+func (p *Permission) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	*p, _ = parsePermission(s)
+
+	return nil
+}
+
+// parsePermission returns the Permission with the given string representation.
+// Permissions unknown to the package are returned as PermissionUnknown, along
+// with their string representation. For known permissions, the returned string
+// is empty.
+func parsePermission(s string) (Permission, string) {
+	switch s {
+	case PermissionNone.String():
+		return PermissionNone, ""
+	case PermissionReadOnly.String():
+		return PermissionReadOnly, ""
+	case PermissionReadWrite.String():
+		return PermissionReadWrite, ""
+	}
+	return PermissionUnknown, s
+}
+
+// ParticipantType is the role of a participant of a share.
+type ParticipantType uint8
+
+// All available participant types.
+const (
+	// ParticipantUnknown is a participant type unknown to the package.
+	ParticipantUnknown ParticipantType = iota // UNKNOWN
+	// ParticipantOwner is the user that created the share.
+	ParticipantOwner // OWNER
+	// ParticipantAdministrator can manage the participants of the share.
+	ParticipantAdministrator // ADMINISTRATOR
+	// ParticipantUser is a user that was added to the share explicitly.
+	ParticipantUser // USER
+	// ParticipantPublicUser is a user that accepted a public share.
+	ParticipantPublicUser // PUBLIC_USER
+)
+
+// MarshalJSON implements json.Marshaler. It is in place to marshal the
+// ParticipantType to its string representation because that's what the server
+// expects.
+func (pt ParticipantType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(pt.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It is in place to unmarshal the
+// ParticipantType from the string representation the server returns.
+// Participant types unknown to the package are unmarshalled as
+// ParticipantUnknown, so responses stay decodable when the server introduces
+// new participant types.
+func (pt *ParticipantType) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	*pt, _ = parseParticipantType(s)
+
+	return nil
+}
+
+// parseParticipantType returns the ParticipantType with the given string
+// representation. Participant types unknown to the package are returned as
+// ParticipantUnknown, along with their string representation. For known
+// participant types, the returned string is empty.
+func parseParticipantType(s string) (ParticipantType, string) {
+	switch s {
+	case ParticipantOwner.String():
+		return ParticipantOwner, ""
+	case ParticipantAdministrator.String():
+		return ParticipantAdministrator, ""
+	case ParticipantUser.String():
+		return ParticipantUser, ""
+	case ParticipantPublicUser.String():
+		return ParticipantPublicUser, ""
+	}
+	return ParticipantUnknown, s
+}
+
+// AcceptanceStatus is the status of a participant of a share.
+type AcceptanceStatus uint8
+
+// All available acceptance statuses.
+const (
+	// StatusUnknown is the status of a participant whose status can't be
+	// determined. Statuses unknown to the package are unmarshalled as
+	// StatusUnknown as well.
+	StatusUnknown AcceptanceStatus = iota + 1 // UNKNOWN
+	// StatusPending is the status of a participant that hasn't accepted the
+	// share yet.
+	StatusPending // PENDING
+	// StatusAccepted is the status of a participant that accepted the share.
+	StatusAccepted // ACCEPTED
+	// StatusRemoved is the status of a participant that was removed from the
+	// share.
+	StatusRemoved // REMOVED
+)
+
+// MarshalJSON implements json.Marshaler. It is in place to marshal the
+// AcceptanceStatus to its string representation because that's what the
+// server expects.
+func (as AcceptanceStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(as.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It is in place to unmarshal the
+// AcceptanceStatus from the string representation the server returns. Statuses
+// unknown to the package are unmarshalled as StatusUnknown, so responses stay
+// decodable when the server introduces new statuses.
+func (as *AcceptanceStatus) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	*as, _ = parseAcceptanceStatus(s)
+
+	return nil
+}
+
+// parseAcceptanceStatus returns the AcceptanceStatus with the given string
+// representation. Statuses unknown to the package are returned as
+// StatusUnknown, along with their string representation. For known statuses,
+// the returned string is empty. An empty string is returned as the zero value,
+// so an absent status stays unset.
+func parseAcceptanceStatus(s string) (AcceptanceStatus, string) {
+	switch s {
+	case "":
+		return 0, ""
+	case StatusUnknown.String():
+		return StatusUnknown, ""
+	case StatusPending.String():
+		return StatusPending, ""
+	case StatusAccepted.String():
+		return StatusAccepted, ""
+	case StatusRemoved.String():
+		return StatusRemoved, ""
+	}
+	return StatusUnknown, s
+}
+
+// Participant is a participant of a share.
+type Participant struct {
+	// UserIdentity identifies the participant. When adding a participant,
+	// only its LookupInfo is required.
+	UserIdentity UserIdentity `json:"userIdentity"`
+	// Type of the participant. Set by the server. Types unknown to the
+	// package are ParticipantUnknown, use RawType to get them.
+	Type ParticipantType `json:"type,omitempty"`
+	// Permission the participant has on the shared records. Permissions
+	// unknown to the package are PermissionUnknown, use RawPermission to get
+	// them.
+	Permission Permission `json:"permission,omitempty"`
+	// AcceptanceStatus of the participant. Set by the server. Statuses unknown
+	// to the package are StatusUnknown, use RawAcceptanceStatus to get them.
+	AcceptanceStatus AcceptanceStatus `json:"acceptanceStatus,omitempty"`
+
+	rawType             string
+	rawPermission       string
+	rawAcceptanceStatus string
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It is in place to retain
+// participant types, permissions and statuses unknown to the package.
+func (p *Participant) UnmarshalJSON(b []byte) error {
+	type LocalParticipant Participant
+	localParticipant := struct {
+		*LocalParticipant
+
+		Type             string `json:"type"`
+		Permission       string `json:"permission"`
+		AcceptanceStatus string `json:"acceptanceStatus"`
+	}{
+		LocalParticipant: (*LocalParticipant)(p),
+	}
+
+	if err := json.Unmarshal(b, &localParticipant); err != nil {
+		return err
+	}
+
+	p.Type, p.rawType = parseParticipantType(localParticipant.Type)
+	p.Permission, p.rawPermission = parsePermission(localParticipant.Permission)
+	p.AcceptanceStatus, p.rawAcceptanceStatus = parseAcceptanceStatus(localParticipant.AcceptanceStatus)
+
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. It is in place to marshal participant
+// types, permissions and statuses unknown to the package as they were
+// unmarshalled.
+func (p Participant) MarshalJSON() ([]byte, error) {
+	type LocalParticipant Participant
+	localParticipant := struct {
+		LocalParticipant
+
+		Type             string `json:"type,omitempty"`
+		Permission       string `json:"permission,omitempty"`
+		AcceptanceStatus string `json:"acceptanceStatus,omitempty"`
+	}{
+		LocalParticipant: LocalParticipant(p),
+
+		Type:             marshalString(p.rawType, p.Type, p.Type == ParticipantUnknown),
+		Permission:       marshalString(p.rawPermission, p.Permission, p.Permission == PermissionUnknown),
+		AcceptanceStatus: marshalString(p.rawAcceptanceStatus, p.AcceptanceStatus, p.AcceptanceStatus == 0),
+	}
+
+	return json.Marshal(localParticipant)
+}
+
+// RawType returns the participant type as sent by the server. It is the string
+// representation of Type, unless the server sent a type unknown to the
+// package.
+func (p Participant) RawType() string {
+	return rawString(p.rawType, p.Type)
+}
+
+// RawPermission returns the permission as sent by the server. It is the string
+// representation of Permission, unless the server sent a permission unknown to
+// the package.
+func (p Participant) RawPermission() string {
+	return rawString(p.rawPermission, p.Permission)
+}
+
+// RawAcceptanceStatus returns the acceptance status as sent by the server. It
+// is the string representation of AcceptanceStatus, unless the server sent a
+// status unknown to the package.
+func (p Participant) RawAcceptanceStatus() string {
+	return rawString(p.rawAcceptanceStatus, p.AcceptanceStatus)
+}
+
+// UserIdentity identifies a user.
+type UserIdentity struct {
+	// UserRecordName is the record name of the user record. Only set if the
+	// user has been discovered.
+	UserRecordName string `json:"userRecordName,omitempty"`
+	// LookupInfo is the information the user was looked up by.
+	LookupInfo *LookupInfo `json:"lookupInfo,omitempty"`
+	// NameComponents is the name of the user. Only set if the user allowed to
+	// be discovered.
+	NameComponents *NameComponents `json:"nameComponents,omitempty"`
+}
+
+// LookupInfo is the information a user is looked up by. Only one of its
+// fields should be set.
+type LookupInfo struct {
+	// EmailAddress of the user's Apple ID.
+	EmailAddress string `json:"emailAddress,omitempty"`
+	// PhoneNumber of the user's Apple ID.
+	PhoneNumber string `json:"phoneNumber,omitempty"`
+	// UserRecordName is the record name of the user record.
+	UserRecordName string `json:"userRecordName,omitempty"`
+}
+
+// NameComponents is the name of a user.
+type NameComponents struct {
+	// GivenName of the user.
+	GivenName string `json:"givenName,omitempty"`
+	// FamilyName of the user.
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// NewShare returns a new share record with the given name for the root record
+// and points the share reference of the root record to it. If name is empty,
+// it is derived from the name of the root record. Both records must be saved
+// in the same request to a custom zone of the private database:
+//
+//	share := icloud.NewShare(&root, "")
+//	share.PublicPermission = icloud.PermissionNone
+//	share.Participants = []icloud.Participant{{
+//		UserIdentity: icloud.UserIdentity{
+//			LookupInfo: &icloud.LookupInfo{EmailAddress: "bob@example.com"},
+//		},
+//		Permission: icloud.PermissionReadWrite,
+//	}}
+//
+//	res, err := client.Records.Modify(ctx, icloud.Private, icloud.RecordsRequest{
+//		ZoneID: &zoneID,
+//		Operations: []icloud.RecordOperation{
+//			{Type: icloud.Update, Record: root},
+//			{Type: icloud.Create, Record: share},
+//		},
+//	})
+//
+// Records referencing the root record via their Parent are shared as well.
+// The saved share record carries the ShortGUID other users resolve and accept
+// the share by. Participants are added and removed by updating the share
+// record with the complete list of participants.
+func NewShare(root *Record, name string) Record {
+	if name == "" {
+		name = "Share-" + root.Name
+	}
+
+	root.Share = &Reference{RecordName: name}
+
+	return Record{
+		Name: name,
+		Type: ShareRecordType,
+	}
+}
+
+// ParseShareURL returns the short GUID of a share from its URL, e.g.
+// "https://www.icloud.com/share/0abcDEF#Title".
+func ParseShareURL(shareURL string) (string, error) {
+	u, err := url.Parse(shareURL)
+	if err != nil {
+		return "", err
+	}
+
+	shortGUID := strings.TrimPrefix(u.Path, "/share/")
+	if shortGUID == u.Path || shortGUID == "" || strings.Contains(shortGUID, "/") {
+		return "", fmt.Errorf("invalid share url %q", shareURL)
+	}
+
+	return shortGUID, nil
+}
+
+// ShareRequest is the request to the resolve and accept operations of the
+// RecordsService.
+type ShareRequest struct {
+	// ShortGUIDs of the shares to resolve or accept.
+	ShortGUIDs []ShortGUID `json:"shortGUIDs"`
+}
+
+// ShortGUID identifies a share. It is the last path element of the URL of the
+// share and is extracted by ParseShareURL.
+type ShortGUID struct {
+	// Value of the short GUID.
+	Value string `json:"value"`
+	// ShouldFetchRootRecord requests the root record of the share to be
+	// returned with the share metadata.
+	ShouldFetchRootRecord bool `json:"shouldFetchRootRecord,omitempty"`
+}
+
+// ShareMetadata describes a share and the current user's participation in it.
+type ShareMetadata struct {
+	// ShortGUID of the share.
+	ShortGUID string `json:"shortGUID,omitempty"`
+	// ContainerIdentifier of the container the share belongs to.
+	ContainerIdentifier string `json:"containerIdentifier,omitempty"`
+	// Environment of the container the share belongs to.
+	Environment string `json:"environment,omitempty"`
+	// ZoneID of the zone of the shared records in the owner's private
+	// database.
+	ZoneID *ZoneID `json:"zoneID,omitempty"`
+	// Share record.
+	Share *Record `json:"share,omitempty"`
+	// RootRecordName is the name of the root record of the share.
+	RootRecordName string `json:"rootRecordName,omitempty"`
+	// RootRecord of the share. Only set if requested by ShouldFetchRootRecord.
+	RootRecord *Record `json:"rootRecord,omitempty"`
+	// OwnerIdentity identifies the owner of the share.
+	OwnerIdentity *UserIdentity `json:"ownerIdentity,omitempty"`
+	// ParticipantType of the current user. Types unknown to the package are
+	// ParticipantUnknown, use RawParticipantType to get them.
+	ParticipantType ParticipantType `json:"participantType,omitempty"`
+	// ParticipantPermission of the current user. Permissions unknown to the
+	// package are PermissionUnknown, use RawParticipantPermission to get them.
+	ParticipantPermission Permission `json:"participantPermission,omitempty"`
+	// ParticipantStatus of the current user. Statuses unknown to the package
+	// are StatusUnknown, use RawParticipantStatus to get them.
+	ParticipantStatus AcceptanceStatus `json:"participantStatus,omitempty"`
+	// Reason resolving or accepting the share failed.
+	Reason string `json:"reason,omitempty"`
+	// ServerErrorCode is the error code of the failed operation on the share.
+	// Codes unknown to the package are Unknown, the error returned by Err
+	// retains them.
+	ServerErrorCode ErrorCode `json:"serverErrorCode,omitempty"`
+
+	rawParticipantType       string
+	rawParticipantPermission string
+	rawParticipantStatus     string
+	rawErrorCode             string
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It is in place to retain
+// participant types, permissions, statuses and server error codes unknown to
+// the package.
+func (m *ShareMetadata) UnmarshalJSON(b []byte) error {
+	type LocalShareMetadata ShareMetadata
+	localShareMetadata := struct {
+		*LocalShareMetadata
+
+		ParticipantType       string `json:"participantType"`
+		ParticipantPermission string `json:"participantPermission"`
+		ParticipantStatus     string `json:"participantStatus"`
+		ServerErrorCode       string `json:"serverErrorCode"`
+	}{
+		LocalShareMetadata: (*LocalShareMetadata)(m),
+	}
+
+	if err := json.Unmarshal(b, &localShareMetadata); err != nil {
+		return err
+	}
+
+	m.ParticipantType, m.rawParticipantType = parseParticipantType(localShareMetadata.ParticipantType)
+	m.ParticipantPermission, m.rawParticipantPermission = parsePermission(localShareMetadata.ParticipantPermission)
+	m.ParticipantStatus, m.rawParticipantStatus = parseAcceptanceStatus(localShareMetadata.ParticipantStatus)
+	m.ServerErrorCode, m.rawErrorCode = parseErrorCode(localShareMetadata.ServerErrorCode)
+
+	return nil
+}
+
+// RawParticipantType returns the participant type of the current user as sent
+// by the server. It is the string representation of ParticipantType, unless
+// the server sent a type unknown to the package.
+func (m ShareMetadata) RawParticipantType() string {
+	return rawString(m.rawParticipantType, m.ParticipantType)
+}
+
+// RawParticipantPermission returns the permission of the current user as sent
+// by the server. It is the string representation of ParticipantPermission,
+// unless the server sent a permission unknown to the package.
+func (m ShareMetadata) RawParticipantPermission() string {
+	return rawString(m.rawParticipantPermission, m.ParticipantPermission)
+}
+
+// RawParticipantStatus returns the acceptance status of the current user as
+// sent by the server. It is the string representation of ParticipantStatus,
+// unless the server sent a status unknown to the package.
+func (m ShareMetadata) RawParticipantStatus() string {
+	return rawString(m.rawParticipantStatus, m.ParticipantStatus)
+}
+
+// Err returns the error that occurred while resolving or accepting the share,
+// if any.
+func (m ShareMetadata) Err() error {
+	if m.ServerErrorCode == Unknown && m.rawErrorCode == "" && m.Reason == "" {
+		return nil
+	}
+	return Error{
+		Reason:  m.Reason,
+		Code:    m.ServerErrorCode,
+		rawCode: m.rawErrorCode,
+	}
+}
+
+// ShareResponse is the response recevied from the resolve and accept
+// operations of the RecordsService.
+type ShareResponse struct {
+	// Results hold the metadata of the shares in the order of the short GUIDs
+	// of the request.
+	Results []ShareMetadata `json:"results,omitempty"`
+
+	rc requestContext
+}
+
+// Err returns the error of the operation at index i, if any. It is a
+// *RequestError that wraps the error returned by Err of the share with the
+// context of the request.
+func (r *ShareResponse) Err(i int) error {
+	err := r.Results[i].Err()
+	if err == nil {
+		return nil
+	}
+
+	rc := r.rc
+	rc.zoneID = r.Results[i].ZoneID
+	return rc.wrap(err, i, r.Results[i].RootRecordName)
+}
+
+func (r *ShareResponse) setRequestContext(rc requestContext) {
+	r.rc = rc
+}
+
+func (r *ShareResponse) errorCodes() []ErrorCode {
+	var codes []ErrorCode
+	for _, result := range r.Results {
+		if result.Err() != nil {
+			codes = append(codes, result.ServerErrorCode)
+		}
+	}
+	return codes
+}
+
+// Resolve fetches the metadata of shares by their short GUIDs without
+// accepting them.
+func (s *RecordsService) Resolve(ctx context.Context, req ShareRequest) (*ShareResponse, error) {
+	path := "/" + Public.String() + s.basePath + "/resolve"
+
+	var res ShareResponse
+	if err := s.client.call(ctx, http.MethodPost, path, req, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// Accept accepts shares by their short GUIDs on behalf of the current user.
+// The records of accepted shares are available in the Shared database. For
+// requests signed by a server-to-server key, the current user is the user
+// that created the key.
+func (s *RecordsService) Accept(ctx context.Context, req ShareRequest) (*ShareResponse, error) {
+	path := "/" + Shared.String() + s.basePath + "/accept"
+
+	var res ShareResponse
+	if err := s.client.call(ctx, http.MethodPost, path, req, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
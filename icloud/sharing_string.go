@@ -0,0 +1,68 @@
+// Code generated by "stringer -type=Permission,ParticipantType,AcceptanceStatus -linecomment -output=sharing_string.go"; DO NOT EDIT.
+
+package icloud
+
+import "strconv"
+
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[PermissionUnknown-0]
+	_ = x[PermissionNone-1]
+	_ = x[PermissionReadOnly-2]
+	_ = x[PermissionReadWrite-3]
+}
+
+const _Permission_name = "UNKNOWNNONEREAD_ONLYREAD_WRITE"
+
+var _Permission_index = [...]uint8{0, 7, 11, 20, 30}
+
+func (i Permission) String() string {
+	if i >= Permission(len(_Permission_index)-1) {
+		return "Permission(" + strconv.FormatInt(int64(i), 10) + ")"
+	}
+	return _Permission_name[_Permission_index[i]:_Permission_index[i+1]]
+}
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[ParticipantUnknown-0]
+	_ = x[ParticipantOwner-1]
+	_ = x[ParticipantAdministrator-2]
+	_ = x[ParticipantUser-3]
+	_ = x[ParticipantPublicUser-4]
+}
+
+const _ParticipantType_name = "UNKNOWNOWNERADMINISTRATORUSERPUBLIC_USER"
+
+var _ParticipantType_index = [...]uint8{0, 7, 12, 25, 29, 40}
+
+func (i ParticipantType) String() string {
+	if i >= ParticipantType(len(_ParticipantType_index)-1) {
+		return "ParticipantType(" + strconv.FormatInt(int64(i), 10) + ")"
+	}
+	return _ParticipantType_name[_ParticipantType_index[i]:_ParticipantType_index[i+1]]
+}
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[StatusUnknown-1]
+	_ = x[StatusPending-2]
+	_ = x[StatusAccepted-3]
+	_ = x[StatusRemoved-4]
+}
+
+const _AcceptanceStatus_name = "UNKNOWNPENDINGACCEPTEDREMOVED"
+
+var _AcceptanceStatus_index = [...]uint8{0, 7, 14, 22, 29}
+
+func (i AcceptanceStatus) String() string {
+	i -= 1
+	if i >= AcceptanceStatus(len(_AcceptanceStatus_index)-1) {
+		return "AcceptanceStatus(" + strconv.FormatInt(int64(i+1), 10) + ")"
+	}
+	return _AcceptanceStatus_name[_AcceptanceStatus_index[i]:_AcceptanceStatus_index[i+1]]
+}
@@ -0,0 +1,95 @@
+package icloud
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewShare(t *testing.T) {
+	root := Record{Name: "list", Type: "List"}
+
+	share := NewShare(&root, "")
+	assert.Equal(t, Record{Name: "Share-list", Type: ShareRecordType}, share)
+	assert.Equal(t, &Reference{RecordName: "Share-list"}, root.Share)
+
+	share = NewShare(&root, "my-share")
+	assert.Equal(t, "my-share", share.Name)
+	assert.Equal(t, "my-share", root.Share.RecordName)
+}
+
+func TestParseShareURL(t *testing.T) {
+	shortGUID, err := ParseShareURL("https://www.icloud.com/share/0abcDEF#Groceries")
+	require.NoError(t, err)
+	assert.Equal(t, "0abcDEF", shortGUID)
+
+	for _, s := range []string{
+		"https://www.icloud.com/share/",
+		"https://www.icloud.com/photos/0abcDEF",
+		"https://www.icloud.com/share/0abcDEF/more",
+		"%",
+	} {
+		_, err = ParseShareURL(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParticipant_JSON(t *testing.T) {
+	participant := Participant{
+		UserIdentity: UserIdentity{
+			LookupInfo: &LookupInfo{EmailAddress: "bob@example.com"},
+		},
+		Type:             ParticipantUser,
+		Permission:       PermissionReadWrite,
+		AcceptanceStatus: StatusPending,
+	}
+
+	b, err := json.Marshal(participant)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"userIdentity": {"lookupInfo": {"emailAddress": "bob@example.com"}},
+		"type": "USER",
+		"permission": "READ_WRITE",
+		"acceptanceStatus": "PENDING"
+	}`, string(b))
+
+	var got Participant
+	require.NoError(t, json.Unmarshal(b, &got))
+	assert.Equal(t, participant, got)
+
+	var unknown Participant
+	require.NoError(t, json.Unmarshal([]byte(`{"type":"GUEST","permission":"ALL","acceptanceStatus":"DECLINED"}`), &unknown))
+	assert.Equal(t, ParticipantUnknown, unknown.Type)
+	assert.Equal(t, "GUEST", unknown.RawType())
+	assert.Equal(t, PermissionUnknown, unknown.Permission)
+	assert.Equal(t, "ALL", unknown.RawPermission())
+	assert.Equal(t, StatusUnknown, unknown.AcceptanceStatus)
+	assert.Equal(t, "DECLINED", unknown.RawAcceptanceStatus())
+
+	var unset Participant
+	require.NoError(t, json.Unmarshal([]byte(`{}`), &unset))
+	assert.Zero(t, unset.AcceptanceStatus)
+}
+
+func TestShareMetadata_JSON(t *testing.T) {
+	var m ShareMetadata
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"shortGUID": "0abcDEF",
+		"share": {"recordName": "share", "publicPermission": "COMMENT"},
+		"participantType": "GUEST",
+		"participantPermission": "READ_ONLY",
+		"participantStatus": "DECLINED"
+	}`), &m))
+
+	assert.Equal(t, ParticipantUnknown, m.ParticipantType)
+	assert.Equal(t, "GUEST", m.RawParticipantType())
+	assert.Equal(t, PermissionReadOnly, m.ParticipantPermission)
+	assert.Equal(t, "READ_ONLY", m.RawParticipantPermission())
+	assert.Equal(t, StatusUnknown, m.ParticipantStatus)
+	assert.Equal(t, "DECLINED", m.RawParticipantStatus())
+	assert.Equal(t, PermissionUnknown, m.Share.PublicPermission)
+	assert.Equal(t, "COMMENT", m.Share.RawPublicPermission())
+	assert.NoError(t, m.Err())
+}
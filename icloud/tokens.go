@@ -0,0 +1,152 @@
+package icloud
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+//go:generate ../bin/stringer -type=APNSEnvironment -linecomment -output=tokens_string.go
+
+// APNSEnvironment is the environment of the Apple Push Notification service
+// a token is valid for.
+type APNSEnvironment uint8
+
+const (
+	// APNSUnknown is an APNs environment unknown to the package.
+	APNSUnknown APNSEnvironment = iota // unknown
+	// APNSDevelopment is the APNs environment used by development builds of
+	// an app.
+	APNSDevelopment // development
+	// APNSProduction is the APNs environment used by apps available on the
+	// store.
+	APNSProduction // production
+)
+
+// MarshalJSON implements json.Marshaler. It is in place to marshal the
+// APNSEnvironment to its string representation because that's what the server
+// expects.
+func (e APNSEnvironment) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It is in place to unmarshal the
+// APNSEnvironment from the string representation the server returns.
+// Environments unknown to the package are unmarshalled as APNSUnknown, so
+// responses stay decodable when the server introduces new environments.
+func (e *APNSEnvironment) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	*e, _ = parseAPNSEnvironment(s)
+
+	return nil
+}
+
+// parseAPNSEnvironment returns the APNSEnvironment with the given string
+// representation. Environments unknown to the package are returned as
+// APNSUnknown, along with their string representation. For known environments,
+// the returned string is empty.
+func parseAPNSEnvironment(s string) (APNSEnvironment, string) {
+	switch s {
+	case APNSDevelopment.String():
+		return APNSDevelopment, ""
+	case APNSProduction.String():
+		return APNSProduction, ""
+	}
+	return APNSUnknown, s
+}
+
+// CreateTokenRequest is the request to the create operation of the
+// TokensService.
+type CreateTokenRequest struct {
+	// APNSEnvironment to create the token for.
+	APNSEnvironment APNSEnvironment `json:"apnsEnvironment"`
+}
+
+// RegisterTokenRequest is the request to the register operation of the
+// TokensService.
+type RegisterTokenRequest struct {
+	// APNSEnvironment the token is valid for.
+	APNSEnvironment APNSEnvironment `json:"apnsEnvironment"`
+	// APNSToken is the device token to register.
+	APNSToken string `json:"apnsToken"`
+}
+
+// Token is an APNs token notifications of subscriptions are delivered to.
+type Token struct {
+	// APNSEnvironment the token is valid for. Environments unknown to the
+	// package are APNSUnknown, use RawAPNSEnvironment to get them.
+	APNSEnvironment APNSEnvironment `json:"apnsEnvironment"`
+	// APNSToken is the token.
+	APNSToken string `json:"apnsToken"`
+	// WebcourierURL is the URL notifications for the token are received
+	// from. Only set for created tokens.
+	WebcourierURL string `json:"webcourierURL,omitempty"`
+
+	rawAPNSEnvironment string
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It is in place to retain APNs
+// environments unknown to the package.
+func (t *Token) UnmarshalJSON(b []byte) error {
+	type LocalToken Token
+	localToken := struct {
+		*LocalToken
+
+		APNSEnvironment string `json:"apnsEnvironment"`
+	}{
+		LocalToken: (*LocalToken)(t),
+	}
+
+	if err := json.Unmarshal(b, &localToken); err != nil {
+		return err
+	}
+
+	t.APNSEnvironment, t.rawAPNSEnvironment = parseAPNSEnvironment(localToken.APNSEnvironment)
+
+	return nil
+}
+
+// RawAPNSEnvironment returns the APNs environment as sent by the server. It is
+// the string representation of APNSEnvironment, unless the server sent an
+// environment unknown to the package.
+func (t Token) RawAPNSEnvironment() string {
+	return rawString(t.rawAPNSEnvironment, t.APNSEnvironment)
+}
+
+// TokensService handles communication with the APNs token related operations
+// of the CloudKit Web Services API.
+//
+// CloudKit Web Services Reference: https://developer.apple.com/library/archive/documentation/DataManagement/Conceptual/CloudKitWebServicesReference/CreateTokens.html
+type TokensService service
+
+// Create creates an APNs token for the given environment. Notifications for
+// the token are received from its WebcourierURL, which lets clients without a
+// device token, like a backend, receive the notifications of their
+// subscriptions.
+func (s *TokensService) Create(ctx context.Context, req CreateTokenRequest) (*Token, error) {
+	path := s.basePath + "/create"
+
+	var res Token
+	if err := s.client.callDevice(ctx, http.MethodPost, path, req, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// Register registers an existing APNs device token, so notifications of the
+// subscriptions of the current user are delivered to it.
+func (s *TokensService) Register(ctx context.Context, req RegisterTokenRequest) (*Token, error) {
+	path := s.basePath + "/register"
+
+	var res Token
+	if err := s.client.callDevice(ctx, http.MethodPost, path, req, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
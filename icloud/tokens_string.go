@@ -0,0 +1,25 @@
+// Code generated by "stringer -type=APNSEnvironment -linecomment -output=tokens_string.go"; DO NOT EDIT.
+
+package icloud
+
+import "strconv"
+
+func _() {
+	// An "invalid array index" compiler error signifies that the constant values have changed.
+	// Re-run the stringer command to generate them again.
+	var x [1]struct{}
+	_ = x[APNSUnknown-0]
+	_ = x[APNSDevelopment-1]
+	_ = x[APNSProduction-2]
+}
+
+const _APNSEnvironment_name = "unknowndevelopmentproduction"
+
+var _APNSEnvironment_index = [...]uint8{0, 7, 18, 28}
+
+func (i APNSEnvironment) String() string {
+	if i >= APNSEnvironment(len(_APNSEnvironment_index)-1) {
+		return "APNSEnvironment(" + strconv.FormatInt(int64(i), 10) + ")"
+	}
+	return _APNSEnvironment_name[_APNSEnvironment_index[i]:_APNSEnvironment_index[i+1]]
+}
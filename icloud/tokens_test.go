@@ -0,0 +1,90 @@
+package icloud
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPNSEnvironment_JSON(t *testing.T) {
+	for _, env := range []APNSEnvironment{APNSDevelopment, APNSProduction} {
+		b, err := json.Marshal(env)
+		require.NoError(t, err)
+		assert.Equal(t, `"`+env.String()+`"`, string(b))
+
+		var got APNSEnvironment
+		require.NoError(t, json.Unmarshal(b, &got))
+		assert.Equal(t, env, got)
+	}
+
+	var env APNSEnvironment
+	require.NoError(t, json.Unmarshal([]byte(`"staging"`), &env))
+	assert.Equal(t, APNSUnknown, env)
+
+	var token Token
+	require.NoError(t, json.Unmarshal([]byte(`{"apnsEnvironment":"staging","apnsToken":"abc"}`), &token))
+	assert.Equal(t, APNSUnknown, token.APNSEnvironment)
+	assert.Equal(t, "staging", token.RawAPNSEnvironment())
+}
+
+func TestTokensService_Create(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+
+		var req CreateTokenRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, APNSProduction, req.APNSEnvironment)
+
+		_, _ = w.Write([]byte(`{"apnsEnvironment":"production","apnsToken":"abc","webcourierURL":"https://webcourier.example.com/abc"}`))
+	}
+
+	client, teardown := setup(t, "/device/1/"+container+"/development/tokens/create", hf)
+	defer teardown()
+
+	var info *RequestInfo
+	require.NoError(t, client.Options(AddHooks(Hooks{
+		BeforeRequest: func(ctx context.Context, req *RequestInfo) context.Context {
+			info = req
+			return ctx
+		},
+	})))
+
+	token, err := client.Tokens.Create(context.Background(), CreateTokenRequest{APNSEnvironment: APNSProduction})
+	require.NoError(t, err)
+	assert.Equal(t, &Token{
+		APNSEnvironment: APNSProduction,
+		APNSToken:       "abc",
+		WebcourierURL:   "https://webcourier.example.com/abc",
+	}, token)
+
+	require.NotNil(t, info)
+	assert.Equal(t, "/tokens/create", info.Path)
+	assert.Equal(t, "tokens/create", info.Endpoint)
+	assert.Zero(t, info.Database)
+}
+
+func TestTokensService_Register(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+
+		var req RegisterTokenRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, RegisterTokenRequest{APNSEnvironment: APNSDevelopment, APNSToken: "abc"}, req)
+
+		_, _ = w.Write([]byte(`{"apnsEnvironment":"development","apnsToken":"abc"}`))
+	}
+
+	client, teardown := setup(t, "/device/1/"+container+"/development/tokens/register", hf)
+	defer teardown()
+
+	token, err := client.Tokens.Register(context.Background(), RegisterTokenRequest{
+		APNSEnvironment: APNSDevelopment,
+		APNSToken:       "abc",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &Token{APNSEnvironment: APNSDevelopment, APNSToken: "abc"}, token)
+}
@@ -0,0 +1,37 @@
+package icloud
+
+import (
+	"context"
+	"net/http"
+)
+
+// User is a user of the container.
+type User struct {
+	// RecordName of the user record.
+	RecordName string `json:"userRecordName"`
+	// FirstName of the user. Only set if the user allowed to be discovered.
+	FirstName string `json:"firstName,omitempty"`
+	// LastName of the user. Only set if the user allowed to be discovered.
+	LastName string `json:"lastName,omitempty"`
+	// EmailAddress of the user. Only set if the user allowed to be discovered.
+	EmailAddress string `json:"emailAddress,omitempty"`
+}
+
+// UsersService handles communication with the user related operations of the
+// CloudKit Web Services API.
+//
+// CloudKit Web Services Reference: https://developer.apple.com/library/archive/documentation/DataManagement/Conceptual/CloudKitWebServicesReference/GetCurrentUser.html
+type UsersService service
+
+// Current returns the user the request is made on behalf of. For requests
+// signed by a server-to-server key, this is the user that created the key.
+func (s *UsersService) Current(ctx context.Context) (*User, error) {
+	path := "/" + Public.String() + s.basePath + "/current"
+
+	var res User
+	if err := s.client.call(ctx, http.MethodGet, path, nil, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
@@ -0,0 +1,97 @@
+package icloud
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Schema validates records and queries against the record types of a
+// container before they are sent to the server. It is set using SetSchema. The
+// schema package implements it for schemas in the .ckdb format.
+type Schema interface {
+	// ValidateRecord validates a record that is about to be created, updated
+	// or replaced.
+	ValidateRecord(record Record) error
+	// ValidateQuery validates a query that is about to be sent.
+	ValidateQuery(query Query) error
+}
+
+// ValidationError is returned by the RecordsService if a request doesn't
+// match the Schema of the client. The request is not sent in that case.
+type ValidationError struct {
+	// Operation is the index of the offending operation of a RecordsRequest.
+	// It is -1 if the error isn't related to an operation, e.g. for queries.
+	Operation int
+	// RecordType of the offending record or query.
+	RecordType string
+	// Field that caused the error, if any.
+	Field string
+	// Reason the validation failed.
+	Reason string
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	var sb strings.Builder
+	if e.Operation >= 0 {
+		fmt.Fprintf(&sb, "operation %d: ", e.Operation)
+	}
+	switch {
+	case e.Field != "":
+		fmt.Fprintf(&sb, "field %q of record type %q: ", e.Field, e.RecordType)
+	case e.RecordType != "":
+		fmt.Fprintf(&sb, "record type %q: ", e.RecordType)
+	}
+	sb.WriteString(e.Reason)
+	return sb.String()
+}
+
+// validateOperations validates the records of the operations against the
+// schema of the client, if any. Deletions are not validated.
+func (c *Client) validateOperations(ops []RecordOperation) error {
+	if c.schema == nil {
+		return nil
+	}
+
+	for i, op := range ops {
+		if op.Type == Delete || op.Type == ForceDelete {
+			continue
+		}
+		if err := c.schema.ValidateRecord(op.Record); err != nil {
+			return validationError(i, op.Record.Type, err)
+		}
+	}
+
+	return nil
+}
+
+// validateQuery validates the query against the schema of the client, if any.
+func (c *Client) validateQuery(query Query) error {
+	if c.schema == nil {
+		return nil
+	}
+
+	if err := c.schema.ValidateQuery(query); err != nil {
+		return validationError(-1, query.RecordType, err)
+	}
+
+	return nil
+}
+
+// validationError returns the error returned by a Schema as a
+// *ValidationError of the given operation.
+func validationError(op int, recordType string, err error) *ValidationError {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		e := *validationErr
+		e.Operation = op
+		return &e
+	}
+
+	return &ValidationError{
+		Operation:  op,
+		RecordType: recordType,
+		Reason:     err.Error(),
+	}
+}
@@ -0,0 +1,183 @@
+package icloud
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// DefaultZoneName is the name of the default zone every database has. It is
+// the only zone of the public database.
+const DefaultZoneName = "_defaultZone"
+
+// ZoneID identifies a zone.
+type ZoneID struct {
+	// Name of the zone.
+	Name string `json:"zoneName"`
+	// OwnerName is the record name of the user that owns the zone. If not set,
+	// the current user is assumed.
+	OwnerName string `json:"ownerRecordName,omitempty"`
+}
+
+// Zone is a zone in the database.
+type Zone struct {
+	// ZoneID of the zone.
+	ZoneID ZoneID `json:"zoneID"`
+	// SyncToken identifies the latest change to the zone.
+	SyncToken string `json:"syncToken,omitempty"`
+	// Atomic is true, if the zone supports atomic operations.
+	Atomic bool `json:"atomic,omitempty"`
+	// Deleted is true, if the zone has been deleted.
+	Deleted bool `json:"deleted,omitempty"`
+	// Reason the operation on the zone failed.
+	Reason string `json:"reason,omitempty"`
+	// ServerErrorCode is the error code of the failed operation on the zone.
+	// Codes unknown to the package are Unknown, the error returned by Err
+	// retains them.
+	ServerErrorCode ErrorCode `json:"serverErrorCode,omitempty"`
+
+	rawErrorCode string
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It is in place to retain server
+// error codes unknown to the package.
+func (z *Zone) UnmarshalJSON(b []byte) error {
+	type LocalZone Zone
+	localZone := struct {
+		*LocalZone
+
+		ServerErrorCode string `json:"serverErrorCode"`
+	}{
+		LocalZone: (*LocalZone)(z),
+	}
+
+	if err := json.Unmarshal(b, &localZone); err != nil {
+		return err
+	}
+
+	z.ServerErrorCode, z.rawErrorCode = parseErrorCode(localZone.ServerErrorCode)
+
+	return nil
+}
+
+// Err returns the error that occurred while operating on the zone, if any.
+func (z Zone) Err() error {
+	if z.ServerErrorCode == Unknown && z.rawErrorCode == "" && z.Reason == "" {
+		return nil
+	}
+	return Error{
+		Reason:  z.Reason,
+		Code:    z.ServerErrorCode,
+		rawCode: z.rawErrorCode,
+	}
+}
+
+// ZonesRequest is the request to the modify operation of the ZonesService.
+type ZonesRequest struct {
+	// Operations to apply to zones in the database.
+	Operations []ZoneOperation `json:"operations,omitempty"`
+}
+
+// ZoneOperation is an operation on a single zone.
+type ZoneOperation struct {
+	// Type of the operation. Only Create and Delete are valid. Types unknown
+	// to the package are OperationUnknown, use RawType to get them.
+	Type OperationType `json:"operationType,omitempty"`
+	// Zone to create or delete.
+	Zone Zone `json:"zone"`
+
+	rawType string
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It is in place to retain
+// operation types unknown to the package.
+func (op *ZoneOperation) UnmarshalJSON(b []byte) error {
+	type LocalZoneOperation ZoneOperation
+	localZoneOperation := struct {
+		*LocalZoneOperation
+
+		Type string `json:"operationType"`
+	}{
+		LocalZoneOperation: (*LocalZoneOperation)(op),
+	}
+
+	if err := json.Unmarshal(b, &localZoneOperation); err != nil {
+		return err
+	}
+
+	op.Type, op.rawType = parseOperationType(localZoneOperation.Type)
+
+	return nil
+}
+
+// RawType returns the operation type as it was unmarshalled. It is the string
+// representation of Type, unless the operation type is unknown to the package.
+func (op ZoneOperation) RawType() string {
+	return rawString(op.rawType, op.Type)
+}
+
+// ZonesResponse is the response recevied from every operation of the
+// ZonesService.
+type ZonesResponse struct {
+	Zones []Zone `json:"zones,omitempty"`
+
+	rc requestContext
+}
+
+// Err returns the error of the operation at index i, if any. It is a
+// *RequestError that wraps the error returned by Err of the zone with the
+// context of the request.
+func (r *ZonesResponse) Err(i int) error {
+	err := r.Zones[i].Err()
+	if err == nil {
+		return nil
+	}
+
+	rc := r.rc
+	rc.zoneID = &r.Zones[i].ZoneID
+	return rc.wrap(err, i, "")
+}
+
+func (r *ZonesResponse) setRequestContext(rc requestContext) {
+	r.rc = rc
+}
+
+func (r *ZonesResponse) errorCodes() []ErrorCode {
+	var codes []ErrorCode
+	for _, zone := range r.Zones {
+		if zone.Err() != nil {
+			codes = append(codes, zone.ServerErrorCode)
+		}
+	}
+	return codes
+}
+
+// ZonesService handles communication with the zone related operations of the
+// CloudKit Web Services API.
+//
+// CloudKit Web Services Reference: https://developer.apple.com/library/archive/documentation/DataManagement/Conceptual/CloudKitWebServicesReference/ModifyZones.html
+type ZonesService service
+
+// List all zones of a database.
+func (s *ZonesService) List(ctx context.Context, database Database) (*ZonesResponse, error) {
+	path := "/" + database.String() + s.basePath + "/list"
+
+	var res ZonesResponse
+	if err := s.client.call(ctx, http.MethodGet, path, nil, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// Modify zones in a database.
+func (s *ZonesService) Modify(ctx context.Context, database Database, req ZonesRequest) (*ZonesResponse, error) {
+	path := "/" + database.String() + s.basePath + "/modify"
+
+	var res ZonesResponse
+	if err := s.client.call(ctx, http.MethodPost, path, req, &res); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}